@@ -0,0 +1,339 @@
+// admins.go — життєвий цикл персоналу: видача/зміна/відкликання ролі із
+// захистом останнього super-admin, адмін за замовчуванням з конфігурації
+// (DEFAULT_ADMIN_EMAIL) та одноразовий токен bootstrap — обидва лише для
+// порожньої бази.
+// Записи admins/{userId}; email — лише для відображення.
+//
+// Роль з roleMap провайдера (idp.go) має перевагу над виданою вручну і
+// дзеркалиться в admins/{userId} з source "idp:<name>" при вході, тож захист
+// останнього super-admin і четверо очей (approvals.go) її враховують. Такий
+// запис змінюється лише в IdP: видача/відкликання через API повертає 409, а
+// вхід без ролі з того ж провайдера його видаляє.
+
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+const bootstrapTokenTTL = 24 * time.Hour
+
+var errLastSuperAdmin = errors.New("cannot remove the last super-admin")
+
+type AdminRecord struct {
+	UserID    string `json:"userId"`
+	Email     string `json:"email"`
+	Role      Role   `json:"role"`
+	GrantedBy string `json:"grantedBy,omitempty"`
+	GrantedAt int64  `json:"grantedAt,omitempty"`
+	Source    string `json:"source,omitempty"`
+}
+
+type FSAdmin struct {
+	UserID    string    `firestore:"userId"`
+	Email     string    `firestore:"email"`
+	Role      string    `firestore:"role,omitempty"` // порожньо — старий запис, super-admin
+	GrantedBy string    `firestore:"grantedBy,omitempty"`
+	GrantedAt time.Time `firestore:"grantedAt,omitempty"`
+	CreatedAt time.Time `firestore:"createdAt,omitempty"`
+	UpdatedAt time.Time `firestore:"updatedAt,omitempty"`
+	Source    string    `firestore:"source,omitempty"` // "idp:<name>" — керується провайдером
+}
+
+func (a FSAdmin) role() Role {
+	if r, ok := validStaffRole(a.Role); ok {
+		return r
+	}
+	return RoleSuperAdmin
+}
+
+func adminFromFS(a FSAdmin) AdminRecord {
+	at := a.GrantedAt
+	if at.IsZero() {
+		at = a.CreatedAt
+	}
+	var ms int64
+	if !at.IsZero() {
+		ms = at.UnixMilli()
+	}
+	return AdminRecord{UserID: a.UserID, Email: a.Email, Role: a.role(), GrantedBy: a.GrantedBy, GrantedAt: ms, Source: a.Source}
+}
+
+func idpSource(provider string) string { return "idp:" + provider }
+
+// errIdPManaged — роль з IdP не змінюється через API, вхід її відновить
+func errIdPManaged(a FSAdmin) error {
+	return opError{409, "role is managed by identity provider " + strings.TrimPrefix(a.Source, "idp:")}
+}
+
+func adminDoc(uid string) *firestore.DocumentRef { return fsDoc("admins/" + uid) }
+
+func fsListAdmins(ctx context.Context) ([]AdminRecord, error) {
+	docs, err := fsCol("admins").Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]AdminRecord, 0, len(docs))
+	ids := make([]string, 0, len(docs))
+	for _, d := range docs {
+		var a FSAdmin
+		if err := d.DataTo(&a); err != nil {
+			return nil, err
+		}
+		if a.UserID == "" {
+			a.UserID = d.Ref.ID
+		}
+		ids = append(ids, a.UserID)
+		out = append(out, adminFromFS(a))
+	}
+	// актуальні email-и з users/{id}
+	emails, err := fsUserEmails(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range out {
+		if e := emails[out[i].UserID]; e != "" {
+			out[i].Email = e
+		}
+	}
+	return out, nil
+}
+
+// superAdminsExcept — скільки super-admin лишиться без uid (у транзакції)
+func superAdminsExcept(tx *firestore.Transaction, uid string) (int, error) {
+	docs, err := tx.Documents(fsCol("admins")).GetAll()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, d := range docs {
+		var a FSAdmin
+		if err := d.DataTo(&a); err != nil {
+			return 0, err
+		}
+		if d.Ref.ID != uid && a.role() == RoleSuperAdmin {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// fsGrantAdmin — видати або змінити роль; пониження останнього super-admin заборонене.
+// Повертає і попередню роль ("" — не був персоналом).
+func fsGrantAdmin(ctx context.Context, u FSUser, role Role, by string) (AdminRecord, Role, error) {
+	var out FSAdmin
+	var prev Role
+	err := fsClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		ref := adminDoc(u.ID)
+		s, err := tx.Get(ref)
+		exists := err == nil
+		if err != nil && !strings.Contains(strings.ToLower(err.Error()), "not found") {
+			return err
+		}
+		now := time.Now()
+		out = FSAdmin{UserID: u.ID, Email: u.Email, Role: string(role), GrantedBy: by, GrantedAt: now, UpdatedAt: now}
+		if exists {
+			var cur FSAdmin
+			if err := s.DataTo(&cur); err != nil {
+				return err
+			}
+			if cur.Source != "" {
+				return errIdPManaged(cur)
+			}
+			prev = cur.role()
+			if prev == RoleSuperAdmin && role != RoleSuperAdmin {
+				n, err := superAdminsExcept(tx, u.ID)
+				if err != nil {
+					return err
+				}
+				if n == 0 {
+					return errLastSuperAdmin
+				}
+			}
+			out.CreatedAt = cur.CreatedAt
+		} else {
+			out.CreatedAt = now
+		}
+		return tx.Set(ref, out)
+	})
+	invalidateStaffRole(u.ID)
+	return adminFromFS(out), prev, err
+}
+
+// fsRevokeAdmin — відкликати роль персоналу; повертає відкликану роль,
+// (_, false, nil) — запису немає
+func fsRevokeAdmin(ctx context.Context, uid string) (Role, bool, error) {
+	found := true
+	var prev Role
+	err := fsClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		ref := adminDoc(uid)
+		s, err := tx.Get(ref)
+		if err != nil {
+			if strings.Contains(strings.ToLower(err.Error()), "not found") {
+				found = false
+				return nil
+			}
+			return err
+		}
+		var cur FSAdmin
+		if err := s.DataTo(&cur); err != nil {
+			return err
+		}
+		if cur.Source != "" {
+			return errIdPManaged(cur)
+		}
+		prev = cur.role()
+		if prev == RoleSuperAdmin {
+			n, err := superAdminsExcept(tx, uid)
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				return errLastSuperAdmin
+			}
+		}
+		return tx.Delete(ref)
+	})
+	invalidateStaffRole(uid)
+	return prev, found, err
+}
+
+// idpRoleSynced — остання віддзеркалена роль за uid|provider, щоб не писати
+// в Firestore на кожен запит
+var idpRoleSynced = newTTLCache[Role]()
+
+// syncIdPRole — віддзеркалює роль з roleMap у admins/{uid}: ставить або
+// оновлює запис провайдера, знімає його, коли IdP роль більше не видає.
+// Записи, видані вручну, без ролі з IdP не чіпає; з роллю — перекриває
+// (IdP має перевагу). Захист останнього super-admin тут не діє: джерело
+// правди — IdP.
+func syncIdPRole(ctx context.Context, uid, email, provider string, role Role) error {
+	key := uid + "|" + provider
+	if r, ok := idpRoleSynced.get(key); ok && r == role {
+		return nil
+	}
+	src := idpSource(provider)
+	changed := false
+	err := fsClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		changed = false
+		ref := adminDoc(uid)
+		s, err := tx.Get(ref)
+		exists := err == nil
+		if err != nil && !strings.Contains(strings.ToLower(err.Error()), "not found") {
+			return err
+		}
+		var cur FSAdmin
+		if exists {
+			if err := s.DataTo(&cur); err != nil {
+				return err
+			}
+		}
+		switch {
+		case role == "":
+			if !exists || cur.Source != src {
+				return nil
+			}
+			changed = true
+			return tx.Delete(ref)
+		case exists && cur.Source == src && cur.role() == role:
+			return nil
+		}
+		now := time.Now()
+		x := FSAdmin{UserID: uid, Email: email, Role: string(role), GrantedBy: src, GrantedAt: now, CreatedAt: now, UpdatedAt: now, Source: src}
+		if exists && !cur.CreatedAt.IsZero() {
+			x.CreatedAt = cur.CreatedAt
+		}
+		changed = true
+		return tx.Set(ref, x)
+	})
+	if err != nil {
+		return err
+	}
+	if changed {
+		invalidateStaffRole(uid)
+		log.Printf("[admin] %s role for %s synced from %s: %q\n", uid, email, provider, role)
+	}
+	idpRoleSynced.set(key, role, roleCacheTTL)
+	return nil
+}
+
+// ================== BOOTSTRAP ==================
+
+func bootstrapDoc() *firestore.DocumentRef { return fsDoc("system/bootstrap") }
+
+func hashBootstrapToken(tok string) string { return sha256Hex(strings.TrimSpace(tok)) }
+
+// issueBootstrapToken — новий одноразовий токен (попередній стає недійсним);
+// у базі лише хеш
+func issueBootstrapToken(ctx context.Context) (string, time.Time, error) {
+	tok := "mkb_" + randToken(24)
+	exp := time.Now().Add(bootstrapTokenTTL)
+	_, err := bootstrapDoc().Set(ctx, map[string]any{
+		"tokenHash": hashBootstrapToken(tok),
+		"createdAt": time.Now(),
+		"expiresAt": exp,
+	})
+	return tok, exp, err
+}
+
+// ensureBootstrapToken — якщо адмінів немає, друкує токен у лог при старті
+func ensureBootstrapToken(ctx context.Context) {
+	if _, err := fsCol("admins").Limit(1).Documents(ctx).Next(); err == nil {
+		return
+	}
+	tok, exp, err := issueBootstrapToken(ctx)
+	if err != nil {
+		log.Printf("[admin] bootstrap token: %v\n", err)
+		return
+	}
+	log.Printf("[admin] no admins yet — bootstrap token (valid until %s): %s\n", exp.UTC().Format(time.RFC3339), tok)
+}
+
+// consumeBootstrapToken — перевіряє токен, робить користувача першим super-admin і гасить токен
+func consumeBootstrapToken(ctx context.Context, tok, uid, email string) error {
+	return fsClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		s, err := tx.Get(bootstrapDoc())
+		if err != nil {
+			if strings.Contains(strings.ToLower(err.Error()), "not found") {
+				return fmt.Errorf("invalid bootstrap token")
+			}
+			return err
+		}
+		var rec struct {
+			TokenHash string    `firestore:"tokenHash"`
+			ExpiresAt time.Time `firestore:"expiresAt"`
+		}
+		if err := s.DataTo(&rec); err != nil {
+			return err
+		}
+		if subtle.ConstantTimeCompare([]byte(rec.TokenHash), []byte(hashBootstrapToken(tok))) != 1 {
+			return fmt.Errorf("invalid bootstrap token")
+		}
+		if time.Now().After(rec.ExpiresAt) {
+			return fmt.Errorf("bootstrap token expired")
+		}
+		admins, err := tx.Documents(fsCol("admins").Limit(1)).GetAll()
+		if err != nil {
+			return err
+		}
+		if len(admins) > 0 {
+			return fmt.Errorf("already initialized")
+		}
+		now := time.Now()
+		if err := tx.Create(adminDoc(uid), FSAdmin{
+			UserID: uid, Email: email, Role: string(RoleSuperAdmin), GrantedBy: "bootstrap",
+			GrantedAt: now, CreatedAt: now,
+		}); err != nil {
+			return err
+		}
+		return tx.Delete(bootstrapDoc())
+	})
+}
@@ -0,0 +1,383 @@
+// apikeys.go — довгоживучі API-ключі брендів для машинних клієнтів (фабрики):
+// зберігаються лише хеші секретів, ключ обмежений скоупами і строком дії
+// і діє від імені бренду (X-Api-Key: mk_<id>_<secret>).
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+const (
+	ScopeMint  = "mint"
+	ScopeRead  = "read"
+	ScopeBatch = "batch"
+)
+
+var apiKeyScopes = []string{ScopeMint, ScopeRead, ScopeBatch}
+
+type APIKey struct {
+	ID         string   `json:"id"`
+	BrandSlug  string   `json:"brandSlug"`
+	Label      string   `json:"label"`
+	Prefix     string   `json:"prefix"` // перші символи для впізнавання в UI
+	Scopes     []string `json:"scopes"`
+	CreatedBy  string   `json:"createdBy"`
+	CreatedAt  int64    `json:"createdAt"`
+	ExpiresAt  int64    `json:"expiresAt,omitempty"`
+	RevokedAt  int64    `json:"revokedAt,omitempty"`
+	LastUsedAt int64    `json:"lastUsedAt,omitempty"`
+	LastUsedIP string   `json:"lastUsedIp,omitempty"`
+	Signed     bool     `json:"signed"` // мінт і партії лише з підписом запиту (signing.go)
+}
+
+type FSAPIKey struct {
+	ID          string    `firestore:"id"`
+	BrandSlug   string    `firestore:"brandSlug"`
+	Label       string    `firestore:"label"`
+	Prefix      string    `firestore:"prefix"`
+	SecretHash  string    `firestore:"secretHash"`
+	Scopes      []string  `firestore:"scopes"`
+	CreatedBy   string    `firestore:"createdBy"`
+	CreatedAt   time.Time `firestore:"createdAt"`
+	ExpiresAt   time.Time `firestore:"expiresAt,omitempty"`
+	RevokedAt   time.Time `firestore:"revokedAt,omitempty"`
+	LastUsedAt  time.Time `firestore:"lastUsedAt,omitempty"`
+	LastUsedIP  string    `firestore:"lastUsedIp,omitempty"`
+	SigningSalt string    `firestore:"signingSalt,omitempty"` // порожньо — без підпису
+}
+
+func apiKeyFromFS(k FSAPIKey) APIKey {
+	out := APIKey{
+		ID: k.ID, BrandSlug: k.BrandSlug, Label: k.Label, Prefix: k.Prefix,
+		Scopes: append([]string{}, k.Scopes...), CreatedBy: k.CreatedBy,
+		CreatedAt: k.CreatedAt.UnixMilli(), LastUsedIP: k.LastUsedIP,
+		Signed: k.SigningSalt != "",
+	}
+	if !k.ExpiresAt.IsZero() {
+		out.ExpiresAt = k.ExpiresAt.UnixMilli()
+	}
+	if !k.RevokedAt.IsZero() {
+		out.RevokedAt = k.RevokedAt.UnixMilli()
+	}
+	if !k.LastUsedAt.IsZero() {
+		out.LastUsedAt = k.LastUsedAt.UnixMilli()
+	}
+	return out
+}
+
+// apiKeyPrincipal — ключ, яким автентифіковано запит (кладеться в контекст)
+type apiKeyPrincipal struct {
+	ID        string
+	BrandSlug string
+	Scopes    []string
+}
+
+// apiKeyUserID — окремий принципал ключа замість акаунта власника бренду:
+// ключ не бачить особистих продуктів і партій власника, а права має лише
+// через canBrand у своєму бренді (brandRole).
+func apiKeyUserID(id string) string {
+	return "apikey:" + id
+}
+
+type apiKeyCtxKey struct{}
+
+func apiKeyFromContext(ctx context.Context) *apiKeyPrincipal {
+	k, _ := ctx.Value(apiKeyCtxKey{}).(*apiKeyPrincipal)
+	return k
+}
+
+// apiKeyScopeFor — який скоуп потрібен маршруту; "" — ключам сюди не можна
+func apiKeyScopeFor(r *http.Request) string {
+	p := r.URL.Path
+	switch {
+	case p == "/api/manufacturer/products" && r.Method == http.MethodPost:
+		return ScopeMint
+	case p == "/api/manufacturer/products" && r.Method == http.MethodGet,
+		strings.HasPrefix(p, "/api/verify/") && r.Method == http.MethodGet,
+		strings.HasPrefix(p, "/api/products/") && r.Method == http.MethodGet &&
+			(strings.HasSuffix(p, "/qr.png") || strings.HasSuffix(p, "/qr.svg")):
+		return ScopeRead
+	case p == "/api/manufacturer/batches",
+		strings.HasPrefix(p, "/api/manufacturer/batches/") && (r.Method == http.MethodGet || strings.HasSuffix(p, "/labels.pdf")),
+		p == "/api/manufacturer/labels.pdf",
+		strings.HasPrefix(p, "/api/products/") && r.Method == http.MethodPost &&
+			(strings.HasSuffix(p, "/qr.png") || strings.HasSuffix(p, "/qr.svg")),
+		p == "/api/manufacturer/offline-bundle" && r.Method == http.MethodGet:
+		return ScopeBatch
+	}
+	return ""
+}
+
+func hashAPISecret(secret string) string {
+	h := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(h[:])
+}
+
+// authAPIKey перевіряє X-Api-Key і повертає запит з ключем у контексті.
+// code != 0 — відмова.
+func authAPIKey(r *http.Request) (*http.Request, int, string) {
+	raw := strings.TrimSpace(r.Header.Get("X-Api-Key"))
+	rest, ok := strings.CutPrefix(raw, "mk_")
+	id, secret, ok2 := strings.Cut(rest, "_")
+	if !ok || !ok2 || id == "" || secret == "" {
+		return r, 401, "invalid api key"
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	s, err := fsDoc("apiKeys/" + id).Get(ctx)
+	if err != nil {
+		return r, 401, "invalid api key"
+	}
+	var k FSAPIKey
+	if err := s.DataTo(&k); err != nil ||
+		subtle.ConstantTimeCompare([]byte(hashAPISecret(secret)), []byte(k.SecretHash)) != 1 {
+		return r, 401, "invalid api key"
+	}
+	now := time.Now()
+	switch {
+	case !k.RevokedAt.IsZero():
+		return r, 401, "api key revoked"
+	case !k.ExpiresAt.IsZero() && now.After(k.ExpiresAt):
+		return r, 401, "api key expired"
+	}
+	scope := apiKeyScopeFor(r)
+	if scope == "" || !slices.Contains(k.Scopes, scope) {
+		return r, 403, "api key scope does not allow this request"
+	}
+	if k.SigningSalt != "" && (scope == ScopeMint || scope == ScopeBatch) {
+		if code, msg := verifySignedRequest(ctx, r, k); code != 0 {
+			return r, code, msg
+		}
+	}
+	if _, err := fsGetBrand(ctx, k.BrandSlug); err != nil {
+		return r, 401, "api key brand not found"
+	}
+
+	// lastUsed пишемо не частіше разу на хвилину
+	if now.Sub(k.LastUsedAt) > time.Minute {
+		if _, err := s.Ref.Update(ctx, []firestore.Update{
+			{Path: "lastUsedAt", Value: now},
+			{Path: "lastUsedIp", Value: clientIP(r)},
+		}); err != nil {
+			log.Printf("[apikey] %s: last-used update failed: %v\n", k.ID, err)
+		}
+	}
+	p := &apiKeyPrincipal{ID: k.ID, BrandSlug: k.BrandSlug, Scopes: k.Scopes}
+	return r.WithContext(context.WithValue(r.Context(), apiKeyCtxKey{}, p)), 0, ""
+}
+
+// ================== FIRESTORE ==================
+
+func fsCreateAPIKey(ctx context.Context, slug, label, by string, scopes []string, ttl time.Duration, signingSalt string) (APIKey, string, error) {
+	id := strings.ToLower(shortID() + randToken(4))
+	id = strings.NewReplacer("-", "", "_", "").Replace(id)
+	secret := randToken(32)
+	k := FSAPIKey{
+		ID: id, BrandSlug: slug, Label: label, Prefix: "mk_" + id + "_" + secret[:4],
+		SecretHash: hashAPISecret(secret), Scopes: scopes, SigningSalt: signingSalt,
+		CreatedBy: strings.ToLower(by), CreatedAt: time.Now(),
+	}
+	if ttl > 0 {
+		k.ExpiresAt = k.CreatedAt.Add(ttl)
+	}
+	if _, err := fsDoc("apiKeys/"+id).Create(ctx, k); err != nil {
+		return APIKey{}, "", err
+	}
+	return apiKeyFromFS(k), "mk_" + id + "_" + secret, nil
+}
+
+func fsListAPIKeys(ctx context.Context, slug string) ([]APIKey, error) {
+	docs, err := fsCol("apiKeys").Where("brandSlug", "==", slug).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]APIKey, 0, len(docs))
+	for _, d := range docs {
+		var k FSAPIKey
+		if err := d.DataTo(&k); err != nil {
+			return nil, err
+		}
+		out = append(out, apiKeyFromFS(k))
+	}
+	return out, nil
+}
+
+func fsGetAPIKey(ctx context.Context, slug, id string) (FSAPIKey, bool, error) {
+	s, err := fsDoc("apiKeys/" + id).Get(ctx)
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "not found") {
+			return FSAPIKey{}, false, nil
+		}
+		return FSAPIKey{}, false, err
+	}
+	var k FSAPIKey
+	if err := s.DataTo(&k); err != nil {
+		return FSAPIKey{}, false, err
+	}
+	return k, k.BrandSlug == slug, nil
+}
+
+// ================== HTTP ==================
+
+// GET    /api/manufacturers/{slug}/api-keys       — список
+// POST   /api/manufacturers/{slug}/api-keys       — створити {label, scopes, expiresInDays}
+// POST   /api/manufacturers/{slug}/api-keys/{id}  — змінити підпис {label}
+// DELETE /api/manufacturers/{slug}/api-keys/{id}  — відкликати
+// POST   /api/manufacturers/{slug}/api-keys/{id}/signing — увімкнути або ротувати секрет підпису запитів
+// DELETE /api/manufacturers/{slug}/api-keys/{id}/signing — вимкнути підпис
+func brandAPIKeys(w http.ResponseWriter, r *http.Request, slug, id, action string) {
+	u := currentUser(r)
+	if u == "" {
+		writeJSON(w, 401, ErrorResp{"missing user"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	if !canBrand(ctx, u, slug, PermBrandKeys) {
+		writeJSON(w, 403, ErrorResp{"forbidden"})
+		return
+	}
+
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		list, err := fsListAPIKeys(ctx, slug)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		writeJSON(w, 200, list)
+
+	case id == "" && r.Method == http.MethodPost:
+		var body struct {
+			Label         string   `json:"label"`
+			Scopes        []string `json:"scopes"`
+			ExpiresInDays int      `json:"expiresInDays"`
+			Signed        bool     `json:"signed"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, 400, ErrorResp{"invalid json"})
+			return
+		}
+		var scopes []string
+		for _, s := range body.Scopes {
+			s = strings.ToLower(strings.TrimSpace(s))
+			if !slices.Contains(apiKeyScopes, s) {
+				writeJSON(w, 400, ErrorResp{"unknown scope " + s})
+				return
+			}
+			if !slices.Contains(scopes, s) {
+				scopes = append(scopes, s)
+			}
+		}
+		if len(scopes) == 0 {
+			writeJSON(w, 400, ErrorResp{"scopes required"})
+			return
+		}
+		if body.Signed && len(signingMaster) == 0 {
+			writeJSON(w, 400, ErrorResp{"request signing not configured"})
+			return
+		}
+		salt := ""
+		if body.Signed {
+			salt = randToken(12)
+		}
+		k, secret, err := fsCreateAPIKey(ctx, slug, strings.TrimSpace(body.Label), u, scopes,
+			time.Duration(body.ExpiresInDays)*24*time.Hour, salt)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		auditRecord(ctx, r, AuditAPIKeyCreate, "apikey:"+k.ID, nil,
+			map[string]any{"brand": slug, "scopes": scopes, "signed": salt != "", "expiresAt": k.ExpiresAt})
+		// секрети показуються один раз
+		out := map[string]any{"key": k, "secret": secret}
+		if salt != "" {
+			out["signingSecret"] = signingSecretFor(k.ID, salt)
+		}
+		writeJSON(w, 201, out)
+
+	case id != "" && action == "signing" && (r.Method == http.MethodPost || r.Method == http.MethodDelete):
+		k, ok, err := fsGetAPIKey(ctx, slug, id)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		if !ok || !k.RevokedAt.IsZero() {
+			writeJSON(w, 404, ErrorResp{"api key not found"})
+			return
+		}
+		if r.Method == http.MethodPost && len(signingMaster) == 0 {
+			writeJSON(w, 400, ErrorResp{"request signing not configured"})
+			return
+		}
+		wasSigned := k.SigningSalt != ""
+		k.SigningSalt = ""
+		if r.Method == http.MethodPost {
+			k.SigningSalt = randToken(12)
+		}
+		if _, err := fsDoc("apiKeys/"+id).Update(ctx, []firestore.Update{{Path: "signingSalt", Value: k.SigningSalt}}); err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		auditRecord(ctx, r, AuditAPIKeySigning, "apikey:"+id,
+			map[string]any{"signed": wasSigned}, map[string]any{"signed": k.SigningSalt != "", "rotated": wasSigned && k.SigningSalt != ""})
+		out := map[string]any{"key": apiKeyFromFS(k)}
+		if k.SigningSalt != "" {
+			out["signingSecret"] = signingSecretFor(k.ID, k.SigningSalt)
+		}
+		writeJSON(w, 200, out)
+
+	case id != "" && action == "" && (r.Method == http.MethodPost || r.Method == http.MethodDelete):
+		k, ok, err := fsGetAPIKey(ctx, slug, id)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		if !ok {
+			writeJSON(w, 404, ErrorResp{"api key not found"})
+			return
+		}
+		var up []firestore.Update
+		if r.Method == http.MethodDelete {
+			if !k.RevokedAt.IsZero() {
+				writeJSON(w, 409, ErrorResp{"already revoked"})
+				return
+			}
+			k.RevokedAt = time.Now()
+			up = append(up, firestore.Update{Path: "revokedAt", Value: k.RevokedAt})
+		} else {
+			var body struct {
+				Label string `json:"label"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				writeJSON(w, 400, ErrorResp{"invalid json"})
+				return
+			}
+			k.Label = strings.TrimSpace(body.Label)
+			up = append(up, firestore.Update{Path: "label", Value: k.Label})
+		}
+		if _, err := fsDoc("apiKeys/"+id).Update(ctx, up); err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		if r.Method == http.MethodDelete {
+			auditRecord(ctx, r, AuditAPIKeyRevoke, "apikey:"+id, map[string]any{"revoked": false}, map[string]any{"revoked": true})
+		}
+		writeJSON(w, 200, apiKeyFromFS(k))
+
+	default:
+		writeJSON(w, 405, ErrorResp{"Method not allowed"})
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+// ключ — окремий принципал з правами оператора лише свого бренду
+func TestAPIKeyPrincipal(t *testing.T) {
+	k := &apiKeyPrincipal{ID: "k1", BrandSlug: "acme", Scopes: []string{ScopeRead}}
+	r := httptest.NewRequest("GET", "/api/manufacturer/products", nil)
+	r = r.WithContext(context.WithValue(r.Context(), apiKeyCtxKey{}, k))
+	ctx := r.Context()
+
+	u := currentUser(r)
+	if u != "apikey:k1" || currentEmail(r) != "" {
+		t.Fatalf("principal = %q / %q", u, currentEmail(r))
+	}
+	if can(ctx, u, PermProductsReadAny) || can(ctx, u, PermBrandsVerify) {
+		t.Fatal("api key must not hold staff permissions")
+	}
+	if got := brandRole(ctx, "acme", u); got != RoleBrandOperator {
+		t.Fatalf("role in own brand = %q", got)
+	}
+	if !canBrand(ctx, u, "acme", PermBrandProducts) || !canBrand(ctx, u, "acme", PermBrandBatches) {
+		t.Fatal("api key lost operator rights in its brand")
+	}
+	if canBrand(ctx, u, "acme", PermBrandKeys) || canBrand(ctx, u, "acme", PermBrandMembers) {
+		t.Fatal("api key must not manage keys or members")
+	}
+	if canBrand(ctx, u, "other", PermBrandRead) || isBrandOwner(ctx, "other", u) {
+		t.Fatal("api key reaches another brand")
+	}
+}
+
+func TestAPIKeyScopeFor(t *testing.T) {
+	tests := []struct {
+		method, path, want string
+	}{
+		{"POST", "/api/manufacturer/products", ScopeMint},
+		{"GET", "/api/manufacturer/products", ScopeRead},
+		{"GET", "/api/verify/12", ScopeRead},
+		{"GET", "/api/products/12/qr.png", ScopeRead},
+		{"POST", "/api/products/12/qr.svg", ScopeBatch},
+		{"GET", "/api/manufacturer/batches", ScopeBatch},
+		{"POST", "/api/manufacturer/batches/b1/labels.pdf", ScopeBatch},
+		{"GET", "/api/manufacturer/offline-bundle", ScopeBatch},
+		// особисті маршрути власника ключам закриті
+		{"GET", "/api/products", ""},
+		{"POST", "/api/products/12/vc", ""},
+		{"POST", "/api/products/12/claim", ""},
+		{"GET", "/api/me", ""},
+		{"POST", "/api/manufacturers/acme/api-keys", ""},
+	}
+	for _, tt := range tests {
+		if got := apiKeyScopeFor(httptest.NewRequest(tt.method, tt.path, nil)); got != tt.want {
+			t.Errorf("%s %s: scope %q, want %q", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,563 @@
+// approvals.go — принцип чотирьох очей: чутливі операції персоналу (видача і
+// відкликання ролей, верифікація і поновлення бренду, масове відкликання продуктів)
+// спершу стають запитом на погодження і виконуються лише після схвалення
+// іншим адміном з тим самим дозволом у межах вікна FOUR_EYES_TTL.
+//
+// FOUR_EYES_OPS — список операцій через кому (за замовчуванням усі нижче);
+// "none" вимикає. Якщо іншого адміна з потрібним дозволом немає, операція
+// відхиляється з 409; FOUR_EYES_BOOTSTRAP=true дозволяє тоді виконати її
+// одразу (щоб перший адмін міг призначити другого).
+//
+// Погоджений запит стає "approved" і лише після виконання — "executed" або
+// "failed".
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+const (
+	AuditProductsRevoke  = "products.revoke"
+	AuditApprovalRequest = "approval.request"
+	AuditApprovalReject  = "approval.reject"
+)
+
+const (
+	ApprovalPending  = "pending"
+	ApprovalApproved = "approved"
+	ApprovalExecuted = "executed"
+	ApprovalFailed   = "failed"
+	ApprovalRejected = "rejected"
+	ApprovalExpired  = "expired"
+)
+
+// approvalOp — операція, яку можна відкласти до погодження
+type approvalOp struct {
+	Perm Perm
+	Run  func(ctx context.Context, r *http.Request, params map[string]string, a *Approval) (map[string]any, error)
+}
+
+var approvalOps = map[string]approvalOp{
+	AuditAdminGrant:     {PermAdminsManage, runAdminGrant},
+	AuditAdminRevoke:    {PermAdminsManage, runAdminRevoke},
+	AuditBrandVerify:    {PermBrandsVerify, runBrandVerify},
+	AuditBrandReinstate: {PermBrandsVerify, runBrandStatus(AuditBrandReinstate)},
+	AuditProductsRevoke: {PermBrandRevoke, runProductsRevoke},
+}
+
+var (
+	fourEyesOps = func() map[string]bool {
+		out := map[string]bool{}
+		raw := strings.TrimSpace(os.Getenv("FOUR_EYES_OPS"))
+		if strings.EqualFold(raw, "none") {
+			log.Println("[approvals] FOUR_EYES_OPS=none — sensitive operations run without a second approval")
+			return out
+		}
+		if raw == "" {
+			for op := range approvalOps {
+				out[op] = true
+			}
+			return out
+		}
+		for _, op := range strings.Split(raw, ",") {
+			op = strings.TrimSpace(op)
+			if _, ok := approvalOps[op]; !ok {
+				log.Printf("[approvals] FOUR_EYES_OPS: unknown operation %q\n", op)
+				continue
+			}
+			out[op] = true
+		}
+		return out
+	}()
+	// FOUR_EYES_TTL — скільки запит чекає на друге погодження
+	fourEyesTTL = envDuration("FOUR_EYES_TTL", 24*time.Hour)
+	// FOUR_EYES_BOOTSTRAP — без другого погоджувача виконувати одразу
+	fourEyesBootstrap = strings.EqualFold(strings.TrimSpace(os.Getenv("FOUR_EYES_BOOTSTRAP")), "true")
+)
+
+type Approval struct {
+	ID               string            `json:"id"`
+	Op               string            `json:"op"`
+	Target           string            `json:"target"`
+	Params           map[string]string `json:"params"`
+	RequestedBy      string            `json:"requestedBy"`
+	RequestedByEmail string            `json:"requestedByEmail,omitempty"`
+	Status           string            `json:"status"`
+	ApprovedBy       string            `json:"approvedBy,omitempty"`
+	Error            string            `json:"error,omitempty"`
+	CreatedAt        int64             `json:"createdAt"`
+	ExpiresAt        int64             `json:"expiresAt"`
+	DecidedAt        int64             `json:"decidedAt,omitempty"`
+}
+
+type FSApproval struct {
+	ID               string            `firestore:"id"`
+	Op               string            `firestore:"op"`
+	Target           string            `firestore:"target"`
+	Params           map[string]string `firestore:"params"`
+	RequestedBy      string            `firestore:"requestedBy"`
+	RequestedByEmail string            `firestore:"requestedByEmail"`
+	Status           string            `firestore:"status"`
+	ApprovedBy       string            `firestore:"approvedBy,omitempty"`
+	Error            string            `firestore:"error,omitempty"`
+	CreatedAt        time.Time         `firestore:"createdAt"`
+	ExpiresAt        time.Time         `firestore:"expiresAt"`
+	DecidedAt        time.Time         `firestore:"decidedAt,omitempty"`
+}
+
+func approvalFromFS(x FSApproval) Approval {
+	a := Approval{
+		ID: x.ID, Op: x.Op, Target: x.Target, Params: x.Params,
+		RequestedBy: x.RequestedBy, RequestedByEmail: x.RequestedByEmail,
+		Status: x.Status, ApprovedBy: x.ApprovedBy, Error: x.Error,
+		CreatedAt: x.CreatedAt.UnixMilli(), ExpiresAt: x.ExpiresAt.UnixMilli(),
+	}
+	if !x.DecidedAt.IsZero() {
+		a.DecidedAt = x.DecidedAt.UnixMilli()
+	}
+	// прострочені, але ще не закриті
+	if a.Status == ApprovalPending && time.Now().After(x.ExpiresAt) {
+		a.Status = ApprovalExpired
+	}
+	return a
+}
+
+func approvalDoc(id string) *firestore.DocumentRef { return fsDoc("approvals/" + id) }
+
+// opError — помилка операції з HTTP-кодом
+type opError struct {
+	Code int
+	Msg  string
+}
+
+func (e opError) Error() string { return e.Msg }
+
+func writeOpErr(w http.ResponseWriter, err error) {
+	var oe opError
+	switch {
+	case errors.As(err, &oe):
+		writeJSON(w, oe.Code, ErrorResp{oe.Msg})
+	case errors.Is(err, errLastSuperAdmin):
+		writeJSON(w, 409, ErrorResp{err.Error()})
+	default:
+		writeJSON(w, 500, ErrorResp{err.Error()})
+	}
+}
+
+// withApproval — у запис аудиту: хто ініціював операцію, яку погоджено
+func withApproval(after map[string]any, a *Approval) map[string]any {
+	if a != nil {
+		after["approval"] = a.ID
+		after["requestedBy"] = a.RequestedBy
+	}
+	return after
+}
+
+// ================== FLOW ==================
+
+// otherApprovers — чи є інший адмін із дозволом операції. Погодити може лише
+// той, хто проходить can(), тобто персонал; admins містить і видані вручну
+// ролі, і ролі з roleMap IdP (admins.go), API-ключі ролей персоналу не мають.
+func otherApprovers(ctx context.Context, perm Perm, uid string) (bool, error) {
+	admins, err := fsListAdmins(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, a := range admins {
+		if a.UserID != uid && roleHas(a.Role, perm) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// runOrPropose — виконати операцію одразу або створити запит на погодження (202)
+func runOrPropose(ctx context.Context, w http.ResponseWriter, r *http.Request, op, target string, params map[string]string) {
+	code, out, err := proposeOrRun(ctx, r, op, target, params)
+	if err != nil {
+		writeOpErr(w, err)
+		return
+	}
+	writeJSON(w, code, out)
+}
+
+// proposeOrRun — те саме без запису відповіді: 202 і запит на погодження або
+// 200 і результат операції (для обробників, що додають це до своєї відповіді)
+func proposeOrRun(ctx context.Context, r *http.Request, op, target string, params map[string]string) (int, map[string]any, error) {
+	u := currentUser(r)
+	spec := approvalOps[op]
+	if fourEyesOps[op] {
+		need, err := otherApprovers(ctx, spec.Perm, u)
+		if err != nil {
+			return 0, nil, err
+		}
+		if !need && !fourEyesBootstrap {
+			return 0, nil, opError{409, "no other admin can approve " + op + "; grant one or set FOUR_EYES_BOOTSTRAP=true"}
+		}
+		if need {
+			now := time.Now()
+			x := FSApproval{
+				ID: strings.ToLower(shortID()) + randToken(6), Op: op, Target: target, Params: params,
+				RequestedBy: u, RequestedByEmail: currentEmail(r), Status: ApprovalPending,
+				CreatedAt: now, ExpiresAt: now.Add(fourEyesTTL),
+			}
+			if _, err := approvalDoc(x.ID).Create(ctx, x); err != nil {
+				return 0, nil, err
+			}
+			auditRecord(ctx, r, AuditApprovalRequest, target, nil, map[string]any{"approval": x.ID, "op": op, "params": params})
+			return 202, map[string]any{"ok": true, "pending": true, "approval": approvalFromFS(x)}, nil
+		}
+	}
+	out, err := spec.Run(ctx, r, params, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	return 200, out, nil
+}
+
+// fsDecideApproval — переводить запит з pending у рішення; погоджує лише
+// інший адмін, ніж ініціатор, і лише до закінчення вікна
+func fsDecideApproval(ctx context.Context, id, by string, approve bool) (FSApproval, error) {
+	var out FSApproval
+	err := fsClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		s, err := tx.Get(approvalDoc(id))
+		if err != nil {
+			if strings.Contains(strings.ToLower(err.Error()), "not found") {
+				return opError{404, "approval not found"}
+			}
+			return err
+		}
+		if err := s.DataTo(&out); err != nil {
+			return err
+		}
+		if out.Status != ApprovalPending {
+			return opError{409, "approval already " + out.Status}
+		}
+		now := time.Now()
+		if now.After(out.ExpiresAt) {
+			out.Status = ApprovalExpired
+			return tx.Update(s.Ref, []firestore.Update{{Path: "status", Value: out.Status}, {Path: "decidedAt", Value: now}})
+		}
+		// ініціатор може лише скасувати свій запит
+		if approve && out.RequestedBy == by {
+			return opError{403, "a different admin must approve"}
+		}
+		// executed/failed — лише після виконання (adminApprovals)
+		out.Status, out.ApprovedBy, out.DecidedAt = ApprovalApproved, by, now
+		if !approve {
+			out.Status = ApprovalRejected
+		}
+		return tx.Update(s.Ref, []firestore.Update{
+			{Path: "status", Value: out.Status},
+			{Path: "approvedBy", Value: by},
+			{Path: "decidedAt", Value: now},
+		})
+	})
+	if err == nil && out.Status == ApprovalExpired {
+		err = opError{409, "approval expired"}
+	}
+	return out, err
+}
+
+func fsListApprovals(ctx context.Context, status string) ([]Approval, error) {
+	q := fsCol("approvals").Query
+	if status != "" {
+		q = q.Where("status", "==", status)
+	}
+	it := q.OrderBy("createdAt", firestore.Desc).Limit(200).Documents(ctx)
+	defer it.Stop()
+	out := []Approval{}
+	for {
+		d, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var x FSApproval
+		if err := d.DataTo(&x); err != nil {
+			return nil, err
+		}
+		out = append(out, approvalFromFS(x))
+	}
+	return out, nil
+}
+
+// ================== HTTP ==================
+
+// GET  /api/admins/approvals[?status=pending|approved|executed|failed|rejected|expired|all]
+// POST /api/admins/approvals/{id}/approve|reject
+func adminApprovals(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		returnOK(w)
+		return
+	}
+	u := currentUser(r)
+	if u == "" {
+		writeJSON(w, 401, ErrorResp{"missing user"})
+		return
+	}
+	// масове відкликання може тривати довго
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
+	defer cancel()
+
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admins/approvals"), "/")
+	parts := strings.Split(rest, "/")
+	switch {
+	case rest == "" && r.Method == http.MethodGet:
+		status := strings.TrimSpace(r.URL.Query().Get("status"))
+		switch status {
+		case "":
+			status = ApprovalPending
+		case "all":
+			status = ""
+		}
+		list, err := fsListApprovals(ctx, status)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		// лише операції, які користувач може погодити
+		out := []Approval{}
+		for _, a := range list {
+			if can(ctx, u, approvalOps[a.Op].Perm) {
+				out = append(out, a)
+			}
+		}
+		writeJSON(w, 200, out)
+
+	case len(parts) == 2 && (parts[1] == "approve" || parts[1] == "reject") && r.Method == http.MethodPost:
+		s, err := approvalDoc(parts[0]).Get(ctx)
+		if err != nil {
+			writeJSON(w, 404, ErrorResp{"approval not found"})
+			return
+		}
+		spec, ok := approvalOps[asString(s.Data()["op"])]
+		if !ok || !can(ctx, u, spec.Perm) {
+			writeJSON(w, 403, ErrorResp{"forbidden"})
+			return
+		}
+		approve := parts[1] == "approve"
+		x, err := fsDecideApproval(ctx, parts[0], u, approve)
+		if err != nil {
+			writeOpErr(w, err)
+			return
+		}
+		a := approvalFromFS(x)
+		if !approve {
+			auditRecord(ctx, r, AuditApprovalReject, a.Target, nil, map[string]any{"approval": a.ID, "op": a.Op})
+			writeJSON(w, 200, map[string]any{"ok": true, "approval": a})
+			return
+		}
+		out, err := spec.Run(ctx, r, a.Params, &a)
+		upd := []firestore.Update{{Path: "status", Value: ApprovalExecuted}}
+		a.Status = ApprovalExecuted
+		if err != nil {
+			a.Status, a.Error = ApprovalFailed, err.Error()
+			upd = []firestore.Update{{Path: "status", Value: a.Status}, {Path: "error", Value: a.Error}}
+		}
+		// окремий контекст: результат треба записати, навіть якщо запит уже скасовано
+		uctx, ucancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		if _, uerr := approvalDoc(a.ID).Update(uctx, upd); uerr != nil {
+			log.Printf("[approvals] %s: status %s not saved: %v\n", a.ID, a.Status, uerr)
+		}
+		ucancel()
+		if err != nil {
+			writeOpErr(w, err)
+			return
+		}
+		out["approval"] = a
+		writeJSON(w, 200, out)
+
+	default:
+		writeJSON(w, 405, ErrorResp{"Method not allowed"})
+	}
+}
+
+// ================== OPERATIONS ==================
+
+func runAdminGrant(ctx context.Context, r *http.Request, p map[string]string, a *Approval) (map[string]any, error) {
+	user, ok, err := fsGetUser(ctx, p["userId"])
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, opError{404, "user not found"}
+	}
+	role := Role(p["role"])
+	rec, prev, err := fsGrantAdmin(ctx, user, role, currentUser(r))
+	if err != nil {
+		return nil, err
+	}
+	var before any
+	if prev != "" {
+		before = map[string]any{"role": prev}
+	}
+	auditRecord(ctx, r, AuditAdminGrant, auditUser(user.ID), before,
+		withApproval(map[string]any{"role": role, "email": user.Email}, a))
+	return map[string]any{"ok": true, "role": role, "admin": rec}, nil
+}
+
+func runAdminRevoke(ctx context.Context, r *http.Request, p map[string]string, a *Approval) (map[string]any, error) {
+	uid := p["userId"]
+	prev, found, err := fsRevokeAdmin(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, opError{404, "admin not found"}
+	}
+	auditRecord(ctx, r, AuditAdminRevoke, auditUser(uid), map[string]any{"role": prev}, withApproval(map[string]any{}, a))
+	return map[string]any{"ok": true, "revoked": uid}, nil
+}
+
+func runBrandVerify(ctx context.Context, r *http.Request, p map[string]string, a *Approval) (map[string]any, error) {
+	slug, u := p["slug"], currentUser(r)
+	prev, err := fsGetBrand(ctx, slug)
+	if err != nil {
+		return nil, opError{404, "manufacturer not found"}
+	}
+	m, err := fsVerifyBrand(ctx, slug, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	auditRecord(ctx, r, AuditBrandVerify, auditBrand(slug),
+		map[string]any{"verified": prev.Verified, "verifiedBy": prev.VerifiedBy},
+		withApproval(map[string]any{"verified": m.Verified, "verifiedBy": m.VerifiedBy}, a))
+	return map[string]any{"ok": true, "manufacturer": m}, nil
+}
+
+// runProductsRevoke — відкликати всі невідкликані продукти бренду або партії
+func runProductsRevoke(ctx context.Context, r *http.Request, p map[string]string, a *Approval) (map[string]any, error) {
+	q := fsCol("products").Query
+	if p["brandSlug"] != "" {
+		q = q.Where("brandSlug", "==", p["brandSlug"])
+	}
+	if p["batchId"] != "" {
+		q = q.Where("batchId", "==", p["batchId"])
+	}
+	u := currentUser(r)
+	var refs []*firestore.DocumentRef
+	it := q.Documents(ctx)
+	defer it.Stop()
+	for {
+		d, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var fp FSProduct
+		if err := d.DataTo(&fp); err != nil || fp.State == string(StateRevoked) {
+			continue
+		}
+		refs = append(refs, d.Ref)
+	}
+	// транзакція Firestore — до 500 записів: порція продуктів + стільки ж
+	// записів журналу + голова; стан і журнал комітяться разом
+	revoked, failed := 0, 0
+	for len(refs) > 0 {
+		chunk := refs[:min(len(refs), 200)]
+		refs = refs[len(chunk):]
+		n := 0
+		err := fsClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+			n = 0
+			snaps, err := tx.GetAll(chunk)
+			if err != nil {
+				return err
+			}
+			head, err := ledgerReadHead(tx)
+			if err != nil {
+				return err
+			}
+			var events []LedgerEntry
+			for _, s := range snaps {
+				var fp FSProduct
+				if !s.Exists() || s.DataTo(&fp) != nil || fp.State == string(StateRevoked) {
+					continue
+				}
+				if err := tx.Update(s.Ref, []firestore.Update{{Path: "state", Value: string(StateRevoked)}}); err != nil {
+					return err
+				}
+				events = append(events, LedgerEntry{
+					Kind: LedgerRevoke, TokenID: fp.TokenID, BrandSlug: fp.BrandSlug, Actor: u,
+					Data: map[string]string{"reason": p["reason"], "bulk": "true"},
+				})
+			}
+			n = len(events)
+			_, err = head.append(tx, events...)
+			return err
+		})
+		if err != nil {
+			log.Printf("[approvals] revoke chunk of %d: %v\n", len(chunk), err)
+			failed += len(chunk)
+			continue
+		}
+		revoked += n
+	}
+	target := auditBrand(p["brandSlug"])
+	if p["batchId"] != "" {
+		target = "batch:" + p["batchId"]
+	}
+	auditRecord(ctx, r, AuditProductsRevoke, target, nil,
+		withApproval(map[string]any{"revoked": revoked, "brandSlug": p["brandSlug"], "batchId": p["batchId"], "reason": p["reason"]}, a))
+	if failed > 0 {
+		return nil, fmt.Errorf("revoked %d products, %d failed", revoked, failed)
+	}
+	return map[string]any{"ok": true, "revoked": revoked}, nil
+}
+
+// POST /api/admins/revoke-products {brandSlug?, batchId?, reason}
+func adminRevokeProducts(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		returnOK(w)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeJSON(w, 405, ErrorResp{"Method not allowed"})
+		return
+	}
+	var body struct {
+		BrandSlug string `json:"brandSlug"`
+		BatchID   string `json:"batchId"`
+		Reason    string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, 400, ErrorResp{"invalid json"})
+		return
+	}
+	params := map[string]string{
+		"brandSlug": strings.TrimSpace(body.BrandSlug),
+		"batchId":   strings.TrimSpace(body.BatchID),
+		"reason":    strings.TrimSpace(body.Reason),
+	}
+	if params["brandSlug"] == "" && params["batchId"] == "" {
+		writeJSON(w, 400, ErrorResp{"brandSlug or batchId required"})
+		return
+	}
+	if params["reason"] == "" {
+		writeJSON(w, 400, ErrorResp{"reason required"})
+		return
+	}
+	target := auditBrand(params["brandSlug"])
+	if params["batchId"] != "" {
+		target = "batch:" + params["batchId"]
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
+	defer cancel()
+	runOrPropose(ctx, w, r, AuditProductsRevoke, target, params)
+}
@@ -0,0 +1,382 @@
+// audit.go — незмінний журнал адміністративних і привілейованих дій: хто
+// (actor), що (action), над чим (target), значення до/після, IP клієнта та
+// ID запиту. Записи лише додаються і зшиті хешами, як ledger.go; пошук за
+// actor/target/action/датами і CSV-експорт — /api/admins/audit.
+
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+const (
+	AuditAdminGrant     = "admin.grant"
+	AuditAdminRevoke    = "admin.revoke"
+	AuditAdminBootstrap = "admin.bootstrap"
+	AuditBrandCreate    = "brand.create"
+	AuditBrandVerify    = "brand.verify"
+	AuditAppApprove     = "application.approve"
+	AuditAppReject      = "application.reject"
+	AuditAPIKeyCreate   = "apikey.create"
+	AuditAPIKeyRevoke   = "apikey.revoke"
+	AuditAPIKeySigning  = "apikey.signing"
+	AuditUsersMigrate   = "users.migrate"
+	AuditUserLink       = "user.link"
+)
+
+const (
+	auditDefLimit = 200
+	auditMaxLimit = 5000
+)
+
+type AuditEntry struct {
+	Seq        int64           `json:"seq"`
+	Action     string          `json:"action"`
+	Actor      string          `json:"actor"`
+	ActorEmail string          `json:"actorEmail,omitempty"`
+	Target     string          `json:"target"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	IP         string          `json:"ip,omitempty"`
+	RequestID  string          `json:"requestId,omitempty"`
+	At         int64           `json:"at"`
+	PrevHash   string          `json:"prevHash"`
+	Hash       string          `json:"hash"`
+}
+
+// Before/After — JSON-рядки: хеш рахується від того ж, що зберігається
+type FSAuditEntry struct {
+	Seq        int64  `firestore:"seq"`
+	Action     string `firestore:"action"`
+	Actor      string `firestore:"actor"`
+	ActorEmail string `firestore:"actorEmail"`
+	Target     string `firestore:"target"`
+	Before     string `firestore:"before"`
+	After      string `firestore:"after"`
+	IP         string `firestore:"ip"`
+	RequestID  string `firestore:"requestId"`
+	At         int64  `firestore:"at"`
+	PrevHash   string `firestore:"prevHash"`
+	Hash       string `firestore:"hash"`
+}
+
+func auditFromFS(x FSAuditEntry) AuditEntry {
+	e := AuditEntry{
+		Seq: x.Seq, Action: x.Action, Actor: x.Actor, ActorEmail: x.ActorEmail, Target: x.Target,
+		IP: x.IP, RequestID: x.RequestID, At: x.At, PrevHash: x.PrevHash, Hash: x.Hash,
+	}
+	if x.Before != "" {
+		e.Before = json.RawMessage(x.Before)
+	}
+	if x.After != "" {
+		e.After = json.RawMessage(x.After)
+	}
+	return e
+}
+
+func auditHash(x FSAuditEntry) string {
+	x.Hash = ""
+	return sha256Hex(string(mustJSON(x)))
+}
+
+// цілі записів: user:{id}, brand:{slug}, application:{id}, apikey:{id}
+func auditUser(uid string) string   { return "user:" + uid }
+func auditBrand(slug string) string { return "brand:" + slug }
+
+// ================== REQUEST ID ==================
+
+type requestIDCtxKey struct{}
+
+var requestIDRe = regexp.MustCompile(`^[A-Za-z0-9._\-]{1,128}$`)
+
+// withRequestID — X-Request-ID від проксі або новий; повертається у відповіді
+func withRequestID(w http.ResponseWriter, r *http.Request) *http.Request {
+	if requestIDFromContext(r.Context()) != "" {
+		return r
+	}
+	id := strings.TrimSpace(r.Header.Get("X-Request-ID"))
+	if !requestIDRe.MatchString(id) {
+		id = randToken(12)
+	}
+	w.Header().Set("X-Request-ID", id)
+	return r.WithContext(context.WithValue(r.Context(), requestIDCtxKey{}, id))
+}
+
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDCtxKey{}).(string)
+	return id
+}
+
+// ================== APPEND ==================
+
+func auditAppend(ctx context.Context, x FSAuditEntry) error {
+	if !fsEnabled {
+		return nil
+	}
+	head := fsDoc("meta/audit")
+	return fsClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		seq, prev := int64(0), ledgerGenesis
+		snap, err := tx.Get(head)
+		if err != nil && !strings.Contains(strings.ToLower(err.Error()), "not found") {
+			return err
+		}
+		if err == nil {
+			seq, _ = snap.Data()["seq"].(int64)
+			if s := asString(snap.Data()["head"]); s != "" {
+				prev = s
+			}
+		}
+		x.Seq = seq + 1
+		x.At = time.Now().UnixMilli()
+		x.PrevHash = prev
+		x.Hash = auditHash(x)
+		if err := tx.Create(fsDoc("audit/"+ledgerDocID(x.Seq)), x); err != nil {
+			return err
+		}
+		return tx.Set(head, map[string]any{"seq": x.Seq, "head": x.Hash, "updatedAt": time.Now()})
+	})
+}
+
+// auditRecord — для хендлерів: actor, IP і ID запиту беруться з r; помилка
+// аудиту не скасовує вже виконану дію (на відміну від ledger)
+func auditRecord(ctx context.Context, r *http.Request, action, target string, before, after any) {
+	x := FSAuditEntry{Action: action, Target: target, Before: auditJSON(before), After: auditJSON(after)}
+	if r != nil {
+		x.Actor, x.ActorEmail = currentUser(r), currentEmail(r)
+		x.IP, x.RequestID = clientIP(r), requestIDFromContext(r.Context())
+	} else {
+		x.Actor = "cli"
+	}
+	if err := auditAppend(ctx, x); err != nil {
+		log.Printf("[audit] %s %s: %v\n", action, target, err)
+	}
+}
+
+func auditJSON(v any) string {
+	if v == nil {
+		return ""
+	}
+	return string(mustJSON(v))
+}
+
+// ================== QUERY ==================
+
+type auditFilter struct {
+	Actor, Target, Action string
+	From, To              time.Time
+	Limit                 int
+}
+
+// fsQueryAudit — нові спершу; комбінації фільтрів з датами потребують
+// складених індексів Firestore (actor|target|action + at desc)
+func fsQueryAudit(ctx context.Context, f auditFilter) ([]AuditEntry, error) {
+	q := fsCol("audit").Query
+	if f.Actor != "" {
+		q = q.Where("actor", "==", f.Actor)
+	}
+	if f.Target != "" {
+		q = q.Where("target", "==", f.Target)
+	}
+	if f.Action != "" {
+		q = q.Where("action", "==", f.Action)
+	}
+	if !f.From.IsZero() {
+		q = q.Where("at", ">=", f.From.UnixMilli())
+	}
+	if !f.To.IsZero() {
+		q = q.Where("at", "<", f.To.UnixMilli())
+	}
+	it := q.OrderBy("at", firestore.Desc).Limit(f.Limit).Documents(ctx)
+	defer it.Stop()
+	var out []AuditEntry
+	for {
+		d, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var x FSAuditEntry
+		if err := d.DataTo(&x); err != nil {
+			return nil, err
+		}
+		out = append(out, auditFromFS(x))
+	}
+	return out, nil
+}
+
+// auditVerifyChain — як ledgerVerifyChain: (seq, причина) першого розриву
+func auditVerifyChain(ctx context.Context) (int64, string, error) {
+	it := fsCol("audit").OrderBy("seq", firestore.Asc).Documents(ctx)
+	defer it.Stop()
+	prev, want := ledgerGenesis, int64(1)
+	for {
+		d, err := it.Next()
+		if err == iterator.Done {
+			return 0, "", nil
+		}
+		if err != nil {
+			return 0, "", err
+		}
+		var x FSAuditEntry
+		if err := d.DataTo(&x); err != nil {
+			return 0, "", err
+		}
+		if seq, why := auditCheckLink(x, want, prev); why != "" {
+			return seq, why, nil
+		}
+		prev, want = x.Hash, want+1
+	}
+}
+
+// auditCheckLink — чи є x наступною ланкою після prev з номером want
+func auditCheckLink(x FSAuditEntry, want int64, prev string) (int64, string) {
+	switch {
+	case x.Seq != want:
+		return want, "missing entry"
+	case x.PrevHash != prev:
+		return x.Seq, "prevHash mismatch"
+	case auditHash(x) != x.Hash:
+		return x.Seq, "hash mismatch"
+	}
+	return 0, ""
+}
+
+// parseAuditTime — RFC3339, YYYY-MM-DD або unix ms; дата в "to" включає весь день
+func parseAuditTime(s string, end bool) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		if end {
+			t = t.Add(24 * time.Hour)
+		}
+		return t, nil
+	}
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	return time.Time{}, fmt.Errorf("bad date %q", s)
+}
+
+// auditSubject — email у фільтрі actor/target перекладається на ID користувача
+func auditSubject(ctx context.Context, s string, target bool) (string, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "@") || strings.Contains(s, ":") {
+		return s, nil
+	}
+	u, ok, err := fsUserByEmail(ctx, s)
+	if errors.Is(err, errAmbiguousEmail) {
+		return "", opError{409, err.Error()}
+	}
+	if err != nil || !ok {
+		return "-", err // невідомий email — порожній результат
+	}
+	if target {
+		return auditUser(u.ID), nil
+	}
+	return u.ID, nil
+}
+
+// ================== HTTP ==================
+
+// GET /api/admins/audit?actor=&target=&action=&from=&to=&limit=&format=csv
+// GET /api/admins/audit/verify
+func adminAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		returnOK(w)
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeJSON(w, 405, ErrorResp{"Method not allowed"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	if strings.TrimSuffix(r.URL.Path, "/") == "/api/admins/audit/verify" {
+		bad, reason, err := auditVerifyChain(ctx)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		if bad != 0 {
+			writeJSON(w, 200, map[string]any{"ok": false, "brokenAt": bad, "reason": reason})
+			return
+		}
+		writeJSON(w, 200, map[string]any{"ok": true})
+		return
+	}
+
+	q := r.URL.Query()
+	f := auditFilter{Action: strings.TrimSpace(q.Get("action")), Limit: auditDefLimit}
+	var err error
+	if f.From, err = parseAuditTime(q.Get("from"), false); err != nil {
+		writeJSON(w, 400, ErrorResp{err.Error()})
+		return
+	}
+	if f.To, err = parseAuditTime(q.Get("to"), true); err != nil {
+		writeJSON(w, 400, ErrorResp{err.Error()})
+		return
+	}
+	if n, err := strconv.Atoi(q.Get("limit")); err == nil && n > 0 {
+		f.Limit = min(n, auditMaxLimit)
+	}
+	if f.Actor, err = auditSubject(ctx, q.Get("actor"), false); err != nil {
+		writeOpErr(w, err)
+		return
+	}
+	if f.Target, err = auditSubject(ctx, q.Get("target"), true); err != nil {
+		writeOpErr(w, err)
+		return
+	}
+	list, err := fsQueryAudit(ctx, f)
+	if err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+	if q.Get("format") != "csv" {
+		writeJSON(w, 200, list)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-%s.csv"`, time.Now().UTC().Format("20060102-150405")))
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"seq", "at", "action", "actor", "actorEmail", "target", "before", "after", "ip", "requestId", "prevHash", "hash"})
+	for _, e := range list {
+		_ = cw.Write([]string{
+			strconv.FormatInt(e.Seq, 10), time.UnixMilli(e.At).UTC().Format(time.RFC3339),
+			csvSafe(e.Action), csvSafe(e.Actor), csvSafe(e.ActorEmail), csvSafe(e.Target),
+			csvSafe(string(e.Before)), csvSafe(string(e.After)),
+			csvSafe(e.IP), csvSafe(e.RequestID), e.PrevHash, e.Hash,
+		})
+	}
+	cw.Flush()
+}
+
+// csvSafe — не даємо табличним редакторам виконати значення як формулу
+func csvSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func auditLink(seq int64, prev, action string) FSAuditEntry {
+	x := FSAuditEntry{Seq: seq, Action: action, Actor: "user:u1", Target: "brand:acme", At: 1700000000000 + seq, PrevHash: prev}
+	x.Hash = auditHash(x)
+	return x
+}
+
+func TestAuditHash(t *testing.T) {
+	x := auditLink(1, ledgerGenesis, AuditBrandVerify)
+	if x.Hash != auditHash(x) {
+		t.Fatal("hash is not stable")
+	}
+	stale := x
+	stale.Hash = "deadbeef"
+	if auditHash(stale) != x.Hash {
+		t.Error("stored hash must not feed into the hash")
+	}
+	edits := map[string]func(*FSAuditEntry){
+		"seq":      func(e *FSAuditEntry) { e.Seq++ },
+		"action":   func(e *FSAuditEntry) { e.Action = AuditBrandCreate },
+		"actor":    func(e *FSAuditEntry) { e.Actor = "user:u2" },
+		"target":   func(e *FSAuditEntry) { e.Target = "brand:other" },
+		"after":    func(e *FSAuditEntry) { e.After = `{"verified":true}` },
+		"at":       func(e *FSAuditEntry) { e.At++ },
+		"prevHash": func(e *FSAuditEntry) { e.PrevHash = "00" },
+	}
+	for field, edit := range edits {
+		y := x
+		edit(&y)
+		if auditHash(y) == x.Hash {
+			t.Errorf("%s is not covered by the hash", field)
+		}
+	}
+}
+
+func TestAuditCheckLink(t *testing.T) {
+	first := auditLink(1, ledgerGenesis, AuditBrandVerify)
+	second := auditLink(2, first.Hash, AuditBrandCreate)
+	forged := second
+	forged.Actor = "user:u2"
+	tests := []struct {
+		name    string
+		x       FSAuditEntry
+		want    int64
+		prev    string
+		wantSeq int64
+		wantWhy string
+	}{
+		{"first after genesis", first, 1, ledgerGenesis, 0, ""},
+		{"second", second, 2, first.Hash, 0, ""},
+		{"gap", second, 1, ledgerGenesis, 1, "missing entry"},
+		{"wrong prev", second, 2, ledgerGenesis, 2, "prevHash mismatch"},
+		{"edited entry", forged, 2, first.Hash, 2, "hash mismatch"},
+	}
+	for _, tt := range tests {
+		seq, why := auditCheckLink(tt.x, tt.want, tt.prev)
+		if seq != tt.wantSeq || why != tt.wantWhy {
+			t.Errorf("%s: (%d, %q), want (%d, %q)", tt.name, seq, why, tt.wantSeq, tt.wantWhy)
+		}
+	}
+}
+
+func TestCSVSafe(t *testing.T) {
+	tests := map[string]string{
+		"":                      "",
+		"user:u1":               "user:u1",
+		"=HYPERLINK(\"x\")":     "'=HYPERLINK(\"x\")",
+		"+1+1":                  "'+1+1",
+		"-2+3":                  "'-2+3",
+		"@SUM(A1)":              "'@SUM(A1)",
+		"\t=1":                  "'\t=1",
+		"\r=1":                  "'\r=1",
+		"a=1":                   "a=1",
+		"brand.verify":          "brand.verify",
+		"ops@marki.example":     "ops@marki.example",
+		`{"name":"=cmd|' /C'"}`: `{"name":"=cmd|' /C'"}`,
+	}
+	for in, want := range tests {
+		if got := csvSafe(in); got != want {
+			t.Errorf("csvSafe(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParseAuditTime(t *testing.T) {
+	day := time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		end  bool
+		want time.Time
+	}{
+		{"", false, time.Time{}},
+		{"", true, time.Time{}},
+		{"2026-03-14", false, day},
+		// "to" з датою включає весь день: межа — наступна північ (запит at < to)
+		{"2026-03-14", true, day.Add(24 * time.Hour)},
+		{" 2026-03-14 ", true, day.Add(24 * time.Hour)},
+		{"2026-03-14T10:30:00Z", false, day.Add(10*time.Hour + 30*time.Minute)},
+		{"2026-03-14T10:30:00Z", true, day.Add(10*time.Hour + 30*time.Minute)},
+		{"1773446400000", true, time.UnixMilli(1773446400000)},
+	}
+	for _, tt := range tests {
+		got, err := parseAuditTime(tt.in, tt.end)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseAuditTime(%q, %v) = %v, %v; want %v", tt.in, tt.end, got, err, tt.want)
+		}
+	}
+	last := day.Add(24*time.Hour - time.Millisecond).UnixMilli()
+	if to, _ := parseAuditTime("2026-03-14", true); !(last < to.UnixMilli()) {
+		t.Error("last millisecond of the day is excluded")
+	}
+	for _, bad := range []string{"14.03.2026", "2026-13-01", "yesterday"} {
+		if _, err := parseAuditTime(bad, false); err == nil {
+			t.Errorf("parseAuditTime(%q) accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// brandnames.go — зарезервовані назви брендів: адміни ведуть список відомих
+// марок, щоб звичайний користувач не зайняв "NIKE" (чи "NIKE-STORE") через
+// /api/manufacturers. Такий бренд створює лише персонал — після заявки компанії
+// або напряму через /api/admins/create-manufacturer.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+const (
+	AuditReservedAdd    = "reserved.add"
+	AuditReservedRemove = "reserved.remove"
+)
+
+type ReservedName struct {
+	Slug      string `json:"slug"`
+	Name      string `json:"name"`
+	Note      string `json:"note,omitempty"`
+	CreatedBy string `json:"createdBy"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+type FSReservedName struct {
+	Slug      string    `firestore:"slug"`
+	Name      string    `firestore:"name"`
+	Note      string    `firestore:"note,omitempty"`
+	CreatedBy string    `firestore:"createdBy"`
+	CreatedAt time.Time `firestore:"createdAt"`
+}
+
+func reservedDoc(slug string) *firestore.DocumentRef {
+	return fsDoc("reservedBrandNames/" + slug)
+}
+
+func reservedFromFS(x FSReservedName) ReservedName {
+	return ReservedName{
+		Slug: x.Slug, Name: x.Name, Note: x.Note,
+		CreatedBy: x.CreatedBy, CreatedAt: x.CreatedAt.UnixMilli(),
+	}
+}
+
+// reservedPrefixes — "NIKE-STORE-UA" → NIKE, NIKE-STORE, NIKE-STORE-UA
+func reservedPrefixes(slug string) []string {
+	parts := strings.Split(slug, "-")
+	out := make([]string, 0, len(parts))
+	for i := range parts {
+		out = append(out, strings.Join(parts[:i+1], "-"))
+	}
+	return out
+}
+
+// fsReservedFor — зарезервована назва, що покриває slug (сама назва або її
+// префікс до дефіса)
+func fsReservedFor(ctx context.Context, slug string) (ReservedName, bool, error) {
+	var refs []*firestore.DocumentRef
+	for _, p := range reservedPrefixes(slug) {
+		refs = append(refs, reservedDoc(p))
+	}
+	snaps, err := fsClient.GetAll(ctx, refs)
+	if err != nil {
+		return ReservedName{}, false, err
+	}
+	for _, s := range snaps {
+		if !s.Exists() {
+			continue
+		}
+		var x FSReservedName
+		if err := s.DataTo(&x); err != nil {
+			return ReservedName{}, false, err
+		}
+		return reservedFromFS(x), true, nil
+	}
+	return ReservedName{}, false, nil
+}
+
+func fsListReserved(ctx context.Context) ([]ReservedName, error) {
+	docs, err := fsCol("reservedBrandNames").Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	out := []ReservedName{}
+	for _, d := range docs {
+		var x FSReservedName
+		if err := d.DataTo(&x); err != nil {
+			return nil, err
+		}
+		out = append(out, reservedFromFS(x))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Slug < out[j].Slug })
+	return out, nil
+}
+
+// ================== HANDLER (/api/admins/reserved-names) ==================
+
+func adminReservedNames(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		returnOK(w)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+
+	slug := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admins/reserved-names"), "/")
+	switch {
+	case slug == "" && r.Method == http.MethodGet:
+		list, err := fsListReserved(ctx)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		writeJSON(w, 200, list)
+
+	case slug == "" && r.Method == http.MethodPost:
+		var body struct {
+			Name string `json:"name"`
+			Note string `json:"note"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, 400, ErrorResp{"invalid json"})
+			return
+		}
+		name := strings.TrimSpace(body.Name)
+		if name == "" {
+			writeJSON(w, 400, ErrorResp{"name is required"})
+			return
+		}
+		x := FSReservedName{
+			Slug: slugify(name), Name: name, Note: strings.TrimSpace(body.Note),
+			CreatedBy: currentUser(r), CreatedAt: time.Now(),
+		}
+		if _, err := reservedDoc(x.Slug).Create(ctx, x); err != nil {
+			if strings.Contains(strings.ToLower(err.Error()), "already exists") {
+				writeJSON(w, 409, ErrorResp{"name already reserved"})
+				return
+			}
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		rn := reservedFromFS(x)
+		auditRecord(ctx, r, AuditReservedAdd, "reserved:"+rn.Slug, nil, map[string]any{"name": rn.Name, "note": rn.Note})
+		writeJSON(w, 201, rn)
+
+	case slug != "" && r.Method == http.MethodDelete:
+		slug = slugify(slug)
+		s, err := reservedDoc(slug).Get(ctx)
+		if err != nil {
+			if strings.Contains(strings.ToLower(err.Error()), "not found") {
+				writeJSON(w, 404, ErrorResp{"reserved name not found"})
+				return
+			}
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		if _, err := s.Ref.Delete(ctx); err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		auditRecord(ctx, r, AuditReservedRemove, "reserved:"+slug, map[string]any{"name": asString(s.Data()["name"])}, nil)
+		writeJSON(w, 200, map[string]any{"ok": true})
+
+	default:
+		writeJSON(w, 405, ErrorResp{"Method not allowed"})
+	}
+}
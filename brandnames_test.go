@@ -0,0 +1,19 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestReservedPrefixes(t *testing.T) {
+	tests := map[string][]string{
+		"NIKE-STORE-UA": {"NIKE", "NIKE-STORE", "NIKE-STORE-UA"},
+		"NIKESTORE":     {"NIKESTORE"},
+		"NIKE-UA":       {"NIKE", "NIKE-UA"},
+	}
+	for in, want := range tests {
+		if got := reservedPrefixes(in); !slices.Equal(got, want) {
+			t.Errorf("reservedPrefixes(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
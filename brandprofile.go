@@ -0,0 +1,170 @@
+// brandprofile.go — публічний профіль бренду: лого, опис, сайт, країна,
+// підтримка і соцмережі. Редагує власник бренду (POST
+// /api/manufacturers/{slug}/profile); GET /api/manufacturers/{slug} і відповіді
+// перевірки віддають профіль без даних власника.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+const AuditBrandProfile = "brand.profile"
+
+const brandDescMax = 2000
+
+// соцмережі, які показуємо на сторінці продукту
+var brandSocials = []string{"instagram", "facebook", "x", "tiktok", "youtube", "linkedin", "telegram"}
+
+var (
+	countryRe = regexp.MustCompile(`^[A-Z]{2}$`)
+	phoneRe   = regexp.MustCompile(`^\+?[0-9 ()-]{5,32}$`)
+)
+
+type BrandProfile struct {
+	Logo         string            `json:"logo,omitempty" firestore:"logo,omitempty"`
+	Description  string            `json:"description,omitempty" firestore:"description,omitempty"`
+	Website      string            `json:"website,omitempty" firestore:"website,omitempty"`
+	Country      string            `json:"country,omitempty" firestore:"country,omitempty"` // ISO 3166-1 alpha-2
+	SupportEmail string            `json:"supportEmail,omitempty" firestore:"supportEmail,omitempty"`
+	SupportPhone string            `json:"supportPhone,omitempty" firestore:"supportPhone,omitempty"`
+	SupportURL   string            `json:"supportUrl,omitempty" firestore:"supportUrl,omitempty"`
+	Social       map[string]string `json:"social,omitempty" firestore:"social,omitempty"`
+	UpdatedAt    int64             `json:"updatedAt,omitempty" firestore:"updatedAt,omitempty"`
+}
+
+// profileURL — лише https (лого ще й ipfs://), щоб сторінка продукту не
+// отримала javascript: чи змішаний контент
+func profileURL(field, s string, ipfs bool) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", nil
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && !(ipfs && u.Scheme == "ipfs")) {
+		return "", fmt.Errorf("%s must be an https URL", field)
+	}
+	return u.String(), nil
+}
+
+// normBrandProfile — перевіряє і нормалізує профіль з тіла запиту
+func normBrandProfile(in BrandProfile) (BrandProfile, error) {
+	var out BrandProfile
+	var err error
+	if out.Logo, err = profileURL("logo", in.Logo, true); err != nil {
+		return out, err
+	}
+	if out.Website, err = profileURL("website", in.Website, false); err != nil {
+		return out, err
+	}
+	if out.SupportURL, err = profileURL("supportUrl", in.SupportURL, false); err != nil {
+		return out, err
+	}
+	out.Description = strings.TrimSpace(in.Description)
+	if len([]rune(out.Description)) > brandDescMax {
+		return out, fmt.Errorf("description is too long (max %d)", brandDescMax)
+	}
+	if out.Country = strings.ToUpper(strings.TrimSpace(in.Country)); out.Country != "" && !countryRe.MatchString(out.Country) {
+		return out, fmt.Errorf("country must be an ISO 3166-1 alpha-2 code")
+	}
+	if e := strings.TrimSpace(in.SupportEmail); e != "" {
+		a, err := mail.ParseAddress(e)
+		if err != nil || a.Address != e {
+			return out, fmt.Errorf("invalid supportEmail")
+		}
+		out.SupportEmail = strings.ToLower(e)
+	}
+	if out.SupportPhone = strings.TrimSpace(in.SupportPhone); out.SupportPhone != "" && !phoneRe.MatchString(out.SupportPhone) {
+		return out, fmt.Errorf("invalid supportPhone")
+	}
+	for k, v := range in.Social {
+		k = strings.ToLower(strings.TrimSpace(k))
+		if !slices.Contains(brandSocials, k) {
+			return out, fmt.Errorf("unknown social network %q (allowed: %s)", k, strings.Join(brandSocials, ", "))
+		}
+		v, err := profileURL("social."+k, v, false)
+		if err != nil {
+			return out, err
+		}
+		if v == "" {
+			continue
+		}
+		if out.Social == nil {
+			out.Social = map[string]string{}
+		}
+		out.Social[k] = v
+	}
+	return out, nil
+}
+
+// brandPublicView — бренд для сторонніх: без власника і того, хто верифікував
+func brandPublicView(m Manufacturer) map[string]any {
+	return map[string]any{
+		"slug":       m.Slug,
+		"name":       m.Name,
+		"verified":   m.Verified,
+		"verifiedAt": m.VerifiedAt,
+		"suspended":  m.Suspended,
+		"createdAt":  m.CreatedAt,
+		"profile":    m.Profile,
+	}
+}
+
+func fsSetBrandProfile(ctx context.Context, slug string, p BrandProfile) error {
+	_, err := fsDoc("brands/"+slug).Set(ctx, map[string]any{"profile": p}, firestore.Merge([]string{"profile"}))
+	brandCache.del(slug)
+	return err
+}
+
+// brandProfile — POST /api/manufacturers/{slug}/profile (повна заміна профілю)
+func brandProfile(w http.ResponseWriter, r *http.Request, slug string) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, 405, ErrorResp{"Method not allowed"})
+		return
+	}
+	u := currentUser(r)
+	if u == "" {
+		writeJSON(w, 401, ErrorResp{"missing user"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 6*time.Second)
+	defer cancel()
+	m, err := fsGetBrand(ctx, slug)
+	if err != nil {
+		writeJSON(w, 404, ErrorResp{"manufacturer not found"})
+		return
+	}
+	if !canBrand(ctx, u, slug, PermBrandProfile) {
+		writeJSON(w, 403, ErrorResp{"forbidden"})
+		return
+	}
+	var body BrandProfile
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, 400, ErrorResp{"invalid json"})
+		return
+	}
+	p, err := normBrandProfile(body)
+	if err != nil {
+		writeJSON(w, 400, ErrorResp{err.Error()})
+		return
+	}
+	p.UpdatedAt = time.Now().UnixMilli()
+	if err := fsSetBrandProfile(ctx, slug, p); err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+	auditRecord(ctx, r, AuditBrandProfile, auditBrand(slug), m.Profile, p)
+	m.Profile = p
+	writeJSON(w, 200, brandPublicView(m))
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProfileURL(t *testing.T) {
+	tests := []struct {
+		in   string
+		ipfs bool
+		want string
+		ok   bool
+	}{
+		{"", false, "", true},
+		{" https://acme.example/about ", false, "https://acme.example/about", true},
+		{"ipfs://bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi", true, "ipfs://bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi", true},
+		{"ipfs://bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi", false, "", false},
+		{"javascript:alert(1)", true, "", false},
+		{"JavaScript://acme.example/%0aalert(1)", false, "", false},
+		{"http://acme.example", false, "", false},
+		{"data:image/png;base64,AAAA", true, "", false},
+		{"//acme.example/logo.png", true, "", false},
+		{"https:///logo.png", true, "", false},
+	}
+	for _, tt := range tests {
+		got, err := profileURL("logo", tt.in, tt.ipfs)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("profileURL(%q, %v) = %q, %v", tt.in, tt.ipfs, got, err)
+		}
+	}
+}
+
+func TestNormBrandProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      BrandProfile
+		wantErr string
+		check   func(BrandProfile) bool
+	}{
+		{
+			name: "full profile",
+			in: BrandProfile{
+				Logo: "ipfs://bafylogo", Website: "https://acme.example", Country: " ua ",
+				SupportEmail: "Help@Acme.Example", SupportPhone: "+380 (44) 123-45-67",
+				Social: map[string]string{" Instagram ": "https://instagram.com/acme", "x": ""},
+			},
+			check: func(p BrandProfile) bool {
+				return p.Logo == "ipfs://bafylogo" && p.Country == "UA" && p.SupportEmail == "help@acme.example" &&
+					len(p.Social) == 1 && p.Social["instagram"] == "https://instagram.com/acme"
+			},
+		},
+		{name: "javascript logo", in: BrandProfile{Logo: "javascript:alert(1)"}, wantErr: "logo"},
+		{name: "http website", in: BrandProfile{Website: "http://acme.example"}, wantErr: "website"},
+		{name: "ipfs website", in: BrandProfile{Website: "ipfs://bafysite"}, wantErr: "website"},
+		{name: "http social", in: BrandProfile{Social: map[string]string{"facebook": "http://fb.com/acme"}}, wantErr: "social.facebook"},
+		{name: "three-letter country", in: BrandProfile{Country: "UKR"}, wantErr: "country"},
+		{name: "digits in country", in: BrandProfile{Country: "U1"}, wantErr: "country"},
+		{name: "display-name email", in: BrandProfile{SupportEmail: "Acme Support <help@acme.example>"}, wantErr: "supportEmail"},
+		{name: "bad phone", in: BrandProfile{SupportPhone: "call us"}, wantErr: "supportPhone"},
+		{name: "unknown social", in: BrandProfile{Social: map[string]string{"myspace": "https://myspace.com/acme"}}, wantErr: "unknown social network"},
+		{name: "long description", in: BrandProfile{Description: strings.Repeat("я", brandDescMax+1)}, wantErr: "description"},
+		{
+			name:  "description at limit",
+			in:    BrandProfile{Description: strings.Repeat("я", brandDescMax)},
+			check: func(p BrandProfile) bool { return len([]rune(p.Description)) == brandDescMax },
+		},
+	}
+	for _, tt := range tests {
+		got, err := normBrandProfile(tt.in)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !tt.check(got) {
+			t.Errorf("%s: %+v, %v", tt.name, got, err)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+// brandstatus.go — призупинення, зняття верифікації і поновлення бренду.
+// Призупинений бренд не мінтить, а перевірка його продуктів показує
+// попередження. Кожна зміна — запис у brandStatusLog (хто, коли, причина) і
+// ledger у тій самій транзакції, плюс аудит. Призупинення і зняття
+// верифікації захисні й діють одразу; поновлення повертає право мінту, тож
+// проходить чотири ока (approvals.go).
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+const (
+	AuditBrandSuspend   = "brand.suspend"
+	AuditBrandReinstate = "brand.reinstate"
+	AuditBrandUnverify  = "brand.unverify"
+)
+
+const brandStatusLogLimit = 100
+
+type BrandStatusChange struct {
+	ID         string `json:"id"`
+	BrandSlug  string `json:"brandSlug"`
+	Action     string `json:"action"`
+	Reason     string `json:"reason,omitempty"`
+	Actor      string `json:"actor"`
+	ActorEmail string `json:"actorEmail,omitempty"`
+	Approval   string `json:"approval,omitempty"`
+	At         int64  `json:"at"`
+}
+
+type FSBrandStatusChange struct {
+	BrandSlug  string    `firestore:"brandSlug"`
+	Action     string    `firestore:"action"`
+	Reason     string    `firestore:"reason,omitempty"`
+	Actor      string    `firestore:"actor"`
+	ActorEmail string    `firestore:"actorEmail,omitempty"`
+	Approval   string    `firestore:"approval,omitempty"`
+	At         time.Time `firestore:"at"`
+}
+
+// fsChangeBrandStatus — атомарно змінює статус бренду і пише історію
+func fsChangeBrandStatus(ctx context.Context, slug, action string, c FSBrandStatusChange) (Manufacturer, Manufacturer, error) {
+	ref := fsDoc("brands/" + slug)
+	var prev Manufacturer
+	err := fsClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		s, err := tx.Get(ref)
+		if err != nil {
+			if strings.Contains(strings.ToLower(err.Error()), "not found") {
+				return opError{404, "manufacturer not found"}
+			}
+			return err
+		}
+		var b FSBrand
+		if err := s.DataTo(&b); err != nil {
+			return err
+		}
+		prev = brandFromFS(b, s.CreateTime)
+
+		var upd []firestore.Update
+		switch action {
+		case AuditBrandSuspend:
+			if b.Suspended {
+				return opError{409, "brand already suspended"}
+			}
+			upd = []firestore.Update{
+				{Path: "suspended", Value: true},
+				{Path: "suspendReason", Value: c.Reason},
+				{Path: "suspendedBy", Value: c.Actor},
+				{Path: "suspendedAt", Value: c.At},
+			}
+		case AuditBrandReinstate:
+			if !b.Suspended {
+				return opError{409, "brand is not suspended"}
+			}
+			upd = []firestore.Update{
+				{Path: "suspended", Value: false},
+				{Path: "suspendReason", Value: firestore.Delete},
+				{Path: "suspendedBy", Value: firestore.Delete},
+				{Path: "suspendedAt", Value: firestore.Delete},
+			}
+		case AuditBrandUnverify:
+			if !b.Verified {
+				return opError{409, "brand is not verified"}
+			}
+			upd = []firestore.Update{
+				{Path: "verified", Value: false},
+				{Path: "verifiedBy", Value: firestore.Delete},
+				{Path: "verifiedAt", Value: firestore.Delete},
+			}
+		default:
+			return opError{400, "unknown action"}
+		}
+		head, err := ledgerReadHead(tx)
+		if err != nil {
+			return err
+		}
+		if err := tx.Update(ref, upd); err != nil {
+			return err
+		}
+		c.BrandSlug, c.Action = slug, action
+		if err := tx.Create(fsCol("brandStatusLog").NewDoc(), c); err != nil {
+			return err
+		}
+		// причина — лише в історії бренду та аудиті, ledger публічний
+		_, err = head.append(tx, LedgerEntry{
+			Kind: LedgerBrandStatus, BrandSlug: slug, Actor: c.Actor,
+			Data: map[string]string{"action": strings.TrimPrefix(action, "brand.")},
+		})
+		return err
+	})
+	brandCache.del(slug)
+	if err != nil {
+		return Manufacturer{}, Manufacturer{}, err
+	}
+	m, err := fsGetBrand(ctx, slug)
+	return prev, m, err
+}
+
+func fsBrandStatusLog(ctx context.Context, slug string) ([]BrandStatusChange, error) {
+	it := fsCol("brandStatusLog").Where("brandSlug", "==", slug).
+		OrderBy("at", firestore.Desc).Limit(brandStatusLogLimit).Documents(ctx)
+	defer it.Stop()
+	out := []BrandStatusChange{}
+	for {
+		d, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var x FSBrandStatusChange
+		if err := d.DataTo(&x); err != nil {
+			return nil, err
+		}
+		out = append(out, BrandStatusChange{
+			ID: d.Ref.ID, BrandSlug: x.BrandSlug, Action: x.Action, Reason: x.Reason,
+			Actor: x.Actor, ActorEmail: x.ActorEmail, Approval: x.Approval, At: x.At.UnixMilli(),
+		})
+	}
+	return out, nil
+}
+
+// runBrandStatus — спільне виконання для прямого виклику і для approvals.go
+func runBrandStatus(action string) func(ctx context.Context, r *http.Request, p map[string]string, a *Approval) (map[string]any, error) {
+	return func(ctx context.Context, r *http.Request, p map[string]string, a *Approval) (map[string]any, error) {
+		slug, u := p["slug"], currentUser(r)
+		c := FSBrandStatusChange{Reason: p["reason"], Actor: u, ActorEmail: currentEmail(r), At: time.Now()}
+		if a != nil {
+			c.Approval = a.ID
+		}
+		prev, m, err := fsChangeBrandStatus(ctx, slug, action, c)
+		if err != nil {
+			return nil, err
+		}
+		auditRecord(ctx, r, action, auditBrand(slug),
+			map[string]any{"verified": prev.Verified, "suspended": prev.Suspended},
+			withApproval(map[string]any{"verified": m.Verified, "suspended": m.Suspended, "reason": c.Reason}, a))
+		return map[string]any{"ok": true, "manufacturer": m}, nil
+	}
+}
+
+// brandStatus — /api/manufacturers/{slug}/status (GET: статус та історія),
+// /suspend, /unverify, /reinstate (POST {reason})
+func brandStatus(w http.ResponseWriter, r *http.Request, slug, action string) {
+	u := currentUser(r)
+	if u == "" {
+		writeJSON(w, 401, ErrorResp{"missing user"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+
+	if action == "status" {
+		if r.Method != http.MethodGet {
+			writeJSON(w, 405, ErrorResp{"Method not allowed"})
+			return
+		}
+		if !can(ctx, u, PermBrandsVerify) && !canBrand(ctx, u, slug, PermBrandRead) {
+			writeJSON(w, 403, ErrorResp{"forbidden"})
+			return
+		}
+		m, err := fsGetBrand(ctx, slug)
+		if err != nil {
+			writeJSON(w, 404, ErrorResp{"manufacturer not found"})
+			return
+		}
+		history, err := fsBrandStatusLog(ctx, slug)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		writeJSON(w, 200, map[string]any{
+			"slug": m.Slug, "verified": m.Verified, "verifiedAt": m.VerifiedAt,
+			"suspended": m.Suspended, "suspendReason": m.SuspendReason, "suspendedAt": m.SuspendedAt,
+			"history": history,
+		})
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		writeJSON(w, 405, ErrorResp{"Method not allowed"})
+		return
+	}
+	if !can(ctx, u, PermBrandsVerify) {
+		writeJSON(w, 403, ErrorResp{"forbidden"})
+		return
+	}
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	params := map[string]string{"slug": slug, "reason": strings.TrimSpace(body.Reason)}
+	op := "brand." + action
+	if op != AuditBrandReinstate && params["reason"] == "" {
+		writeJSON(w, 400, ErrorResp{"reason is required"})
+		return
+	}
+	if _, err := fsGetBrand(ctx, slug); err != nil {
+		writeJSON(w, 404, ErrorResp{"manufacturer not found"})
+		return
+	}
+	if op == AuditBrandReinstate {
+		runOrPropose(ctx, w, r, op, auditBrand(slug), params)
+		return
+	}
+	res, err := runBrandStatus(op)(ctx, r, params, nil)
+	if err != nil {
+		writeOpErr(w, err)
+		return
+	}
+	writeJSON(w, 200, res)
+}
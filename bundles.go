@@ -0,0 +1,137 @@
+// bundles.go — експорт підписаних офлайн-пакетів перевірки (пакет offline)
+// для партії або SKU бренду; підписуються ключем емітента VC.
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"marki-secure/offline"
+)
+
+const offlineMaxRecords = 20000
+
+func buildOfflineBundle(scope string, list []Product) ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	k := vcKeys[0]
+	b := offline.Bundle{
+		Issuer:    vcIssuer,
+		KeyID:     k.ID,
+		Scope:     scope,
+		CreatedAt: time.Now().Unix(),
+		Salt:      salt,
+		Records:   make([]offline.Record, 0, len(list)),
+	}
+	for _, p := range list {
+		b.Records = append(b.Records, offline.Record{
+			TokenID:    p.TokenID,
+			SerialHash: offline.SerialHash(salt, p.Meta.Serial),
+			State:      string(p.State),
+			Brand:      p.BrandSlug,
+		})
+	}
+	return offline.Seal(b, k.Priv)
+}
+
+// GET /api/manufacturer/offline-bundle?batch={id}
+// GET /api/manufacturer/offline-bundle?sku={sku}&brand={slug}
+func manufacturerOfflineBundle(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		returnOK(w)
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeJSON(w, 405, ErrorResp{"Method not allowed"})
+		return
+	}
+	u := currentUser(r)
+	if u == "" {
+		writeJSON(w, 401, ErrorResp{"missing user"})
+		return
+	}
+	q := r.URL.Query()
+	batchID := strings.TrimSpace(q.Get("batch"))
+	sku := strings.ToUpper(strings.TrimSpace(q.Get("sku")))
+	if (batchID == "") == (sku == "") {
+		writeJSON(w, 400, ErrorResp{"batch or sku required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	var list []Product
+	var scope string
+	var err error
+	if batchID != "" {
+		scope = "batch:" + batchID
+		list, err = fsListProductsByBatch(ctx, batchID)
+	} else {
+		// явний бренд перевіряється нижче разом із персоналом (canBrand)
+		brand := strings.TrimSpace(q.Get("brand"))
+		if brand == "" {
+			if brand, err = fsChooseBrand(ctx, u, "", PermBrandBatches); err != nil {
+				writeOpErr(w, err)
+				return
+			}
+		}
+		scope = "sku:" + brand + "/" + sku
+		list, err = fsQueryProducts(ctx, fsCol("products").
+			Where("brandSlug", "==", brand).Where("sku", "==", sku).Limit(offlineMaxRecords+1))
+	}
+	if err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+	if len(list) > offlineMaxRecords {
+		writeJSON(w, 400, ErrorResp{fmt.Sprintf("too many products (max %d)", offlineMaxRecords)})
+		return
+	}
+
+	// лише бренди, якими користувач керує
+	owned := map[string]bool{}
+	var allowed []Product
+	for _, p := range list {
+		ok, seen := owned[p.BrandSlug]
+		if !seen {
+			ok = canBrand(ctx, u, p.BrandSlug, PermBrandBatches)
+			owned[p.BrandSlug] = ok
+		}
+		if ok {
+			allowed = append(allowed, p)
+		}
+	}
+	if len(allowed) == 0 {
+		writeJSON(w, 404, ErrorResp{"no products of your brands in scope"})
+		return
+	}
+
+	data, err := buildOfflineBundle(scope, allowed)
+	if err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="marki-`+safeFilename(scope)+`.bundle"`)
+	_, _ = w.Write(data)
+}
+
+// safeFilename — лише [A-Za-z0-9._-] для Content-Disposition: sku і batch
+// приходять від користувача, лапки чи переводи рядка зламали б заголовок
+func safeFilename(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		}
+		return '-'
+	}, s)
+}
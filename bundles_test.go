@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"marki-secure/offline"
+)
+
+func TestSafeFilename(t *testing.T) {
+	tests := map[string]string{
+		"batch:b_01HX.2":          "batch-b_01HX.2",
+		"sku:acme/SKU-1":          "sku-acme-SKU-1",
+		`sku:acme/X"; evil=1`:     "sku-acme-X---evil-1",
+		"batch:a\r\nSet-Cookie:x": "batch-a--Set-Cookie-x",
+		"sku:acme/КРОС":           "sku-acme-----",
+	}
+	for in, want := range tests {
+		if got := safeFilename(in); got != want {
+			t.Errorf("safeFilename(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// пакет, підписаний поточним ключем, відкривається ключами з did.json;
+// ротований ключ лишається в документі
+func TestOfflineBundleVerifiesAgainstDIDDocument(t *testing.T) {
+	old := vcKeys
+	t.Cleanup(func() { vcKeys = old })
+	_, k2, _ := ed25519.GenerateKey(rand.Reader)
+	_, k1, _ := ed25519.GenerateKey(rand.Reader)
+	vcKeys = []vcKey{{ID: "k2", Priv: k2}, {ID: "k1", Priv: k1}}
+
+	rec := httptest.NewRecorder()
+	didDocument(rec, httptest.NewRequest("GET", "/.well-known/did.json", nil))
+	if rec.Code != 200 {
+		t.Fatalf("did.json: %d", rec.Code)
+	}
+	keys, err := offline.KeysFromDID(rec.Body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || !keys["k1"].Equal(k1.Public()) {
+		t.Fatalf("keys from did.json = %v", keys)
+	}
+
+	data, err := buildOfflineBundle("batch:b1", []Product{
+		{TokenID: 7, BrandSlug: "acme", Meta: Metadata{Serial: "ABCD-EFGH-JKMN-PQR0"}, State: StateCreated},
+		{TokenID: 8, BrandSlug: "acme", Meta: Metadata{Serial: "ABCD-EFGH-JKMN-PQR1"}, State: StateRevoked},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := offline.Open(data, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.KeyID != "k2" || b.Issuer != vcIssuer || b.Scope != "batch:b1" {
+		t.Fatalf("bundle header = %s %s %s", b.KeyID, b.Issuer, b.Scope)
+	}
+	now := time.Now()
+	if res := b.Check(7, "ABCD-EFGH-JKMN-PQR0", now, time.Hour); res.Status != offline.Authentic {
+		t.Errorf("token 7: %s", res.Status)
+	}
+	if res := b.Check(8, "", now, time.Hour); res.Status != offline.Revoked {
+		t.Errorf("token 8: %s", res.Status)
+	}
+}
@@ -0,0 +1,637 @@
+// chain.go — адаптер EVM-ланцюга: mint/anchor продуктів і передач через
+// JSON-RPC з чергою завдань у Firestore та повторами. Firestore лишається
+// джерелом істини; без CHAIN_RPC_URL адаптер вимкнений.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"golang.org/x/crypto/sha3"
+)
+
+// ChainAdapter — відправка завдання в мережу і перевірка квитанції
+type ChainAdapter interface {
+	Name() string
+	// PendingNonce — наступний nonce акаунта з урахуванням мемпулу
+	PendingNonce(ctx context.Context) (int64, error)
+	// TxNonce — nonce відомої транзакції; found=false — нода її не знає
+	TxNonce(ctx context.Context, txHash string) (nonce int64, found bool, err error)
+	// Send відправляє завдання з job.Nonce; повтор з тим самим nonce — заміна
+	// з ціною газу щонайменше на 15% вищою за job.GasPrice. gasPrice
+	// повертається і при помилці відправки.
+	Send(ctx context.Context, job FSChainJob) (txHash string, gasPrice int64, err error)
+	// Receipt: done=false — ще в мемпулі; ok=false — транзакція відкотилась
+	Receipt(ctx context.Context, txHash string) (done, ok bool, err error)
+}
+
+const (
+	ChainJobMint     = "mint"
+	ChainJobTransfer = "transfer"
+
+	ChainPending   = "pending"
+	ChainSending   = "sending"
+	ChainSent      = "sent"
+	ChainConfirmed = "confirmed"
+	ChainFailed    = "failed"
+)
+
+type FSChainJob struct {
+	ID        string    `firestore:"id"`
+	Kind      string    `firestore:"kind"`
+	TokenID   int64     `firestore:"tokenId"`
+	CID       string    `firestore:"cid"`
+	Status    string    `firestore:"status"`
+	Attempts  int       `firestore:"attempts"`
+	NextAt    time.Time `firestore:"nextAt"`
+	TxHash    string    `firestore:"txHash"`
+	LastError string    `firestore:"lastError"`
+	CreatedAt time.Time `firestore:"createdAt"`
+
+	LedgerSeq  int64    `firestore:"ledgerSeq"`
+	LedgerHash string   `firestore:"ledgerHash"`
+	Nonce      int64    `firestore:"nonce"`
+	HasNonce   bool     `firestore:"hasNonce"`
+	GasPrice   int64    `firestore:"gasPrice"` // wei, останньої відправки
+	TxHashes   []string `firestore:"txHashes"` // усі відправлені заміни з цим nonce
+}
+
+var (
+	chainRPCURL      = strings.TrimSpace(os.Getenv("CHAIN_RPC_URL"))
+	chainFrom        = strings.ToLower(strings.TrimSpace(os.Getenv("CHAIN_FROM"))) // розблокований акаунт ноди або signer-проксі
+	chainMaxAttempts = envInt("CHAIN_MAX_ATTEMPTS", 10)
+	chainPollEvery   = envDuration("CHAIN_POLL_INTERVAL", 15*time.Second)
+
+	chain = newChainAdapter()
+)
+
+func envInt(name string, def int) int {
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv(name))); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
+func envDuration(name string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(strings.TrimSpace(os.Getenv(name))); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
+
+// CHAIN_MODE=anchor (за замовчуванням) — хеш події в calldata транзакції на CHAIN_ANCHOR_TO;
+// CHAIN_MODE=erc721 — safeMint(address,uint256,string) на CHAIN_CONTRACT.
+func newChainAdapter() ChainAdapter {
+	if chainRPCURL == "" {
+		return nil
+	}
+	if !isHexAddress(chainFrom) {
+		log.Println("[chain] CHAIN_FROM is not a valid address — chain adapter disabled")
+		return nil
+	}
+	c := &evmChain{
+		rpc:      &jsonRPC{url: chainRPCURL, hc: &http.Client{Timeout: 20 * time.Second}},
+		from:     chainFrom,
+		anchorTo: strings.ToLower(strings.TrimSpace(os.Getenv("CHAIN_ANCHOR_TO"))),
+	}
+	if c.anchorTo == "" {
+		c.anchorTo = c.from
+	}
+	if strings.EqualFold(strings.TrimSpace(os.Getenv("CHAIN_MODE")), "erc721") {
+		c.contract = strings.ToLower(strings.TrimSpace(os.Getenv("CHAIN_CONTRACT")))
+		if !isHexAddress(c.contract) {
+			log.Println("[chain] CHAIN_MODE=erc721 needs CHAIN_CONTRACT — chain adapter disabled")
+			return nil
+		}
+	}
+	log.Printf("[chain] %s via %s from %s\n", c.Name(), chainRPCURL, c.from)
+	return c
+}
+
+func isHexAddress(s string) bool {
+	if len(s) != 42 || !strings.HasPrefix(s, "0x") {
+		return false
+	}
+	_, err := hex.DecodeString(s[2:])
+	return err == nil
+}
+
+// ================== JSON-RPC ==================
+
+type jsonRPC struct {
+	url string
+	hc  *http.Client
+	id  atomic.Int64
+}
+
+func (c *jsonRPC) call(ctx context.Context, method string, params []any, out any) error {
+	body := mustJSON(map[string]any{"jsonrpc": "2.0", "id": c.id.Add(1), "method": method, "params": params})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var res struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return fmt.Errorf("%s: http %d: %v", method, resp.StatusCode, err)
+	}
+	if res.Error != nil {
+		return fmt.Errorf("%s: rpc %d: %s", method, res.Error.Code, res.Error.Message)
+	}
+	return json.Unmarshal(res.Result, out)
+}
+
+// ================== EVM ==================
+
+type evmChain struct {
+	rpc      *jsonRPC
+	from     string
+	contract string // порожній — режим anchor
+	anchorTo string
+}
+
+func (c *evmChain) Name() string {
+	if c.contract != "" {
+		return "evm-erc721"
+	}
+	return "evm-anchor"
+}
+
+func parseQuantity(s string) (int64, error) {
+	return strconv.ParseInt(strings.TrimPrefix(s, "0x"), 16, 64)
+}
+
+func (c *evmChain) PendingNonce(ctx context.Context) (int64, error) {
+	var n string
+	if err := c.rpc.call(ctx, "eth_getTransactionCount", []any{c.from, "pending"}, &n); err != nil {
+		return 0, err
+	}
+	return parseQuantity(n)
+}
+
+func (c *evmChain) TxNonce(ctx context.Context, txHash string) (int64, bool, error) {
+	var tx *struct {
+		Nonce string `json:"nonce"`
+	}
+	if err := c.rpc.call(ctx, "eth_getTransactionByHash", []any{txHash}, &tx); err != nil {
+		return 0, false, err
+	}
+	if tx == nil {
+		return 0, false, nil
+	}
+	n, err := parseQuantity(tx.Nonce)
+	return n, err == nil, err
+}
+
+func (c *evmChain) Send(ctx context.Context, job FSChainJob) (string, int64, error) {
+	if !job.HasNonce {
+		return "", 0, fmt.Errorf("job %s has no reserved nonce", job.ID)
+	}
+	var gp string
+	if err := c.rpc.call(ctx, "eth_gasPrice", nil, &gp); err != nil {
+		return "", 0, err
+	}
+	price, err := parseQuantity(gp)
+	if err != nil {
+		return "", 0, fmt.Errorf("eth_gasPrice: %v", err)
+	}
+	// заміна в мемпулі приймається лише з помітно вищою ціною
+	price = max(price, job.GasPrice*115/100+1)
+
+	tx := map[string]string{
+		"from": c.from, "value": "0x0",
+		"nonce": fmt.Sprintf("0x%x", job.Nonce), "gasPrice": fmt.Sprintf("0x%x", price),
+	}
+	if c.contract != "" && job.Kind == ChainJobMint {
+		// токени тримає кастодіальний акаунт — власники лишаються в Firestore
+		tx["to"] = c.contract
+		tx["data"] = "0x" + hex.EncodeToString(abiSafeMint(c.from, job.TokenID, "ipfs://"+job.CID))
+	} else {
+		tx["to"] = c.anchorTo
+		tx["data"] = "0x" + hex.EncodeToString(chainAnchor(job))
+	}
+	var hash string
+	if err := c.rpc.call(ctx, "eth_sendTransaction", []any{tx}, &hash); err != nil {
+		return "", price, err // ціна потрібна, щоб наступна заміна була вищою
+	}
+	if hash == "" {
+		return "", 0, fmt.Errorf("eth_sendTransaction: empty tx hash")
+	}
+	return hash, price, nil
+}
+
+func (c *evmChain) Receipt(ctx context.Context, txHash string) (bool, bool, error) {
+	var rc *struct {
+		Status string `json:"status"`
+	}
+	if err := c.rpc.call(ctx, "eth_getTransactionReceipt", []any{txHash}, &rc); err != nil {
+		return false, false, err
+	}
+	if rc == nil {
+		return false, false, nil
+	}
+	return true, rc.Status == "0x1", nil
+}
+
+// chainAnchor — "MARKI" + seq (8 байтів big-endian) + хеш запису журналу.
+// Через prevHash хеш запису фіксує і весь журнал до нього, тож транзакцію
+// можна звірити з /api/ledger; email-и на ланцюг не потрапляють.
+func chainAnchor(job FSChainJob) []byte {
+	out := []byte("MARKI")
+	if h, err := hex.DecodeString(job.LedgerHash); err == nil && len(h) == sha256.Size {
+		out = binary.BigEndian.AppendUint64(out, uint64(job.LedgerSeq))
+		return append(out, h...)
+	}
+	// завдання, поставлені до прив'язки до журналу
+	enc, _ := dagCBOR(map[string]any{
+		"kind": job.Kind, "tokenId": job.TokenID, "cid": job.CID, "job": job.ID,
+	})
+	h := sha256.Sum256(enc)
+	return append(out, h[:]...)
+}
+
+func keccak256(b []byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(b)
+	return h.Sum(nil)
+}
+
+func abiWord(n *big.Int) []byte { return n.FillBytes(make([]byte, 32)) }
+
+// abiSafeMint кодує safeMint(address to, uint256 tokenId, string uri)
+func abiSafeMint(to string, tokenID int64, uri string) []byte {
+	addr, _ := hex.DecodeString(strings.TrimPrefix(to, "0x"))
+	out := append([]byte{}, keccak256([]byte("safeMint(address,uint256,string)"))[:4]...)
+	out = append(out, abiWord(new(big.Int).SetBytes(addr))...)
+	out = append(out, abiWord(big.NewInt(tokenID))...)
+	out = append(out, abiWord(big.NewInt(3*32))...) // зсув рядка
+	out = append(out, abiWord(big.NewInt(int64(len(uri))))...)
+	s := []byte(uri)
+	out = append(out, s...)
+	if pad := (32 - len(s)%32) % 32; pad > 0 {
+		out = append(out, make([]byte, pad)...)
+	}
+	return out
+}
+
+// ================== QUEUE ==================
+
+// chainEnqueue ставить mint/transfer події журналу в чергу; помилки лише в лог
+func chainEnqueue(ctx context.Context, events ...LedgerEntry) {
+	if chain == nil {
+		return
+	}
+	now := time.Now()
+	for _, e := range events {
+		job := FSChainJob{
+			TokenID: e.TokenID, Status: ChainPending, NextAt: now, CreatedAt: now,
+			LedgerSeq: e.Seq, LedgerHash: e.Hash,
+		}
+		switch e.Kind {
+		case LedgerMint:
+			job.Kind, job.ID = ChainJobMint, fmt.Sprintf("mint-%d", e.TokenID)
+		case LedgerTransfer:
+			job.Kind, job.ID = ChainJobTransfer, fmt.Sprintf("transfer-%d-%d", e.TokenID, e.Seq)
+		default:
+			continue
+		}
+		job.CID = e.Data["cid"]
+		if _, err := fsDoc("chainJobs/"+job.ID).Create(ctx, job); err != nil &&
+			!strings.Contains(strings.ToLower(err.Error()), "already exists") {
+			log.Printf("[chain] enqueue %s failed: %v\n", job.ID, err)
+		}
+	}
+}
+
+func chainBackoff(attempts int) time.Duration {
+	d := time.Duration(1<<min(attempts, 10)) * 10 * time.Second
+	return min(d, 6*time.Hour)
+}
+
+// chainClaim переводить завдання в sending, щоб інший інстанс його не взяв
+func chainClaim(ctx context.Context, id string) (FSChainJob, bool, error) {
+	var job FSChainJob
+	claimed := false
+	err := fsClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		claimed = false
+		s, err := tx.Get(fsDoc("chainJobs/" + id))
+		if err != nil {
+			return err
+		}
+		if err := s.DataTo(&job); err != nil {
+			return err
+		}
+		if (job.Status != ChainPending && job.Status != ChainSending) || job.NextAt.After(time.Now()) {
+			return nil
+		}
+		claimed = true
+		return tx.Update(s.Ref, []firestore.Update{
+			{Path: "status", Value: ChainSending},
+			{Path: "nextAt", Value: time.Now().Add(5 * time.Minute)}, // оренда
+		})
+	})
+	return job, claimed, err
+}
+
+// chainFail — повтор пізніше; nonce лишається, тож повтор замінить
+// попередню транзакцію, якщо вона ще в мемпулі
+func chainFail(ctx context.Context, job FSChainJob, cause error, extra ...firestore.Update) {
+	job.Attempts++
+	st := ChainPending
+	if job.Attempts >= chainMaxAttempts {
+		st = ChainFailed
+	}
+	log.Printf("[chain] %s attempt %d: %v\n", job.ID, job.Attempts, cause)
+	_, _ = fsDoc("chainJobs/"+job.ID).Update(ctx, append([]firestore.Update{
+		{Path: "status", Value: st},
+		{Path: "attempts", Value: job.Attempts},
+		{Path: "nextAt", Value: time.Now().Add(chainBackoff(job.Attempts))},
+		{Path: "lastError", Value: cause.Error()},
+	}, extra...))
+}
+
+// chainReleaseNonce — nonce спожито (відкат або чужа транзакція): наступна
+// відправка візьме новий, а старі хеші більше не перевіряються
+var chainReleaseNonce = []firestore.Update{
+	{Path: "hasNonce", Value: false},
+	{Path: "txHash", Value: ""},
+	{Path: "txHashes", Value: firestore.Delete},
+}
+
+// chainKnownHashes — усі відправлені транзакції завдання
+func chainKnownHashes(job FSChainJob) []string {
+	if len(job.TxHashes) == 0 && job.TxHash != "" {
+		return []string{job.TxHash} // завдання зі старих версій
+	}
+	return job.TxHashes
+}
+
+// chainMined — змайнена транзакція завдання, якщо така є (hash == "" — жодної)
+func chainMined(ctx context.Context, job FSChainJob) (hash string, ok bool, err error) {
+	for _, h := range chainKnownHashes(job) {
+		done, ok, err := chain.Receipt(ctx, h)
+		if err != nil {
+			return "", false, err
+		}
+		if done {
+			return h, ok, nil
+		}
+	}
+	return "", false, nil
+}
+
+// chainReserveNonce закріплює nonce за завданням до першої відправки: повтор
+// після збою чи оренди, що минула, іде з тим самим nonce і замінює попередню
+// транзакцію, а не додає другу. meta/chainNonce не дає двом інстансам узяти
+// однаковий nonce.
+func chainReserveNonce(ctx context.Context, job *FSChainJob) error {
+	if job.HasNonce {
+		return nil
+	}
+	ref := fsDoc("chainJobs/" + job.ID)
+	for _, h := range chainKnownHashes(*job) {
+		n, found, err := chain.TxNonce(ctx, h)
+		if err != nil {
+			return err
+		}
+		if found {
+			job.Nonce, job.HasNonce = n, true
+			_, err := ref.Update(ctx, []firestore.Update{{Path: "nonce", Value: n}, {Path: "hasNonce", Value: true}})
+			return err
+		}
+	}
+	onchain, err := chain.PendingNonce(ctx)
+	if err != nil {
+		return err
+	}
+	counter := fsDoc("meta/chainNonce")
+	return fsClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		next := onchain
+		s, err := tx.Get(counter)
+		if err == nil {
+			if v, ok := s.Data()["next"].(int64); ok && v > next {
+				next = v
+			}
+		} else if !strings.Contains(strings.ToLower(err.Error()), "not found") {
+			return err
+		}
+		if err := tx.Set(counter, map[string]any{"next": next + 1}); err != nil {
+			return err
+		}
+		job.Nonce, job.HasNonce = next, true
+		return tx.Update(ref, []firestore.Update{{Path: "nonce", Value: next}, {Path: "hasNonce", Value: true}})
+	})
+}
+
+func chainMarkSent(ctx context.Context, id, hash string) {
+	_, _ = fsDoc("chainJobs/"+id).Update(ctx, []firestore.Update{
+		{Path: "status", Value: ChainSent},
+		{Path: "txHash", Value: hash},
+		{Path: "nextAt", Value: time.Now()},
+	})
+}
+
+func chainSendDue(ctx context.Context) error {
+	iter := fsCol("chainJobs").
+		Where("status", "in", []string{ChainPending, ChainSending}).
+		Where("nextAt", "<=", time.Now()).Limit(25).Documents(ctx)
+	docs, err := iter.GetAll()
+	if err != nil {
+		return err
+	}
+	for _, d := range docs {
+		job, ok, err := chainClaim(ctx, d.Ref.ID)
+		if err != nil || !ok {
+			continue
+		}
+		// попередня відправка могла пройти (оренда минула, "not mined") — не дублюємо
+		mined, _, err := chainMined(ctx, job)
+		if err != nil {
+			chainFail(ctx, job, err)
+			continue
+		}
+		if mined != "" {
+			chainMarkSent(ctx, job.ID, mined)
+			continue
+		}
+		if err := chainReserveNonce(ctx, &job); err != nil {
+			chainFail(ctx, job, err)
+			continue
+		}
+		hash, price, err := chain.Send(ctx, job)
+		if err != nil && strings.Contains(strings.ToLower(err.Error()), "nonce too low") {
+			// nonce уже змайнено: або наша транзакція, або невідома — тоді
+			// зупиняємось, щоб адмін перевірив ланцюг перед повтором
+			if mined, _, _ := chainMined(ctx, job); mined != "" {
+				chainMarkSent(ctx, job.ID, mined)
+				continue
+			}
+			job.Attempts = chainMaxAttempts - 1
+			chainFail(ctx, job, fmt.Errorf("nonce %d used by an unknown transaction", job.Nonce), chainReleaseNonce...)
+			continue
+		}
+		if err != nil {
+			// "underpriced"/"already known": невідома нам копія вже в мемпулі
+			chainFail(ctx, job, err, firestore.Update{Path: "gasPrice", Value: max(job.GasPrice, price)})
+			continue
+		}
+		_, _ = d.Ref.Update(ctx, []firestore.Update{
+			{Path: "status", Value: ChainSent},
+			{Path: "txHash", Value: hash},
+			{Path: "txHashes", Value: firestore.ArrayUnion(hash)},
+			{Path: "gasPrice", Value: price},
+			{Path: "nextAt", Value: time.Now()},
+		})
+	}
+	return nil
+}
+
+// chainConfirmSent перевіряє квитанції; підтверджений хеш пишеться на продукт
+func chainConfirmSent(ctx context.Context) error {
+	docs, err := fsCol("chainJobs").Where("status", "==", ChainSent).Limit(50).Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+	for _, d := range docs {
+		var job FSChainJob
+		if err := d.DataTo(&job); err != nil {
+			continue
+		}
+		// змайнитись могла будь-яка із замін з тим самим nonce
+		hash, ok, err := chainMined(ctx, job)
+		switch {
+		case err != nil:
+			continue
+		case hash == "":
+			if time.Since(job.NextAt) > 30*time.Minute {
+				// nonce лишається — повтор замінить транзакцію з вищою ціною газу
+				chainFail(ctx, job, fmt.Errorf("tx %s not mined", job.TxHash))
+			}
+			continue
+		case !ok:
+			chainFail(ctx, job, fmt.Errorf("tx %s reverted", hash), chainReleaseNonce...)
+			continue
+		}
+		up := firestore.Update{Path: "mintTx", Value: hash}
+		if job.Kind == ChainJobTransfer {
+			up = firestore.Update{Path: "transferTxs", Value: firestore.ArrayUnion(hash)}
+		}
+		if _, err := fsDoc("products/"+strconv.FormatInt(job.TokenID, 10)).Update(ctx, []firestore.Update{up}); err != nil {
+			log.Printf("[chain] %s: store tx on product: %v\n", job.ID, err)
+			continue
+		}
+		_, _ = d.Ref.Update(ctx, []firestore.Update{
+			{Path: "status", Value: ChainConfirmed},
+			{Path: "txHash", Value: hash},
+		})
+	}
+	return nil
+}
+
+func chainWorkerLoop(ctx context.Context) {
+	if chain == nil {
+		return
+	}
+	t := time.NewTicker(chainPollEvery)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			cctx, cancel := context.WithTimeout(ctx, time.Minute)
+			if err := chainSendDue(cctx); err != nil {
+				log.Printf("[chain] send error: %v\n", err)
+			}
+			if err := chainConfirmSent(cctx); err != nil {
+				log.Printf("[chain] confirm error: %v\n", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// ================== HTTP ==================
+
+// GET  /api/admins/chain        — стан адаптера і завдання, що впали
+// POST /api/admins/chain/retry  — повернути failed-завдання в чергу
+func adminChain(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		returnOK(w)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	retry := strings.HasSuffix(r.URL.Path, "/retry")
+	switch {
+	case !retry && r.Method == http.MethodGet:
+		name := "disabled"
+		if chain != nil {
+			name = chain.Name()
+		}
+		docs, err := fsCol("chainJobs").Where("status", "==", ChainFailed).Limit(100).Documents(ctx).GetAll()
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		failed := make([]map[string]any, 0, len(docs))
+		for _, d := range docs {
+			var job FSChainJob
+			if d.DataTo(&job) == nil {
+				failed = append(failed, map[string]any{
+					"id": job.ID, "kind": job.Kind, "tokenId": job.TokenID,
+					"attempts": job.Attempts, "lastError": job.LastError,
+				})
+			}
+		}
+		writeJSON(w, 200, map[string]any{"adapter": name, "failed": failed})
+
+	case retry && r.Method == http.MethodPost:
+		docs, err := fsCol("chainJobs").Where("status", "==", ChainFailed).Documents(ctx).GetAll()
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		for _, d := range docs {
+			if _, err := d.Ref.Update(ctx, []firestore.Update{
+				{Path: "status", Value: ChainPending},
+				{Path: "attempts", Value: 0},
+				{Path: "nextAt", Value: time.Now()},
+			}); err != nil {
+				writeJSON(w, 500, ErrorResp{err.Error()})
+				return
+			}
+		}
+		writeJSON(w, 200, map[string]any{"ok": true, "requeued": len(docs)})
+
+	default:
+		writeJSON(w, 405, ErrorResp{"Method not allowed"})
+	}
+}
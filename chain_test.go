@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeNode — JSON-RPC нода, що відповідає заготовками і запам'ятовує виклики
+type fakeNode struct {
+	results map[string]any
+	sent    []map[string]string
+}
+
+func (f *fakeNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     int64             `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	if req.Method == "eth_sendTransaction" {
+		var tx map[string]string
+		_ = json.Unmarshal(req.Params[0], &tx)
+		f.sent = append(f.sent, tx)
+	}
+	res, ok := f.results[req.Method]
+	if !ok {
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": req.ID, "error": map[string]any{"code": -32601, "message": "method not found"}})
+		return
+	}
+	if e, isErr := res.(error); isErr {
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": req.ID, "error": map[string]any{"code": -32000, "message": e.Error()}})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"id": req.ID, "result": res})
+}
+
+type rpcErr string
+
+func (e rpcErr) Error() string { return string(e) }
+
+func newFakeChain(t *testing.T, f *fakeNode) *evmChain {
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	from := "0x" + strings.Repeat("ab", 20)
+	return &evmChain{rpc: &jsonRPC{url: srv.URL, hc: srv.Client()}, from: from, anchorTo: from}
+}
+
+func TestEVMSendReusesNonceAndBumpsGas(t *testing.T) {
+	f := &fakeNode{results: map[string]any{
+		"eth_gasPrice":        "0x3b9aca00", // 1 gwei
+		"eth_sendTransaction": "0x" + strings.Repeat("11", 32),
+	}}
+	c := newFakeChain(t, f)
+	ctx := context.Background()
+	job := FSChainJob{ID: "transfer-7-12", Kind: ChainJobTransfer, TokenID: 7, Nonce: 42, HasNonce: true}
+
+	hash, price, err := c.Send(ctx, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != "0x"+strings.Repeat("11", 32) || price != 1_000_000_000 {
+		t.Fatalf("send = %s, %d", hash, price)
+	}
+	if f.sent[0]["nonce"] != "0x2a" || f.sent[0]["gasPrice"] != "0x3b9aca00" {
+		t.Fatalf("first tx = %v", f.sent[0])
+	}
+
+	// заміна: той самий nonce, ціна вища за попередню навіть якщо ринкова не зросла
+	job.GasPrice = price
+	if _, price2, err := c.Send(ctx, job); err != nil || price2 <= price*110/100 {
+		t.Fatalf("replacement price %d (err %v), want > 110%% of %d", price2, err, price)
+	}
+	if f.sent[1]["nonce"] != "0x2a" {
+		t.Fatalf("replacement nonce = %s, want 0x2a", f.sent[1]["nonce"])
+	}
+	if f.sent[0]["data"] != f.sent[1]["data"] || f.sent[0]["to"] != f.sent[1]["to"] {
+		t.Fatal("replacement must carry the same payload")
+	}
+
+	job.HasNonce = false
+	if _, _, err := c.Send(ctx, job); err == nil {
+		t.Fatal("send without a reserved nonce must fail")
+	}
+
+	// відмова ноди повертає ціну, щоб наступна заміна її перевищила
+	f.results["eth_sendTransaction"] = rpcErr("replacement transaction underpriced")
+	job.HasNonce, job.GasPrice = true, 5_000_000_000
+	if _, p, err := c.Send(ctx, job); err == nil || p != 5_750_000_001 {
+		t.Fatalf("underpriced send = %d, %v", p, err)
+	}
+}
+
+func TestEVMNonceLookups(t *testing.T) {
+	f := &fakeNode{results: map[string]any{
+		"eth_getTransactionCount":   "0x1f",
+		"eth_getTransactionByHash":  map[string]any{"nonce": "0x9", "hash": "0x01"},
+		"eth_getTransactionReceipt": map[string]any{"status": "0x0"},
+	}}
+	c := newFakeChain(t, f)
+	ctx := context.Background()
+	if n, err := c.PendingNonce(ctx); err != nil || n != 31 {
+		t.Fatalf("PendingNonce = %d, %v", n, err)
+	}
+	if n, found, err := c.TxNonce(ctx, "0x01"); err != nil || !found || n != 9 {
+		t.Fatalf("TxNonce = %d, %v, %v", n, found, err)
+	}
+	if done, ok, err := c.Receipt(ctx, "0x01"); err != nil || !done || ok {
+		t.Fatalf("reverted receipt = %v, %v, %v", done, ok, err)
+	}
+
+	f.results["eth_getTransactionByHash"] = nil
+	f.results["eth_getTransactionReceipt"] = nil
+	if _, found, err := c.TxNonce(ctx, "0x02"); err != nil || found {
+		t.Fatalf("unknown tx found=%v err=%v", found, err)
+	}
+	if done, _, err := c.Receipt(ctx, "0x02"); err != nil || done {
+		t.Fatalf("pending receipt done=%v err=%v", done, err)
+	}
+}
+
+func TestChainAnchorCommitsToLedgerEntry(t *testing.T) {
+	e := LedgerEntry{Seq: 12, Kind: LedgerTransfer, TokenID: 7, Actor: "u_1", At: 1700000000000, PrevHash: ledgerGenesis}
+	e.Hash = ledgerHash(e)
+	job := FSChainJob{ID: "transfer-7-12", Kind: ChainJobTransfer, TokenID: 7, LedgerSeq: e.Seq, LedgerHash: e.Hash}
+
+	got := chainAnchor(job)
+	want := append([]byte("MARKI"), 0, 0, 0, 0, 0, 0, 0, 12)
+	h, _ := hex.DecodeString(e.Hash)
+	want = append(want, h...)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("anchor = %x, want %x", got, want)
+	}
+
+	// завдання без хешу журналу — старий формат, той самий розмір префікса
+	legacy := chainAnchor(FSChainJob{ID: "mint-7", Kind: ChainJobMint, TokenID: 7, CID: "bafy"})
+	if !bytes.HasPrefix(legacy, []byte("MARKI")) || len(legacy) != 5+32 {
+		t.Fatalf("legacy anchor = %x", legacy)
+	}
+}
+
+func TestAbiSafeMint(t *testing.T) {
+	// відомий селектор ERC-20 transfer перевіряє сам keccak256
+	if got := hex.EncodeToString(keccak256([]byte("transfer(address,uint256)"))[:4]); got != "a9059cbb" {
+		t.Fatalf("keccak selector = %s", got)
+	}
+	to := "0x" + strings.Repeat("ab", 20)
+	b := abiSafeMint(to, 258, "ipfs://bafy")
+	if len(b) != 4+5*32 {
+		t.Fatalf("calldata length = %d", len(b))
+	}
+	word := func(i int) string { return hex.EncodeToString(b[4+32*i : 4+32*(i+1)]) }
+	if word(0) != strings.Repeat("00", 12)+strings.Repeat("ab", 20) {
+		t.Errorf("address word = %s", word(0))
+	}
+	if word(1) != strings.Repeat("00", 30)+"0102" {
+		t.Errorf("tokenId word = %s", word(1))
+	}
+	if word(2) != strings.Repeat("00", 31)+"60" || word(3) != strings.Repeat("00", 31)+"0b" {
+		t.Errorf("string offset/length = %s / %s", word(2), word(3))
+	}
+	if !bytes.HasPrefix(b[4+4*32:], []byte("ipfs://bafy")) {
+		t.Error("uri bytes missing")
+	}
+}
@@ -0,0 +1,389 @@
+// cid.go — IPLD для метаданих продуктів: канонічне DAG-CBOR кодування,
+// CIDv1 (dag-cbor + sha2-256, multibase base32) і експорт CAR v1.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	codecDagCBOR = 0x71
+	mhSHA256     = 0x12
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// CID — бінарна форма CIDv1: <version><codec><multihash>
+type CID []byte
+
+func newCID(codec uint64, data []byte) CID {
+	sum := sha256.Sum256(data)
+	var b []byte
+	b = binary.AppendUvarint(b, 1)
+	b = binary.AppendUvarint(b, codec)
+	b = binary.AppendUvarint(b, mhSHA256)
+	b = binary.AppendUvarint(b, uint64(len(sum)))
+	return append(b, sum[:]...)
+}
+
+// String — multibase base32 (нижній регістр, префікс "b"), як у ipfs cid format -v 1
+func (c CID) String() string {
+	return "b" + strings.ToLower(b32.EncodeToString(c))
+}
+
+func parseCID(s string) (CID, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || s[0] != 'b' {
+		return nil, fmt.Errorf("unsupported multibase")
+	}
+	raw, err := b32.DecodeString(strings.ToUpper(s[1:]))
+	if err != nil {
+		return nil, fmt.Errorf("bad base32: %v", err)
+	}
+	rd := bytes.NewReader(raw)
+	ver, err := binary.ReadUvarint(rd)
+	if err != nil || ver != 1 {
+		return nil, fmt.Errorf("only CIDv1 supported")
+	}
+	if _, err := binary.ReadUvarint(rd); err != nil {
+		return nil, fmt.Errorf("bad codec")
+	}
+	code, err := binary.ReadUvarint(rd)
+	if err != nil || code != mhSHA256 {
+		return nil, fmt.Errorf("only sha2-256 multihash supported")
+	}
+	n, err := binary.ReadUvarint(rd)
+	if err != nil || n != sha256.Size || rd.Len() != int(n) {
+		return nil, fmt.Errorf("bad multihash length")
+	}
+	return CID(raw), nil
+}
+
+// ================== DAG-CBOR ==================
+
+// cidLink кодується як CBOR tag 42 (посилання IPLD)
+type cidLink CID
+
+// dagCBOR — детерміноване кодування: мінімальні довжини, ключі мап
+// відсортовані спершу за довжиною, потім побайтово (RFC 7049 canonical).
+func dagCBOR(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := cborEncode(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func cborHead(buf *bytes.Buffer, major byte, n uint64) {
+	m := major << 5
+	switch {
+	case n < 24:
+		buf.WriteByte(m | byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(m | 24)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(m | 25)
+		_ = binary.Write(buf, binary.BigEndian, uint16(n))
+	case n <= math.MaxUint32:
+		buf.WriteByte(m | 26)
+		_ = binary.Write(buf, binary.BigEndian, uint32(n))
+	default:
+		buf.WriteByte(m | 27)
+		_ = binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+func cborEncode(buf *bytes.Buffer, v any) error {
+	switch x := v.(type) {
+	case nil:
+		buf.WriteByte(0xf6)
+	case bool:
+		if x {
+			buf.WriteByte(0xf5)
+		} else {
+			buf.WriteByte(0xf4)
+		}
+	case int:
+		return cborEncode(buf, int64(x))
+	case int64:
+		if x >= 0 {
+			cborHead(buf, 0, uint64(x))
+		} else {
+			cborHead(buf, 1, uint64(-(x + 1)))
+		}
+	case string:
+		cborHead(buf, 3, uint64(len(x)))
+		buf.WriteString(x)
+	case []byte:
+		cborHead(buf, 2, uint64(len(x)))
+		buf.Write(x)
+	case cidLink:
+		cborHead(buf, 6, 42)
+		cborHead(buf, 2, uint64(len(x)+1))
+		buf.WriteByte(0x00) // multibase identity prefix
+		buf.Write(x)
+	case []string:
+		cborHead(buf, 4, uint64(len(x)))
+		for _, s := range x {
+			if err := cborEncode(buf, s); err != nil {
+				return err
+			}
+		}
+	case []any:
+		cborHead(buf, 4, uint64(len(x)))
+		for _, e := range x {
+			if err := cborEncode(buf, e); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if len(keys[i]) != len(keys[j]) {
+				return len(keys[i]) < len(keys[j])
+			}
+			return keys[i] < keys[j]
+		})
+		cborHead(buf, 5, uint64(len(x)))
+		for _, k := range keys {
+			if err := cborEncode(buf, k); err != nil {
+				return err
+			}
+			if err := cborEncode(buf, x[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("dag-cbor: unsupported type %T", v)
+	}
+	return nil
+}
+
+// ================== PRODUCT BLOCKS ==================
+
+// productNode — публічний IPLD-документ продукту. Замість серійника
+// кладемо його комітмент (cidSerial), бо блоки пінуються на сторонніх
+// IPFS-нодах. Ключ лишається "serialHash", щоб старі CID відтворювались.
+func productNode(meta Metadata, serialHash string) map[string]any {
+	certs := meta.Certificates
+	if certs == nil {
+		certs = []string{}
+	}
+	return map[string]any{
+		"name":           meta.Name,
+		"manufacturedAt": meta.ManufacturedAt,
+		"serialHash":     serialHash,
+		"certificates":   certs,
+		"image":          meta.Image,
+		"version":        int64(meta.Version),
+	}
+}
+
+func productBlock(meta Metadata, serialHash string) ([]byte, CID, error) {
+	data, err := dagCBOR(productNode(meta, serialHash))
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, newCID(codecDagCBOR, data), nil
+}
+
+// metadataCID — те, що зберігається в Product.IPFSHash
+func metadataCID(meta Metadata, serialHash string) string {
+	_, c, err := productBlock(meta, serialHash)
+	if err != nil {
+		return ""
+	}
+	return c.String()
+}
+
+// ================== CAR v1 ==================
+
+type carBlock struct {
+	CID  CID
+	Data []byte
+}
+
+func writeCAR(buf *bytes.Buffer, roots []CID, blocks []carBlock) error {
+	rs := make([]any, 0, len(roots))
+	for _, r := range roots {
+		rs = append(rs, cidLink(r))
+	}
+	hdr, err := dagCBOR(map[string]any{"roots": rs, "version": int64(1)})
+	if err != nil {
+		return err
+	}
+	buf.Write(binary.AppendUvarint(nil, uint64(len(hdr))))
+	buf.Write(hdr)
+	for _, b := range blocks {
+		buf.Write(binary.AppendUvarint(nil, uint64(len(b.CID)+len(b.Data))))
+		buf.Write(b.CID)
+		buf.Write(b.Data)
+	}
+	return nil
+}
+
+// batchCAR збирає CAR: кореневий вузол партії з посиланнями на блоки продуктів
+func batchCAR(b Batch, products []Product) ([]byte, CID, error) {
+	sort.Slice(products, func(i, j int) bool { return products[i].TokenID < products[j].TokenID })
+	var blocks []carBlock
+	links := make([]any, 0, len(products))
+	for _, p := range products {
+		data, c, err := productBlock(p.Meta, cidSerial(p))
+		if err != nil {
+			return nil, nil, err
+		}
+		blocks = append(blocks, carBlock{CID: c, Data: data})
+		links = append(links, map[string]any{
+			"tokenId": p.TokenID,
+			"meta":    cidLink(c),
+		})
+	}
+	root, err := dagCBOR(map[string]any{
+		"batchId":  b.ID,
+		"title":    b.Title,
+		"products": links,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	rootCID := newCID(codecDagCBOR, root)
+	blocks = append([]carBlock{{CID: rootCID, Data: root}}, blocks...)
+
+	var buf bytes.Buffer
+	if err := writeCAR(&buf, []CID{rootCID}, blocks); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), rootCID, nil
+}
+
+// ================== HTTP ==================
+
+// GET /api/ipfs/{cid} — сирий блок (application/vnd.ipld.raw) або CAR з одним
+// блоком, якщо Accept: application/vnd.ipld.car чи ?format=car
+func ipfsBlock(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		returnOK(w)
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeJSON(w, 405, ErrorResp{"Method not allowed"})
+		return
+	}
+	c, err := parseCID(strings.TrimPrefix(r.URL.Path, "/api/ipfs/"))
+	if err != nil {
+		writeJSON(w, 400, ErrorResp{"bad cid: " + err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+	p, ok, err := fsGetProductByCID(ctx, c.String())
+	if err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+	if !ok {
+		writeJSON(w, 404, ErrorResp{"block not found"})
+		return
+	}
+	data, got, err := productBlock(p.Meta, cidSerial(p))
+	if err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+	if !bytes.Equal(got, c) {
+		// метадані змінились після мінту — блок з таким CID більше не відтворити
+		writeJSON(w, 410, ErrorResp{"block no longer reproducible"})
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", `"`+c.String()+`"`)
+	if r.URL.Query().Get("format") == "car" || strings.Contains(r.Header.Get("Accept"), "application/vnd.ipld.car") {
+		var buf bytes.Buffer
+		if err := writeCAR(&buf, []CID{c}, []carBlock{{CID: c, Data: data}}); err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.ipld.car; version=1")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+c.String()+`.car"`)
+		_, _ = w.Write(buf.Bytes())
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.ipld.raw")
+	_, _ = w.Write(data)
+}
+
+// GET /api/manufacturer/batches/{id}/car        — CAR-архів усіх метаданих партії
+// GET /api/manufacturer/batches/{id}/labels.pdf — аркуші етикеток партії
+// (POST для партій з анти-копі QR)
+func manufacturerBatchActions(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		returnOK(w)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/manufacturer/batches/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		writeJSON(w, 404, ErrorResp{"not found"})
+		return
+	}
+	if !(parts[1] == "car" && r.Method == http.MethodGet) &&
+		!(parts[1] == "labels.pdf" && (r.Method == http.MethodGet || r.Method == http.MethodPost)) {
+		writeJSON(w, 405, ErrorResp{"Method not allowed"})
+		return
+	}
+	u := currentUser(r)
+	if u == "" {
+		writeJSON(w, 401, ErrorResp{"missing user"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+	b, ok, err := fsGetBatch(ctx, parts[0])
+	if err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+	if !ok {
+		writeJSON(w, 404, ErrorResp{"batch not found"})
+		return
+	}
+	if !strings.EqualFold(b.Owner, u) && !canBrand(ctx, u, b.BrandSlug, PermBrandBatches) {
+		writeJSON(w, 403, ErrorResp{"forbidden"})
+		return
+	}
+	list, err := fsListProductsByBatch(ctx, b.ID)
+	if err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+	if parts[1] == "labels.pdf" {
+		writeLabelSheet(ctx, w, r, list, "labels-"+b.ID+".pdf")
+		return
+	}
+	car, root, err := batchCAR(b, list)
+	if err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.ipld.car; version=1")
+	w.Header().Set("Content-Disposition", `attachment; filename="batch-`+b.ID+`.car"`)
+	w.Header().Set("X-Ipfs-Roots", root.String())
+	_, _ = w.Write(car)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestCIDKnownValues(t *testing.T) {
+	empty, err := dagCBOR(map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		codec uint64
+		data  []byte
+		want  string
+	}{
+		// ipfs add --cid-version 1 --raw-leaves: "hello world" без переводу рядка
+		{"raw hello world", 0x55, []byte("hello world"), "bafkreifzjut3te2nhyekklss27nh3k72ysco7y32koao5eei66wof36n5e"},
+		// порожня мапа dag-cbor (0xa0)
+		{"dag-cbor empty map", codecDagCBOR, empty, "bafyreigbtj4x7ip5legnfznufuopl4sg4knzc2cof6duas4b3q2fy6swua"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCID(tt.codec, tt.data)
+			if got := c.String(); got != tt.want {
+				t.Fatalf("CID = %s, want %s", got, tt.want)
+			}
+			back, err := parseCID(tt.want)
+			if err != nil {
+				t.Fatalf("parseCID: %v", err)
+			}
+			if !bytes.Equal(back, c) {
+				t.Fatalf("parseCID round trip mismatch")
+			}
+		})
+	}
+}
+
+func TestParseCIDRejects(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"QmT78zSuBmuS4z925WZfrqQ1qHaJ56DQaTfyMUF7F8ff5o", // CIDv0 (base58btc)
+		"zb2rhe5P4gXftAwvA4eXQ5HJwsER2owDyS9sKaQRRVQPn93bA",
+		"bafkreifzjut3te2nhyekklss27nh3k72ysco7y32koao5eei66wof36n5", // обрізаний multihash
+		"b!!!!",
+	} {
+		if _, err := parseCID(s); err == nil {
+			t.Errorf("parseCID(%q) accepted", s)
+		}
+	}
+}
+
+func TestDagCBORCanonical(t *testing.T) {
+	link := newCID(0x55, []byte("hello world"))
+	tests := []struct {
+		name string
+		in   any
+		want string
+	}{
+		{"uint 23", int64(23), "17"},
+		{"uint 24", int64(24), "1818"},
+		{"uint 255", int64(255), "18ff"},
+		{"uint 256", int64(256), "190100"},
+		{"uint 65536", int64(65536), "1a00010000"},
+		{"uint 2^32", int64(1 << 32), "1b0000000100000000"},
+		{"neg -1", int64(-1), "20"},
+		{"neg -25", int64(-25), "3818"},
+		{"int", 10, "0a"},
+		{"null", nil, "f6"},
+		{"bool", []any{true, false}, "82f5f4"},
+		{"string", "IETF", "6449455446"},
+		{"bytes", []byte{1, 2}, "420102"},
+		{"strings", []string{"a"}, "816161"},
+		// ключі: спершу коротші, потім побайтово
+		{"key order", map[string]any{"b": int64(1), "aa": int64(2), "a": int64(3)}, "a3616103616201626161" + "02"},
+		{"nested", map[string]any{"z": map[string]any{"y": nil}, "a": []any{}}, "a2616180617aa16179f6"},
+		{"link", cidLink(link), "d82a582500" + hex.EncodeToString(link)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := dagCBOR(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hex.EncodeToString(got) != tt.want {
+				t.Fatalf("dagCBOR = %x, want %s", got, tt.want)
+			}
+		})
+	}
+	if _, err := dagCBOR(3.14); err == nil {
+		t.Fatal("floats must be rejected")
+	}
+}
+
+// порядок вставки в мапу не впливає на CID метаданих
+func TestMetadataCIDDeterministic(t *testing.T) {
+	meta := Metadata{Name: "Sneaker", ManufacturedAt: "2024-08-12", Certificates: []string{"ISO"}, Version: 1}
+	a := metadataCID(meta, "abc")
+	for i := 0; i < 20; i++ {
+		if b := metadataCID(meta, "abc"); b != a {
+			t.Fatalf("CID changed between runs: %s vs %s", a, b)
+		}
+	}
+	if metadataCID(meta, "abd") == a {
+		t.Fatal("serial hash must affect the CID")
+	}
+	if _, err := parseCID(a); err != nil {
+		t.Fatalf("metadataCID is not a valid CID: %v", err)
+	}
+}
+
+// ротація HMAC-ключа не змінює CID: у блоці лежить комітмент, а не HMAC
+func TestCIDStableAcrossSerialKeyRotation(t *testing.T) {
+	meta := Metadata{Name: "Sneaker", ManufacturedAt: "2024-08-12", Serial: "ABCD-EFGH-JKMN-PQR0", Version: 1}
+	commit, salt, err := serialCommitment(meta.Serial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit != sha256Hex(salt+meta.Serial) || len(salt) != 32 {
+		t.Fatalf("commitment %s does not match salt %s", commit, salt)
+	}
+	if again, salt2, _ := serialCommitment(meta.Serial); again == commit || salt2 == salt {
+		t.Fatal("salt must be random per product")
+	}
+	p := Product{Meta: meta, SerialHash: "hmac-k1", SerialKeyID: "k1", SerialCommit: commit}
+	want := metadataCID(meta, cidSerial(p))
+	p.SerialHash, p.SerialKeyID = "hmac-k2", "k2"
+	if got := metadataCID(meta, cidSerial(p)); got != want {
+		t.Fatalf("CID changed after rotation: %s -> %s", want, got)
+	}
+
+	// старий продукт: CID рахувався з HMAC, після ротації він заморожений у serialCommit
+	legacy := Product{Meta: meta, SerialHash: "hmac-k1", SerialKeyID: "k1"}
+	want = metadataCID(meta, cidSerial(legacy))
+	legacy.SerialCommit, legacy.SerialHash = legacy.SerialHash, "hmac-k2"
+	if got := metadataCID(meta, cidSerial(legacy)); got != want {
+		t.Fatalf("legacy CID changed after rotation: %s -> %s", want, got)
+	}
+}
@@ -0,0 +1,194 @@
+// devauth.go — підписані дев-ідентичності замість X-User: сервер сам видає
+// короткоживучі HS256-токени для тестових email/ролей (локальний ендпоінт
+// або `marki-secure dev-token`), і вони перевіряються як звичайний провайдер.
+//
+// DEV_AUTH=true вмикає режим; DEV_AUTH_SECRET — спільний секрет (без нього
+// ключ ефемерний, CLI-токени сервер не прийме, а ендпоінт вимкнений).
+// Ендпоінт вимагає секрет у заголовку X-Dev-Auth-Secret — loopback сам по собі
+// нічого не доводить за проксі на тому ж хості — і видає лише токени без ролі
+// персоналу; ролі — тільки через CLI.
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	devIssuer   = "marki-dev"
+	devMaxTTL   = 12 * time.Hour
+	devDefTTL   = time.Hour
+	devTokenAlg = "HS256"
+)
+
+var (
+	devAuth = strings.EqualFold(strings.TrimSpace(os.Getenv("DEV_AUTH")), "true")
+	// devSecretSet — секрет заданий явно; інакше HTTP-видача вимкнена
+	devSecretSet = strings.TrimSpace(os.Getenv("DEV_AUTH_SECRET")) != ""
+	devSecret    = func() []byte {
+		if s := strings.TrimSpace(os.Getenv("DEV_AUTH_SECRET")); s != "" {
+			return []byte(s)
+		}
+		b := make([]byte, 32)
+		_, _ = rand.Read(b)
+		return b
+	}()
+)
+
+type devClaims struct {
+	Email string `json:"email"`
+	Role  string `json:"role,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func issueDevToken(email, role string, ttl time.Duration) (string, time.Time, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if !strings.Contains(email, "@") {
+		return "", time.Time{}, errors.New("email required")
+	}
+	if role != "" {
+		if _, ok := validStaffRole(role); !ok {
+			return "", time.Time{}, fmt.Errorf("bad role %q", role)
+		}
+	}
+	if ttl <= 0 {
+		ttl = devDefTTL
+	}
+	now := time.Now()
+	exp := now.Add(min(ttl, devMaxTTL))
+	tok, err := jwt.NewWithClaims(jwt.SigningMethodHS256, devClaims{
+		Email: email,
+		Role:  strings.ToLower(strings.TrimSpace(role)),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    devIssuer,
+			Audience:  jwt.ClaimStrings{devIssuer},
+			Subject:   "dev:" + email,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(exp),
+		},
+	}).SignedString(devSecret)
+	return tok, exp, err
+}
+
+// ================== PROVIDER ==================
+
+type devProvider struct{}
+
+func (devProvider) Name() string { return "dev" }
+
+func (devProvider) Accepts(iss string) bool { return iss == devIssuer }
+
+func (devProvider) Verify(_ context.Context, tok string) (Identity, error) {
+	var c devClaims
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{devTokenAlg}))
+	if _, err := parser.ParseWithClaims(tok, &c, func(*jwt.Token) (any, error) { return devSecret, nil }); err != nil {
+		return Identity{}, err
+	}
+	if !c.VerifyIssuer(devIssuer, true) || !c.VerifyAudience(devIssuer, true) || c.ExpiresAt == nil {
+		return Identity{}, errors.New("bad dev token")
+	}
+	id := Identity{
+		Provider:      "dev",
+		Subject:       c.Subject,
+		Email:         strings.ToLower(c.Email),
+		EmailVerified: true, // дев-токени видає сам сервер
+		Expires:       c.ExpiresAt.Time,
+	}
+	if r, ok := validStaffRole(c.Role); ok {
+		id.Staff = r
+		id.Roles = []string{c.Role}
+	}
+	return id, nil
+}
+
+// ================== HTTP ==================
+
+func isLoopback(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback() && r.Header.Get("X-Forwarded-For") == ""
+}
+
+// devCaller — локальний виклик із правильним X-Dev-Auth-Secret
+func devCaller(r *http.Request) bool {
+	got := []byte(strings.TrimSpace(r.Header.Get("X-Dev-Auth-Secret")))
+	return devSecretSet && isLoopback(r) && subtle.ConstantTimeCompare(got, devSecret) == 1
+}
+
+// POST /api/dev/token {email, ttlMinutes?} — лише в DEV_AUTH, з loopback і
+// з X-Dev-Auth-Secret; ролі персоналу — лише через CLI
+func devToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		returnOK(w)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeJSON(w, 405, ErrorResp{"Method not allowed"})
+		return
+	}
+	if !devCaller(r) {
+		writeJSON(w, 403, ErrorResp{"dev tokens require a local caller and X-Dev-Auth-Secret"})
+		return
+	}
+	var body struct {
+		Email      string `json:"email"`
+		Role       string `json:"role"`
+		TTLMinutes int    `json:"ttlMinutes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, 400, ErrorResp{"invalid json"})
+		return
+	}
+	if strings.TrimSpace(body.Role) != "" {
+		writeJSON(w, 403, ErrorResp{"staff roles are issued via `marki-secure dev-token` only"})
+		return
+	}
+	tok, exp, err := issueDevToken(body.Email, "", time.Duration(body.TTLMinutes)*time.Minute)
+	if err != nil {
+		writeJSON(w, 400, ErrorResp{err.Error()})
+		return
+	}
+	writeJSON(w, 200, map[string]any{"token": tok, "expiresAt": exp.UnixMilli()})
+}
+
+// devTokenCLI — marki-secure dev-token <email> [role] [ttl]
+func devTokenCLI(args []string) {
+	if os.Getenv("DEV_AUTH_SECRET") == "" {
+		log.Fatal("DEV_AUTH_SECRET must be set (the server has to share it)")
+	}
+	if len(args) < 1 {
+		log.Fatal("usage: marki-secure dev-token <email> [role] [ttl]")
+	}
+	role := ""
+	if len(args) > 1 {
+		role = args[1]
+	}
+	var ttl time.Duration
+	if len(args) > 2 {
+		var err error
+		if ttl, err = time.ParseDuration(args[2]); err != nil {
+			log.Fatal(err)
+		}
+	}
+	tok, exp, err := issueDevToken(args[0], role, ttl)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s\n(valid until %s)\n", tok, exp.UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDevTokenEndpoint(t *testing.T) {
+	secret, set := devSecret, devSecretSet
+	t.Cleanup(func() { devSecret, devSecretSet = secret, set })
+	devSecret, devSecretSet = []byte("s3cret"), true
+
+	call := func(remote, hdr, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/api/dev/token", strings.NewReader(body))
+		r.RemoteAddr = remote
+		if hdr != "" {
+			r.Header.Set("X-Dev-Auth-Secret", hdr)
+		}
+		w := httptest.NewRecorder()
+		devToken(w, r)
+		return w
+	}
+
+	w := call("127.0.0.1:5000", "s3cret", `{"email":"dev@example.com"}`)
+	if w.Code != 200 {
+		t.Fatalf("valid call = %d %s", w.Code, w.Body)
+	}
+	var out struct{ Token string }
+	_ = json.Unmarshal(w.Body.Bytes(), &out)
+	id, err := devProvider{}.Verify(context.Background(), out.Token)
+	if err != nil || id.Email != "dev@example.com" || id.Staff != "" {
+		t.Fatalf("issued token: %+v, %v", id, err)
+	}
+
+	for name, w := range map[string]*httptest.ResponseRecorder{
+		"no secret":    call("127.0.0.1:5000", "", `{"email":"dev@example.com"}`),
+		"wrong secret": call("127.0.0.1:5000", "nope", `{"email":"dev@example.com"}`),
+		"remote":       call("203.0.113.9:5000", "s3cret", `{"email":"dev@example.com"}`),
+		"staff role":   call("127.0.0.1:5000", "s3cret", `{"email":"dev@example.com","role":"super-admin"}`),
+	} {
+		if w.Code != 403 {
+			t.Errorf("%s = %d, want 403", name, w.Code)
+		}
+	}
+
+	// без DEV_AUTH_SECRET ендпоінт вимкнений навіть з правильним ефемерним ключем
+	devSecretSet = false
+	if w := call("127.0.0.1:5000", "s3cret", `{"email":"dev@example.com"}`); w.Code != 403 {
+		t.Fatalf("unset secret = %d, want 403", w.Code)
+	}
+}
@@ -9,6 +9,7 @@ const verifySection = qs("#verifySection");
 
 const grantForm = qs("#grantAdminForm");
 const grantOut = qs("#grantOut");
+const adminsList = qs("#adminsList");
 
 const brandForm = qs("#createBrandForUserForm");
 const brandOut = qs("#brandCreateOut");
@@ -16,6 +17,17 @@ const brandOut = qs("#brandCreateOut");
 const verifyForm = qs("#verifyBrandForm");
 const verifyOut = qs("#verifyOut");
 
+const brandStatusSection = qs("#brandStatusSection");
+const brandStatusForm = qs("#brandStatusForm");
+const brandStatusOut = qs("#brandStatusOut");
+
+const reservedSection = qs("#reservedSection");
+const reservedForm = qs("#reservedForm");
+const reservedList = qs("#reservedList");
+
+const approvalsSection = qs("#approvalsSection");
+const approvalsList = qs("#approvalsList");
+
 const logoutBtn = qs("#logoutBtn");
 const bootstrapBtn = qs("#bootstrapBtn");
 
@@ -23,18 +35,31 @@ Auth.onChange(async (u) => {
   logoutBtn?.classList.toggle("hidden", !u);
   if (!u) {
     meInfo.textContent = "Увійдіть як адмін.";
-    [grantSection, brandSection, verifySection].forEach(s => s && (s.style.display = "none"));
+    [grantSection, brandSection, verifySection, brandStatusSection, reservedSection, approvalsSection].forEach(s => s && (s.style.display = "none"));
     return;
   }
   try {
     const me = await api("/api/me");
-    if (!me.isAdmin) {
+    const perms = me.permissions || [];
+    if (!perms.length) {
       meInfo.textContent = "Доступ заборонено (не адмін).";
-      [grantSection, brandSection, verifySection].forEach(s => s && (s.style.display = "none"));
+      [grantSection, brandSection, verifySection, brandStatusSection, reservedSection, approvalsSection].forEach(s => s && (s.style.display = "none"));
       return;
     }
-    meInfo.innerHTML = `<b>${me.email}</b> — Admin`;
-    [grantSection, brandSection, verifySection].forEach(s => s && (s.style.display = ""));
+    meInfo.innerHTML = `<b>${me.email}</b> — ${me.role}`;
+    // секції за дозволами ролі
+    const show = (s, perm) => s && (s.style.display = perms.includes(perm) ? "" : "none");
+    show(grantSection, "admins.manage");
+    show(brandSection, "brands.create");
+    show(verifySection, "brands.verify");
+    show(brandStatusSection, "brands.verify");
+    show(reservedSection, "brands.verify");
+    if (perms.includes("brands.verify")) loadReserved();
+    if (perms.includes("admins.manage")) loadAdmins();
+    if (["admins.manage", "brands.verify", "brand.revoke"].some(p => perms.includes(p))) {
+      approvalsSection.style.display = "";
+      loadApprovals();
+    }
   } catch (e) {
     meInfo.textContent = e.message || "Помилка /api/me";
   }
@@ -44,7 +69,9 @@ logoutBtn?.addEventListener("click", () => Auth.signOut());
 
 bootstrapBtn?.addEventListener("click", async () => {
   try {
-    const res = await api("/api/admins/bootstrap", { method: "POST" });
+    const token = (prompt("Bootstrap-токен (з логу сервера або `marki-secure bootstrap-token`):") || "").trim();
+    if (!token) return;
+    const res = await api("/api/admins/bootstrap", { method: "POST", body: { token } });
     alert("OK: " + JSON.stringify(res));
     location.reload();
   } catch (e) {
@@ -56,14 +83,39 @@ grantForm?.addEventListener("submit", async (ev) => {
   ev.preventDefault();
   const fd = new FormData(grantForm);
   const email = (fd.get("email") || "").toString().trim().toLowerCase();
+  const role = (fd.get("role") || "super-admin").toString();
   grantOut.textContent = "Надання прав…";
   try {
-    await api("/api/admins/grant", { method: "POST", body: { email } });
-    grantOut.textContent = "Готово.";
+    const res = await api("/api/admins/grant", { method: "POST", body: { email, role } });
+    grantOut.textContent = res.pending ? "Очікує погодження іншим адміном." : "Готово.";
     grantForm.reset();
+    loadAdmins();
   } catch (e) { grantOut.textContent = e.message || "Помилка"; }
 });
 
+async function loadAdmins() {
+  if (!adminsList) return;
+  try {
+    const { admins = [] } = await api("/api/admins");
+    adminsList.innerHTML = admins.map(a => `
+      <div class="row">
+        <span><b>${a.email || a.userId}</b> — ${a.role}${a.grantedBy ? ` <span class="muted">(від ${a.grantedBy})</span>` : ""}</span>
+        <button class="btn secondary" data-revoke="${a.userId}" data-email="${a.email || a.userId}">Відкликати</button>
+      </div>`).join("") || `<div class="muted">Немає адмінів.</div>`;
+  } catch (e) { adminsList.textContent = e.message || "Помилка"; }
+}
+
+adminsList?.addEventListener("click", async (ev) => {
+  const userId = ev.target?.dataset?.revoke;
+  if (!userId || !confirm(`Відкликати права ${ev.target.dataset.email}?`)) return;
+  try {
+    const res = await api("/api/admins/revoke", { method: "POST", body: { userId } });
+    if (res.pending) alert("Очікує погодження іншим адміном.");
+    loadAdmins();
+    loadApprovals();
+  } catch (e) { alert(e.message || "Помилка"); }
+});
+
 brandForm?.addEventListener("submit", async (ev) => {
   ev.preventDefault();
   const fd = new FormData(brandForm);
@@ -72,7 +124,7 @@ brandForm?.addEventListener("submit", async (ev) => {
   brandOut.textContent = "Створення…";
   try {
     const res = await api("/api/admins/create-manufacturer", { method: "POST", body: { name, email } });
-    brandOut.textContent = `Створено: ${res.name} (${res.slug}) → ${res.owner}`;
+    brandOut.textContent = `Створено: ${res.name} (${res.slug}) → ${email}`;
     brandForm.reset();
   } catch (e) { brandOut.textContent = e.message || "Помилка"; }
 });
@@ -85,7 +137,86 @@ verifyForm?.addEventListener("submit", async (ev) => {
   verifyOut.textContent = "Верифікація…";
   try {
     const res = await api(`/api/manufacturers/${encodeURIComponent(slug)}/verify`, { method: "POST" });
-    verifyOut.textContent = `Верифіковано: ${res.slug}`;
+    verifyOut.textContent = res.pending ? "Очікує погодження іншим адміном." : `Верифіковано: ${res.manufacturer?.slug || slug}`;
     verifyForm.reset();
   } catch (e) { verifyOut.textContent = e.message || "Помилка"; }
 });
+
+brandStatusForm?.addEventListener("click", async (ev) => {
+  const action = ev.target?.dataset?.status;
+  if (!action) return;
+  const fd = new FormData(brandStatusForm);
+  const slug = (fd.get("slug") || "").toString().trim();
+  const reason = (fd.get("reason") || "").toString().trim();
+  if (!slug) return;
+  const path = `/api/manufacturers/${encodeURIComponent(slug)}/${action}`;
+  try {
+    if (action === "status") {
+      const st = await api(path);
+      brandStatusOut.innerHTML = `<div><b>${st.slug}</b> — ${st.verified ? "верифіковано" : "не верифіковано"}${st.suspended ? `, призупинено (${st.suspendReason || "—"})` : ""}</div>` +
+        st.history.map(h => `<div class="row"><span>${new Date(h.at).toLocaleString()} — <b>${h.action}</b> ${h.actorEmail || h.actor}${h.reason ? ` <span class="muted">(${h.reason})</span>` : ""}</span></div>`).join("");
+      return;
+    }
+    if (!confirm(`${action} ${slug}?`)) return;
+    const res = await api(path, { method: "POST", body: { reason } });
+    brandStatusOut.textContent = res.pending ? "Очікує погодження іншим адміном." : "Готово.";
+    loadApprovals();
+  } catch (e) { brandStatusOut.textContent = e.message || "Помилка"; }
+});
+
+reservedForm?.addEventListener("submit", async (ev) => {
+  ev.preventDefault();
+  const fd = new FormData(reservedForm);
+  const name = (fd.get("name") || "").toString().trim();
+  const note = (fd.get("note") || "").toString().trim();
+  try {
+    await api("/api/admins/reserved-names", { method: "POST", body: { name, note } });
+    reservedForm.reset();
+    loadReserved();
+  } catch (e) { alert(e.message || "Помилка"); }
+});
+
+async function loadReserved() {
+  if (!reservedList) return;
+  try {
+    const list = await api("/api/admins/reserved-names");
+    reservedList.innerHTML = list.map(n => `
+      <div class="row">
+        <span><b>${n.slug}</b> — ${n.name}${n.note ? ` <span class="muted">(${n.note})</span>` : ""}</span>
+        <button class="btn secondary" data-unreserve="${n.slug}">Зняти</button>
+      </div>`).join("") || `<div class="muted">Список порожній.</div>`;
+  } catch (e) { reservedList.textContent = e.message || "Помилка"; }
+}
+
+reservedList?.addEventListener("click", async (ev) => {
+  const slug = ev.target?.dataset?.unreserve;
+  if (!slug || !confirm(`Зняти резерв ${slug}?`)) return;
+  try {
+    await api(`/api/admins/reserved-names/${encodeURIComponent(slug)}`, { method: "DELETE" });
+    loadReserved();
+  } catch (e) { alert(e.message || "Помилка"); }
+});
+
+async function loadApprovals() {
+  if (!approvalsList) return;
+  try {
+    const list = await api("/api/admins/approvals");
+    approvalsList.innerHTML = list.map(a => `
+      <div class="row">
+        <span><b>${a.op}</b> ${a.target} <span class="muted">(від ${a.requestedByEmail || a.requestedBy}, до ${new Date(a.expiresAt).toLocaleString()})</span></span>
+        <button class="btn" data-approve="${a.id}">Погодити</button>
+        <button class="btn secondary" data-reject="${a.id}">Відхилити</button>
+      </div>`).join("") || `<div class="muted">Немає запитів.</div>`;
+  } catch (e) { approvalsList.textContent = e.message || "Помилка"; }
+}
+
+approvalsList?.addEventListener("click", async (ev) => {
+  const { approve, reject } = ev.target?.dataset || {};
+  const id = approve || reject;
+  if (!id) return;
+  try {
+    await api(`/api/admins/approvals/${encodeURIComponent(id)}/${approve ? "approve" : "reject"}`, { method: "POST" });
+    loadApprovals();
+    loadAdmins();
+  } catch (e) { alert(e.message || "Помилка"); }
+});
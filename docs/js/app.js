@@ -1,8 +1,9 @@
 // app.js — легкий API-клієнт з Bearer-токеном Firebase
 import { Auth } from "./firebase.js";
 
-// якщо треба форс-дев для локальних тестів: постав true
-const DEV_ALLOW_XUSER_FALLBACK = false;
+// локальна розробка без Firebase: підписаний дев-токен (DEV_AUTH=true на сервері)
+// localStorage.setItem("marki_dev_token", "<token з POST /api/dev/token (X-Dev-Auth-Secret) або marki-secure dev-token>")
+const devToken = () => { try { return localStorage.getItem("marki_dev_token") || ""; } catch { return ""; } };
 
 export const qs  = (s, el = document) => el.querySelector(s);
 export const qsa = (s, el = document) => [...el.querySelectorAll(s)];
@@ -18,14 +19,10 @@ export async function api(path, opts = {}) {
     body = JSON.stringify(body);
   }
 
-  try {
-    const t = await Auth.idToken();
-    if (t) {
-      headers.set("Authorization", `Bearer ${t}`);
-    } else if (DEV_ALLOW_XUSER_FALLBACK && Auth.user?.email) {
-      headers.set("X-User", Auth.user.email);
-    }
-  } catch { /* no-op */ }
+  let t = "";
+  try { t = await Auth.idToken(); } catch { /* no-op */ }
+  t = t || devToken();
+  if (t) headers.set("Authorization", `Bearer ${t}`);
 
   const res = await fetch(url, { method, headers, body, credentials: "include" });
   const ct = res.headers.get("Content-Type") || "";
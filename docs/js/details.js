@@ -7,6 +7,9 @@ import QRCode from "https://esm.sh/qrcode@1.5.3";
 const qs = (s, d = document) => d.querySelector(s);
 const params = new URLSearchParams(location.search);
 const id = Number.parseInt(params.get("id") || "0", 10);
+// одноразовий код анти-копі QR: відправляємо лише при першому завантаженні
+let pendingCode = params.get("code");
+let codeResult = null;
 
 function setAuthButtons(user) {
   const loginBtn = qs("#loginBtn");
@@ -37,6 +40,42 @@ function friendlyScope(scope) {
     : "Публічний перегляд";
 }
 
+function friendlyCode(code) {
+  switch (code.status) {
+    case "used":    return "цей код перевірки вже використано — етикетка може бути копією.";
+    case "invalid": return "недійсний код перевірки.";
+    case "missing": return "посилання не містить коду перевірки.";
+    default:        return code.message || code.status;
+  }
+}
+
+// профіль бренду заповнює сам бренд — екрануємо
+function esc(s) {
+  return String(s ?? "").replace(/[&<>"']/g, c => ({ "&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;", "'": "&#39;" }[c]));
+}
+
+// хто виготовив товар: профіль бренду з відповіді перевірки
+function brandCard(brand) {
+  if (!brand) return "";
+  const p = brand.profile || {};
+  const links = [
+    p.website && `<a href="${esc(p.website)}" target="_blank" rel="noopener">Сайт</a>`,
+    p.supportUrl && `<a href="${esc(p.supportUrl)}" target="_blank" rel="noopener">Підтримка</a>`,
+    p.supportEmail && `<a href="mailto:${esc(p.supportEmail)}">${esc(p.supportEmail)}</a>`,
+    p.supportPhone && `<span>${esc(p.supportPhone)}</span>`,
+    ...Object.entries(p.social || {}).map(([k, v]) => `<a href="${esc(v)}" target="_blank" rel="noopener">${esc(k)}</a>`)
+  ].filter(Boolean).join(" · ");
+  return `<div class="product-side-card">
+      ${p.logo && p.logo.startsWith("https://") ? `<img src="${esc(p.logo)}" alt="" style="max-width:96px;max-height:96px;display:block;">` : ""}
+      <b>${esc(brand.name || brand.slug)}</b>
+      ${brand.suspended ? `<span class="tag tag-rejected">призупинено</span>`
+        : brand.verified ? `<span class="tag tag-approved">верифіковано</span>` : `<span class="tag tag-pending">не верифіковано</span>`}
+      ${p.country ? `<div class="muted tiny">${esc(p.country)}</div>` : ""}
+      ${p.description ? `<p class="small">${esc(p.description)}</p>` : ""}
+      ${links ? `<div class="tiny mt">${links}</div>` : ""}
+    </div>`;
+}
+
 function renderDetails(data) {
   const box = qs("#details");
   if (!box) return;
@@ -55,13 +94,29 @@ function renderDetails(data) {
     ? `<p><b>Serial:</b> ${meta.serial}</p>`
     : `<p class="muted tiny">Серійний номер приховано для публічного перегляду.</p>`;
 
+  const brandWarn = data.brandWarning
+    ? `<div class="product-side-card" style="border-color:#c0392b">
+         <b>Увага:</b> бренд призупинено — справжність товару не гарантується.
+       </div>`
+    : "";
+
+  const codeWarn = data.code && data.code.status !== "valid"
+    ? `<div class="product-side-card" style="border-color:#c0392b">
+         <b>Увага:</b> ${friendlyCode(data.code)}
+       </div>`
+    : "";
+
   box.innerHTML = `
+    ${codeWarn}
+    ${brandWarn}
     <div class="product-hero">
       <div class="product-main-col">
         <div class="product-header-row">
           <span class="tag">${friendlyState(data.state)}</span>
           ${data.brandSlug
-            ? `<span class="tag tag-approved">${data.brandSlug}</span>`
+            ? (data.brand?.verified
+                ? `<span class="tag tag-approved">${esc(data.brand.name || data.brandSlug)} ✓</span>`
+                : `<span class="tag tag-pending" title="Бренд не верифіковано">${esc(data.brand?.name || data.brandSlug)}</span>`)
             : ""
           }
         </div>
@@ -105,6 +160,7 @@ function renderDetails(data) {
 
       <div class="product-side">
         ${img}
+        ${brandCard(data.brand)}
         <div class="product-side-card">
           <canvas id="qr"></canvas>
           <div class="muted tiny mt">
@@ -194,7 +250,12 @@ async function load() {
     return;
   }
   try {
-    const data = await api(`/api/verify/${id}`);
+    const code = pendingCode;
+    pendingCode = null;
+    const q = code ? `?code=${encodeURIComponent(code)}` : "";
+    const data = await api(`/api/verify/${id}${q}`);
+    if (code) codeResult = data.code || null;
+    if (codeResult) data.code = codeResult;
     renderDetails(data);
     renderActions(data);
     await renderQR(location.href);
@@ -504,6 +504,37 @@ async function loadBatchesAndProducts() {
  * 9. КОМПАНІЯ (форма заявки + створення товарів бренду)
  * ========================================================*/
 
+// вибір бренду — лише якщо користувач може створювати товари в кількох
+function fillBrandSelects(me) {
+  const brands = (me?.brands || []).filter(b => ["owner", "manager", "operator"].includes(b.role));
+  const opts = brands.map(b => `<option value="${b.slug}">${b.name}</option>`).join("");
+  qsa("[data-brand-select]").forEach(el => {
+    el.style.display = brands.length > 1 ? "" : "none";
+    const sel = el.tagName === "SELECT" ? el : el.querySelector("select");
+    if (sel) sel.innerHTML = brands.length > 1 ? opts : "";
+  });
+}
+
+// профіль бренду редагує лише власник
+const profileSocials = ["instagram", "facebook", "tiktok", "telegram"];
+
+function fillBrandProfile(me) {
+  const card = qs("#brandProfileCard");
+  const form = qs("#brandProfileForm");
+  if (!card || !form) return;
+  const owned = (me?.brands || []).filter(b => b.role === "owner");
+  card.style.display = owned.length ? "" : "none";
+  form.slug.innerHTML = owned.map(b => `<option value="${b.slug}">${b.name}</option>`).join("");
+  const show = () => {
+    const p = owned.find(b => b.slug === form.slug.value)?.profile || {};
+    ["logo", "description", "website", "country", "supportEmail", "supportPhone", "supportUrl"]
+      .forEach(k => { form[k].value = p[k] || ""; });
+    profileSocials.forEach(k => { form[k].value = p.social?.[k] || ""; });
+  };
+  form.slug.onchange = show;
+  show();
+}
+
 async function loadBatchesSelect() {
   try {
     const list = await api("/api/manufacturer/batches");
@@ -564,7 +595,7 @@ function renderMessagesAdmin(list) {
           <span class="tag tag-${a.status}">${a.status}</span>
         </div>
         <div class="message-meta tiny muted">
-          ${a.fullName} &lt;${a.contactEmail || a.user}&gt; • ${a.country || "—"}
+          ${a.fullName} &lt;${a.contactEmail || a.userEmail || a.user}&gt; • ${a.country || "—"}
         </div>
         <div class="message-body tiny">
           VAT: ${a.vat || "—"} • Reg#: ${a.regNumber || "—"} • Site: ${a.site || "—"}
@@ -618,7 +649,7 @@ async function loadMessagesView(me) {
   const info = qs("#messagesInfo");
   if (!wrap) return;
 
-  if (!me.isAdmin) {
+  if (!(me.permissions || []).includes("applications.read")) {
     wrap.innerHTML = `<div class="muted small">
       Тут адміністратори бачать заявки на бренди.  
       Подати свою заявку можна у вкладці <b>Компанія</b>.
@@ -743,9 +774,10 @@ function wireManufacturerForms() {
   batchForm?.addEventListener("submit", async (e) => {
     e.preventDefault();
     const title = e.target.title?.value.trim();
+    const brandSlug = e.target.brandSlug?.value || undefined;
     if (!title) return alert("Вкажіть назву партії");
     try {
-      await api("/api/manufacturer/batches", { method: "POST", body: { title } });
+      await api("/api/manufacturer/batches", { method: "POST", body: { title, brandSlug } });
       e.target.reset();
       await loadBatchesSelect();
       trackEvent("create_batch", { email: lastMe?.email });
@@ -765,7 +797,8 @@ function wireManufacturerForms() {
       editionCount:   parseInt(f.editionCount?.value || "1", 10) || 1,
       certificates:   (f.certificates?.value || "")
                         .split(",").map(s => s.trim()).filter(Boolean),
-      batchId:        f.batchId?.value.trim()
+      batchId:        f.batchId?.value.trim(),
+      brandSlug:      f.brandSlug?.value || undefined
     };
     if (!body.name) return alert("Назва обовʼязкова");
     try {
@@ -779,6 +812,23 @@ function wireManufacturerForms() {
     }
   });
 
+  qs("#brandProfileForm")?.addEventListener("submit", async (e) => {
+    e.preventDefault();
+    const f = e.target;
+    const msg = qs("#brandProfileMsg");
+    const body = { social: {} };
+    ["logo", "description", "website", "country", "supportEmail", "supportPhone", "supportUrl"]
+      .forEach(k => { body[k] = f[k].value.trim(); });
+    profileSocials.forEach(k => { body.social[k] = f[k].value.trim(); });
+    try {
+      await api(`/api/manufacturers/${encodeURIComponent(f.slug.value)}/profile`, { method: "POST", body });
+      if (msg) msg.textContent = "Збережено.";
+      await reloadProfile();
+    } catch (err) {
+      alert("Помилка збереження профілю: " + (err.message || err));
+    }
+  });
+
   userProductForm?.addEventListener("submit", async (e) => {
     e.preventDefault();
     const f = e.target;
@@ -929,6 +979,8 @@ async function reloadProfile() {
   try {
     const me = await api("/api/me");
     lastMe = me;
+    fillBrandSelects(me);
+    fillBrandProfile(me);
     renderTopbar(me, lastUser);
     renderProfile(me, lastUser);
     renderOverview(me);
@@ -967,6 +1019,8 @@ function initAuth() {
     try {
       const me = await api("/api/me");
       lastMe = me;
+      fillBrandSelects(me);
+      fillBrandProfile(me);
       renderTopbar(me, user);
       renderProfile(me, user);
       renderOverview(me);
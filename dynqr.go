@@ -0,0 +1,236 @@
+// dynqr.go — анти-копі QR: у продукту є секретний seed, посилання для перевірки
+// містять одноразовий код з лічильником, використані коди фіксуються.
+
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+type FSDynamicSeed struct {
+	TokenID   int64     `firestore:"tokenId"`
+	Seed      []byte    `firestore:"seed"`
+	Issued    int64     `firestore:"issued"` // скільки кодів видано (останній лічильник)
+	Gen       int64     `firestore:"gen"`    // покоління seed, росте з кожним перевипуском
+	EnabledBy string    `firestore:"enabledBy"`
+	EnabledAt time.Time `firestore:"enabledAt"`
+}
+
+const (
+	CodeValid   = "valid"
+	CodeUsed    = "used"
+	CodeInvalid = "invalid"
+	CodeMissing = "missing"
+)
+
+func dynamicCode(seed []byte, id, counter int64) string {
+	m := hmac.New(sha256.New, seed)
+	fmt.Fprintf(m, "marki-dqr|%d|%d", id, counter)
+	return strconv.FormatInt(counter, 10) + "-" + strings.ToLower(b32.EncodeToString(m.Sum(nil)[:10]))
+}
+
+func parseDynamicCode(code string) (int64, bool) {
+	n, _, ok := strings.Cut(strings.TrimSpace(code), "-")
+	if !ok {
+		return 0, false
+	}
+	c, err := strconv.ParseInt(n, 10, 64)
+	return c, err == nil && c > 0
+}
+
+func dynamicURL(p Product, code string) string {
+	u := digitalLinkURL(p)
+	if strings.Contains(u, "?") {
+		return u + "&code=" + url.QueryEscape(code)
+	}
+	return u + "?code=" + url.QueryEscape(code)
+}
+
+func dynSeedDoc(id int64) *firestore.DocumentRef {
+	return fsDoc("dynamicCodes/" + strconv.FormatInt(id, 10))
+}
+
+// usedCodeID — id документа used/*: лічильник з поколінням seed, щоб після
+// перевипуску (issued знову з нуля) нові коди не ловили старі позначки.
+// Покоління 0 — seed до появи поколінь, там id це просто лічильник.
+func usedCodeID(gen, counter int64) string {
+	if gen == 0 {
+		return strconv.FormatInt(counter, 10)
+	}
+	return fmt.Sprintf("%d-%d", gen, counter)
+}
+
+// fsEnableDynamicQR створює seed (або перевипускає його з новим поколінням)
+// і ставить прапорець на продукт
+func fsEnableDynamicQR(ctx context.Context, id int64, by string) error {
+	seed := make([]byte, 32)
+	if _, err := rand.Read(seed); err != nil {
+		return err
+	}
+	return fsClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		var prev FSDynamicSeed
+		s, err := tx.Get(dynSeedDoc(id))
+		if err == nil {
+			if err := s.DataTo(&prev); err != nil {
+				return err
+			}
+		} else if !strings.Contains(strings.ToLower(err.Error()), "not found") {
+			return err
+		}
+		if err := tx.Set(dynSeedDoc(id), FSDynamicSeed{
+			TokenID: id, Seed: seed, Gen: prev.Gen + 1, EnabledBy: strings.ToLower(by), EnabledAt: time.Now(),
+		}); err != nil {
+			return err
+		}
+		return tx.Update(fsDoc("products/"+strconv.FormatInt(id, 10)), []firestore.Update{
+			{Path: "dynamicQr", Value: true},
+		})
+	})
+}
+
+// fsIssueDynamicCodes видає n наступних кодів (для друку етикеток або показу в застосунку)
+func fsIssueDynamicCodes(ctx context.Context, id int64, n int) ([]string, error) {
+	var out []string
+	err := fsClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		out = out[:0]
+		s, err := tx.Get(dynSeedDoc(id))
+		if err != nil {
+			return err
+		}
+		var x FSDynamicSeed
+		if err := s.DataTo(&x); err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			x.Issued++
+			out = append(out, dynamicCode(x.Seed, id, x.Issued))
+		}
+		return tx.Update(dynSeedDoc(id), []firestore.Update{{Path: "issued", Value: x.Issued}})
+	})
+	return out, err
+}
+
+// fsConsumeDynamicCode — перше сканування коду успішне, будь-яке наступне
+// (фотокопія етикетки) отримує CodeUsed.
+func fsConsumeDynamicCode(ctx context.Context, id int64, code string) (string, error) {
+	counter, ok := parseDynamicCode(code)
+	if !ok {
+		return CodeInvalid, nil
+	}
+	s, err := dynSeedDoc(id).Get(ctx)
+	if err != nil {
+		return "", err
+	}
+	var x FSDynamicSeed
+	if err := s.DataTo(&x); err != nil {
+		return "", err
+	}
+	want := dynamicCode(x.Seed, id, counter)
+	if counter > x.Issued || subtle.ConstantTimeCompare([]byte(want), []byte(strings.ToLower(strings.TrimSpace(code)))) != 1 {
+		return CodeInvalid, nil
+	}
+	_, err = dynSeedDoc(id).Collection("used").Doc(usedCodeID(x.Gen, counter)).Create(ctx, map[string]any{
+		"usedAt": time.Now(),
+	})
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "already exists") {
+			return CodeUsed, nil
+		}
+		return "", err
+	}
+	return CodeValid, nil
+}
+
+func dynamicCodeResult(ctx context.Context, p Product, code string) map[string]any {
+	if strings.TrimSpace(code) == "" {
+		return map[string]any{"status": CodeMissing, "message": "verification code missing"}
+	}
+	st, err := fsConsumeDynamicCode(ctx, p.TokenID, code)
+	if err != nil {
+		return map[string]any{"status": CodeInvalid, "message": "code check failed"}
+	}
+	switch st {
+	case CodeUsed:
+		return map[string]any{"status": st, "message": "code already used"}
+	case CodeInvalid:
+		return map[string]any{"status": st, "message": "invalid code"}
+	}
+	return map[string]any{"status": st}
+}
+
+// POST /api/products/{id}/dynamic-qr        — увімкнути (перевипустити seed)
+// POST /api/products/{id}/dynamic-qr/issue  — видати наступні коди {count}
+func productDynamicQR(w http.ResponseWriter, r *http.Request, id int64, action string) {
+	u := currentUser(r)
+	if u == "" {
+		writeJSON(w, 401, ErrorResp{"missing user"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	p, ok, err := fsGetProduct(ctx, id)
+	if err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+	if !ok {
+		writeJSON(w, 404, ErrorResp{"product not found"})
+		return
+	}
+	if !canBrand(ctx, u, p.BrandSlug, PermBrandProducts) {
+		writeJSON(w, 403, ErrorResp{"forbidden"})
+		return
+	}
+
+	n := 1
+	switch action {
+	case "":
+		if err := fsEnableDynamicQR(ctx, id, u); err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+	case "issue":
+		if !p.DynamicQR {
+			writeJSON(w, 409, ErrorResp{"dynamic qr not enabled"})
+			return
+		}
+		var body struct {
+			Count int `json:"count"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body.Count > 0 {
+			n = body.Count
+		}
+		if n > 500 {
+			writeJSON(w, 400, ErrorResp{"count too large (max 500)"})
+			return
+		}
+	default:
+		writeJSON(w, 404, ErrorResp{"not found"})
+		return
+	}
+
+	codes, err := fsIssueDynamicCodes(ctx, id, n)
+	if err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+	urls := make([]string, len(codes))
+	for i, c := range codes {
+		urls[i] = dynamicURL(p, c)
+	}
+	writeJSON(w, 200, map[string]any{"ok": true, "tokenId": id, "codes": codes, "urls": urls})
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDynamicCodeRoundTrip(t *testing.T) {
+	seed := bytes.Repeat([]byte{7}, 32)
+	c := dynamicCode(seed, 42, 3)
+	if n, ok := parseDynamicCode(c); !ok || n != 3 {
+		t.Fatalf("parseDynamicCode(%q) = %d, %v", c, n, ok)
+	}
+	if c == dynamicCode(seed, 43, 3) || c == dynamicCode(bytes.Repeat([]byte{8}, 32), 42, 3) {
+		t.Fatal("code must depend on token id and seed")
+	}
+	for _, bad := range []string{"", "abc", "0-x", "-1-x", "x-abc"} {
+		if _, ok := parseDynamicCode(bad); ok {
+			t.Errorf("parseDynamicCode(%q) accepted", bad)
+		}
+	}
+}
+
+// після перевипуску seed лічильник починається знову з 1, і позначки
+// використаних кодів старого покоління не мають збігатися з новими
+func TestUsedCodeIDPerGeneration(t *testing.T) {
+	if got := usedCodeID(0, 5); got != "5" {
+		t.Fatalf("legacy id = %q, want 5", got)
+	}
+	seen := map[string]bool{}
+	for gen := int64(0); gen < 4; gen++ {
+		for c := int64(1); c <= 12; c++ {
+			id := usedCodeID(gen, c)
+			if seen[id] {
+				t.Fatalf("id %q reused (gen %d, counter %d)", id, gen, c)
+			}
+			seen[id] = true
+		}
+	}
+}
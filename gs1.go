@@ -0,0 +1,211 @@
+// gs1.go — GTIN продуктів і резолвер GS1 Digital Link:
+// /01/{gtin}/21/{serial} → продукт, /01/{gtin}/10/{lot} → партія.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// normalizeGTIN приймає GTIN-8/12/13/14, перевіряє контрольну цифру
+// і повертає канонічний 14-значний вигляд (як вимагає Digital Link)
+func normalizeGTIN(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	switch len(s) {
+	case 8, 12, 13, 14:
+	default:
+		return "", false
+	}
+	sum := 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			return "", false
+		}
+		if i == len(s)-1 {
+			continue
+		}
+		d := int(c - '0')
+		// ваги 3,1,3,... справа наліво без контрольної цифри
+		if (len(s)-1-i)%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	if (10-sum%10)%10 != int(s[len(s)-1]-'0') {
+		return "", false
+	}
+	return strings.Repeat("0", 14-len(s)) + s, true
+}
+
+// digitalLinkURL — публічне посилання продукту; без GTIN лишається details.html
+func digitalLinkURL(p Product) string {
+	if p.GTIN == "" || p.Meta.Serial == "" {
+		return makePublicURL(p.TokenID)
+	}
+	return fmt.Sprintf("%s/01/%s/21/%s", publicBase, p.GTIN, url.PathEscape(p.Meta.Serial))
+}
+
+type digitalLink struct {
+	GTIN   string
+	Lot    string // AI 10
+	Serial string // AI 21
+}
+
+// parseDigitalLink розбирає шлях /01/{gtin}[/10/{lot}][/21/{serial}]
+func parseDigitalLink(path string) (digitalLink, error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 2 || len(parts)%2 != 0 || parts[0] != "01" {
+		return digitalLink{}, fmt.Errorf("bad digital link")
+	}
+	var dl digitalLink
+	var ok bool
+	if dl.GTIN, ok = normalizeGTIN(parts[1]); !ok {
+		return digitalLink{}, fmt.Errorf("invalid gtin")
+	}
+	for i := 2; i < len(parts); i += 2 {
+		v, err := url.PathUnescape(parts[i+1])
+		if err != nil || strings.TrimSpace(v) == "" || len(v) > 20 {
+			return digitalLink{}, fmt.Errorf("bad value for AI %s", parts[i])
+		}
+		switch parts[i] {
+		case "10":
+			dl.Lot = v
+		case "21":
+			dl.Serial = normalizeSerial(v)
+		default:
+			return digitalLink{}, fmt.Errorf("unsupported AI %s", parts[i])
+		}
+	}
+	return dl, nil
+}
+
+// ================== FIRESTORE ==================
+
+func fsGetProductBySerial(ctx context.Context, gtin, serial string) (Product, bool, error) {
+	list, err := fsQueryProducts(ctx, fsCol("products").
+		Where("gtin", "==", gtin).Where("serial", "==", serial).Limit(1))
+	if err != nil || len(list) == 0 {
+		return Product{}, false, err
+	}
+	return list[0], true, nil
+}
+
+func fsListProductsByLot(ctx context.Context, gtin, lot string) ([]Product, error) {
+	return fsQueryProducts(ctx, fsCol("products").
+		Where("gtin", "==", gtin).Where("batchId", "==", lot).Limit(1000))
+}
+
+// ================== HTTP ==================
+
+// wantsJSON — сканер ритейлера/API просить дані, браузер отримує редірект
+func wantsJSON(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "json" {
+		return true
+	}
+	accept := strings.ToLower(r.Header.Get("Accept"))
+	return strings.Contains(accept, "json") && !strings.Contains(accept, "text/html")
+}
+
+// GET /01/{gtin}/21/{serial}  — продукт
+// GET /01/{gtin}/10/{lot}     — партія
+// GET /01/{gtin}              — загальна інформація про товар
+func digitalLinkResolve(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		returnOK(w)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeJSON(w, 405, ErrorResp{"Method not allowed"})
+		return
+	}
+	dl, err := parseDigitalLink(r.URL.Path)
+	if err != nil {
+		writeJSON(w, 400, ErrorResp{err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	if dl.Serial != "" {
+		p, ok, err := fsGetProductBySerial(ctx, dl.GTIN, dl.Serial)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		if !ok || (dl.Lot != "" && dl.Lot != p.BatchID) {
+			writeJSON(w, 404, ErrorResp{"product not found"})
+			return
+		}
+		code := r.URL.Query().Get("code")
+		if !wantsJSON(r) {
+			// код не споживаємо — це зробить сторінка деталей при перевірці
+			target := makePublicURL(p.TokenID)
+			if code != "" {
+				target += "&code=" + url.QueryEscape(code)
+			}
+			http.Redirect(w, r, target, http.StatusTemporaryRedirect)
+			return
+		}
+		res := verifyResult(r, p)
+		if p.DynamicQR {
+			res["code"] = dynamicCodeResult(ctx, p, code)
+		}
+		writeJSON(w, 200, res)
+		return
+	}
+
+	// партія чи весь GTIN — окремої сторінки немає, тож завжди JSON
+	var list []Product
+	if dl.Lot != "" {
+		list, err = fsListProductsByLot(ctx, dl.GTIN, dl.Lot)
+	} else {
+		list, err = fsQueryProducts(ctx, fsCol("products").Where("gtin", "==", dl.GTIN).Limit(1))
+	}
+	if err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+	if len(list) == 0 {
+		writeJSON(w, 404, ErrorResp{"not found"})
+		return
+	}
+	first := list[0]
+	res := map[string]any{
+		"gtin":      dl.GTIN,
+		"brandSlug": first.BrandSlug,
+		"name":      first.Meta.Name,
+		"image":     first.Meta.Image,
+		"sku":       first.SKU,
+	}
+	if dl.Lot != "" {
+		b, ok, err := fsGetBatch(ctx, dl.Lot)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		items := make([]map[string]any, 0, len(list))
+		for _, p := range list {
+			items = append(items, map[string]any{
+				"tokenId":   p.TokenID,
+				"state":     p.State,
+				"editionNo": p.EditionNo,
+				"publicUrl": makePublicURL(p.TokenID),
+			})
+		}
+		res["lot"] = dl.Lot
+		if ok {
+			res["title"] = b.Title
+			res["createdAt"] = b.CreatedAt
+		}
+		res["count"] = len(items)
+		res["products"] = items
+	}
+	writeJSON(w, 200, res)
+}
@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestNormalizeGTIN(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"96385074", "00000096385074", true},        // GTIN-8 (приклад GS1)
+		{"036000291452", "00036000291452", true},    // GTIN-12 / UPC-A
+		{"4006381333931", "04006381333931", true},   // GTIN-13 / EAN-13
+		{"09506000134352", "09506000134352", true},  // GTIN-14 з прикладів Digital Link
+		{" 4006381333931 ", "04006381333931", true}, // пробіли обрізаються
+		{"4006381333932", "", false},                // хибна контрольна цифра
+		{"09506000134353", "", false},
+		{"400638133393", "", false}, // 12 цифр, але хибна контрольна
+		{"1234567", "", false},      // недопустима довжина
+		{"400638133393A", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := normalizeGTIN(tt.in)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("normalizeGTIN(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseDigitalLink(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    digitalLink
+		wantErr bool
+	}{
+		{"/01/09506000134352/21/12345", digitalLink{GTIN: "09506000134352", Serial: "12345"}, false},
+		{"/01/09506000134352/10/ABC1/21/x-7", digitalLink{GTIN: "09506000134352", Lot: "ABC1", Serial: "X-7"}, false},
+		{"/01/09506000134352/10/LOT%2F1", digitalLink{GTIN: "09506000134352", Lot: "LOT/1"}, false},
+		{"01/96385074/", digitalLink{GTIN: "00000096385074"}, false}, // GTIN-8 доповнюється до 14
+		{"/01/09506000134352", digitalLink{GTIN: "09506000134352"}, false},
+		{"/01/09506000134353/21/1", digitalLink{}, true},                     // контрольна цифра
+		{"/02/09506000134352/21/1", digitalLink{}, true},                     // не AI 01
+		{"/01/09506000134352/21", digitalLink{}, true},                       // AI без значення
+		{"/01/09506000134352/17/260101", digitalLink{}, true},                // AI не підтримується
+		{"/01/09506000134352/21/%zz", digitalLink{}, true},                   // хибне екранування
+		{"/01/09506000134352/21/%20", digitalLink{}, true},                   // порожнє після декодування
+		{"/01/09506000134352/21/123456789012345678901", digitalLink{}, true}, // >20 символів
+		{"/", digitalLink{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseDigitalLink(tt.path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseDigitalLink(%q) err = %v, wantErr %v", tt.path, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseDigitalLink(%q) = %+v, want %+v", tt.path, got, tt.want)
+		}
+	}
+}
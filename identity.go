@@ -0,0 +1,194 @@
+// identity.go — принципал запиту: хто викликає (ID користувача з users.go та
+// його поточний email), визначається один раз у middleware і кешується. Перевірені токени (idp.go) та ролі персоналу
+// тримаються в пам'яті з коротким TTL; зміна ролі скидає кеш на цьому
+// інстансі, інші інстанси побачать її не пізніше ніж через ROLE_CACHE_TTL.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// TOKEN_CACHE_TTL — скільки тримати перевірений токен (але не довше за його exp)
+	tokenCacheTTL = envDuration("TOKEN_CACHE_TTL", 5*time.Minute)
+	// ROLE_CACHE_TTL — скільки тримати роль з admins/{userId}
+	roleCacheTTL = envDuration("ROLE_CACHE_TTL", 30*time.Second)
+
+	tokenCache = newTTLCache[Identity]()
+	roleCache  = newTTLCache[Role]()
+)
+
+// ================== TTL CACHE ==================
+
+const ttlCacheMax = 50000
+
+type ttlEntry[V any] struct {
+	val V
+	exp time.Time
+}
+
+type ttlCache[V any] struct {
+	mu sync.Mutex
+	m  map[string]ttlEntry[V]
+}
+
+func newTTLCache[V any]() *ttlCache[V] {
+	return &ttlCache[V]{m: map[string]ttlEntry[V]{}}
+}
+
+func (c *ttlCache[V]) get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.m[key]
+	if !ok || time.Now().After(e.exp) {
+		var zero V
+		return zero, false
+	}
+	return e.val, true
+}
+
+func (c *ttlCache[V]) set(key string, val V, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.m) >= ttlCacheMax {
+		for k, e := range c.m {
+			if now.After(e.exp) {
+				delete(c.m, k)
+			}
+		}
+		// усе ще живе — починаємо з чистого аркуша
+		if len(c.m) >= ttlCacheMax {
+			c.m = map[string]ttlEntry[V]{}
+		}
+	}
+	c.m[key] = ttlEntry[V]{val: val, exp: now.Add(ttl)}
+}
+
+func (c *ttlCache[V]) del(key string) {
+	c.mu.Lock()
+	delete(c.m, key)
+	c.mu.Unlock()
+}
+
+// ================== PRINCIPAL ==================
+
+type principal struct {
+	UserID        string
+	Email         string // для API-ключа порожній
+	EmailVerified bool   // email підтверджено провайдером
+
+	mu        sync.Mutex
+	role      Role
+	roleKnown bool
+}
+
+type principalCtxKey struct{}
+
+func principalFromContext(ctx context.Context) *principal {
+	p, _ := ctx.Value(principalCtxKey{}).(*principal)
+	return p
+}
+
+// withPrincipal — викликається з withCORS після API-ключа
+func withPrincipal(r *http.Request) *http.Request {
+	if principalFromContext(r.Context()) != nil {
+		return r
+	}
+	p := &principal{}
+	if k := apiKeyFromContext(r.Context()); k != nil {
+		p.UserID = apiKeyUserID(k.ID)
+	} else if id, ok := bearerIdentity(r); ok {
+		p.UserID, p.Email, p.EmailVerified = userForIdentity(r.Context(), id), id.Email, id.EmailVerified
+		// роль з claims IdP має перевагу над admins/{userId} і дзеркалиться туди (admins.go)
+		if p.UserID != "" {
+			ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+			if err := syncIdPRole(ctx, p.UserID, id.Email, id.Provider, id.Staff); err != nil {
+				log.Printf("[admin] idp role sync %s: %v\n", p.UserID, err)
+			}
+			cancel()
+			if id.Staff != "" {
+				p.role, p.roleKnown = id.Staff, true
+			}
+		}
+	}
+	return r.WithContext(context.WithValue(r.Context(), principalCtxKey{}, p))
+}
+
+// requestPrincipal — принципал запиту (поза withCORS визначається на місці)
+func requestPrincipal(r *http.Request) *principal {
+	return principalFromContext(withPrincipal(r).Context())
+}
+
+// verifyToken — перевірка токена провайдером з кешем за sha256(token)
+func verifyToken(ctx context.Context, tok string) (Identity, bool) {
+	sum := sha256.Sum256([]byte(tok))
+	key := hex.EncodeToString(sum[:])
+	if id, ok := tokenCache.get(key); ok {
+		return id, true
+	}
+	p, err := providerFor(tok)
+	if err != nil {
+		return Identity{}, false
+	}
+	id, err := p.Verify(ctx, tok)
+	if err != nil || id.Email == "" {
+		return Identity{}, false
+	}
+	tokenCache.set(key, id, min(tokenCacheTTL, time.Until(id.Expires)))
+	return id, true
+}
+
+// userForIdentity — ID користувача для перевіреної ідентичності; "" — без Firestore
+func userForIdentity(ctx context.Context, id Identity) string {
+	if !fsEnabled {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	u, err := fsResolveUser(ctx, id)
+	if err != nil {
+		log.Printf("[users] resolve %s: %v\n", id.Provider, err)
+		return ""
+	}
+	return u.ID
+}
+
+// cachedStaffRole — роль персоналу: спершу принципал запиту, далі кеш процесу
+func cachedStaffRole(ctx context.Context, uid string, load func() (Role, error)) Role {
+	p := principalFromContext(ctx)
+	if p != nil && p.UserID == uid {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.roleKnown {
+			return p.role
+		}
+	}
+	role, ok := roleCache.get(uid)
+	if !ok {
+		var err error
+		if role, err = load(); err != nil {
+			return "" // помилки не кешуємо
+		}
+		roleCache.set(uid, role, roleCacheTTL)
+	}
+	if p != nil && p.UserID == uid {
+		p.role, p.roleKnown = role, true
+	}
+	return role
+}
+
+// invalidateStaffRole — після видачі/зміни/відкликання ролі
+func invalidateStaffRole(uid string) {
+	roleCache.del(uid)
+}
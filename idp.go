@@ -0,0 +1,318 @@
+// idp.go — провайдери ідентичності: Firebase Auth і довільні OIDC-емітенти
+// (Keycloak, Azure AD, Okta...). Токен маршрутизується за неперевіреним iss,
+// далі перевіряється провайдером (JWKS з URL, discovery або локального файлу).
+//
+// IDP_CONFIG або IDP_CONFIG_FILE — JSON-масив:
+//
+//	[{"name":"keycloak","issuer":"https://kc.example.com/realms/marki",
+//	  "audiences":["marki"],"rolesClaim":"realm_access.roles",
+//	  "roleMap":{"marki-admin":"super-admin","marki-support":"support"}}]
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MicahParks/keyfunc"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Identity — перевірений користувач незалежно від провайдера
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool     // лише тоді новий суб'єкт може зайняти запис з міграції з цим email
+	Roles         []string // сирі ролі з claims
+	Staff         Role     // роль персоналу з roleMap, має перевагу над admins/{userId} (admins.go)
+	Expires       time.Time
+}
+
+type IdentityProvider interface {
+	Name() string
+	Accepts(iss string) bool
+	Verify(ctx context.Context, token string) (Identity, error)
+}
+
+var identityProviders []IdentityProvider
+
+func initIdentityProviders(ctx context.Context) {
+	identityProviders = nil
+	raw := strings.TrimSpace(os.Getenv("IDP_CONFIG"))
+	if f := strings.TrimSpace(os.Getenv("IDP_CONFIG_FILE")); raw == "" && f != "" {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			log.Printf("[idp] IDP_CONFIG_FILE: %v\n", err)
+		}
+		raw = string(b)
+	}
+	if raw != "" {
+		var cfgs []oidcConfig
+		if err := json.Unmarshal([]byte(raw), &cfgs); err != nil {
+			log.Printf("[idp] bad IDP config: %v\n", err)
+		}
+		for _, c := range cfgs {
+			p, err := newOIDCProvider(ctx, c)
+			if err != nil {
+				log.Printf("[idp] %s disabled: %v\n", c.Name, err)
+				continue
+			}
+			identityProviders = append(identityProviders, p)
+			log.Printf("[idp] %s enabled (%s)\n", p.Name(), c.Issuer)
+		}
+	}
+	if authClient != nil {
+		identityProviders = append(identityProviders, firebaseProvider{})
+	}
+	if devAuth {
+		identityProviders = append(identityProviders, devProvider{})
+		log.Println("[idp] DEV_AUTH enabled — signed dev identities are accepted; never enable in production")
+	}
+	if len(identityProviders) == 0 {
+		log.Println("[idp] no identity providers — bearer tokens are ignored")
+	}
+}
+
+// bearerIdentity — перевірений Authorization: Bearer (з кешем)
+func bearerIdentity(r *http.Request) (Identity, bool) {
+	ah := strings.TrimSpace(r.Header.Get("Authorization"))
+	if !strings.HasPrefix(strings.ToLower(ah), "bearer ") {
+		return Identity{}, false
+	}
+	tok := strings.TrimSpace(ah[7:])
+	if tok == "" || len(identityProviders) == 0 {
+		return Identity{}, false
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	return verifyToken(ctx, tok)
+}
+
+func providerFor(tok string) (IdentityProvider, error) {
+	var c jwt.RegisteredClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(tok, &c); err != nil {
+		return nil, err
+	}
+	for _, p := range identityProviders {
+		if p.Accepts(c.Issuer) {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown issuer %q", c.Issuer)
+}
+
+// ================== FIREBASE ==================
+
+type firebaseProvider struct{}
+
+func (firebaseProvider) Name() string { return "firebase" }
+
+func (firebaseProvider) Accepts(iss string) bool {
+	return strings.HasPrefix(iss, "https://securetoken.google.com/")
+}
+
+func (firebaseProvider) Verify(ctx context.Context, tok string) (Identity, error) {
+	t, err := authClient.VerifyIDToken(ctx, tok)
+	if err != nil {
+		return Identity{}, err
+	}
+	e, _ := t.Claims["email"].(string)
+	ev, _ := t.Claims["email_verified"].(bool)
+	return Identity{
+		Provider:      "firebase",
+		Subject:       t.UID,
+		Email:         strings.ToLower(strings.TrimSpace(e)),
+		EmailVerified: ev,
+		Expires:       time.Unix(t.Expires, 0),
+	}, nil
+}
+
+// ================== OIDC ==================
+
+type oidcConfig struct {
+	Name       string            `json:"name"`
+	Issuer     string            `json:"issuer"`
+	Audiences  []string          `json:"audiences"`
+	JWKSURL    string            `json:"jwksUrl"`    // порожньо — з {issuer}/.well-known/openid-configuration
+	JWKSFile   string            `json:"jwksFile"`   // локальний JWKS замість URL
+	EmailClaim string            `json:"emailClaim"` // за замовчуванням email, далі preferred_username, upn
+	RolesClaim string            `json:"rolesClaim"` // шлях через крапку, напр. realm_access.roles
+	RoleMap    map[string]string `json:"roleMap"`    // роль IdP → роль персоналу
+	Algorithms []string          `json:"algorithms"` // за замовчуванням RS256, ES256
+	TrustEmail bool              `json:"trustEmail"` // вважати email перевіреним без claim email_verified
+}
+
+type oidcProvider struct {
+	cfg  oidcConfig
+	jwks *keyfunc.JWKS
+}
+
+func newOIDCProvider(ctx context.Context, c oidcConfig) (*oidcProvider, error) {
+	c.Issuer = strings.TrimSpace(c.Issuer)
+	if c.Issuer == "" {
+		return nil, errors.New("issuer required")
+	}
+	if len(c.Audiences) == 0 {
+		return nil, errors.New("audiences required")
+	}
+	if c.Name == "" {
+		c.Name = c.Issuer
+	}
+	if len(c.Algorithms) == 0 {
+		c.Algorithms = []string{"RS256", "ES256"}
+	}
+	// зберігаємо нормалізовану роль: Verify порівнює її з staffRoles як є
+	roleMap := make(map[string]string, len(c.RoleMap))
+	for ext, role := range c.RoleMap {
+		r, ok := validStaffRole(role)
+		if !ok {
+			return nil, fmt.Errorf("roleMap %s: bad role %q", ext, role)
+		}
+		roleMap[ext] = string(r)
+	}
+	c.RoleMap = roleMap
+
+	var jwks *keyfunc.JWKS
+	var err error
+	switch {
+	case c.JWKSFile != "":
+		var b []byte
+		if b, err = os.ReadFile(c.JWKSFile); err == nil {
+			jwks, err = keyfunc.NewJSON(b)
+		}
+	default:
+		if c.JWKSURL == "" {
+			if c.JWKSURL, err = oidcDiscoverJWKS(ctx, c.Issuer); err != nil {
+				return nil, err
+			}
+		}
+		jwks, err = keyfunc.Get(c.JWKSURL, keyfunc.Options{
+			Ctx:               ctx,
+			RefreshInterval:   time.Hour,
+			RefreshRateLimit:  5 * time.Minute,
+			RefreshTimeout:    10 * time.Second,
+			RefreshUnknownKID: true, // ротація ключів у IdP
+			RefreshErrorHandler: func(err error) {
+				log.Printf("[idp] %s: jwks refresh: %v\n", c.Name, err)
+			},
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &oidcProvider{cfg: c, jwks: jwks}, nil
+}
+
+func oidcDiscoverJWKS(ctx context.Context, issuer string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(issuer, "/")+"/.well-known/openid-configuration", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("discovery: %s", resp.Status)
+	}
+	var d struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil || d.JWKSURI == "" {
+		return "", fmt.Errorf("discovery: no jwks_uri")
+	}
+	return d.JWKSURI, nil
+}
+
+func (p *oidcProvider) Name() string { return p.cfg.Name }
+
+func (p *oidcProvider) Accepts(iss string) bool { return iss == p.cfg.Issuer }
+
+func (p *oidcProvider) Verify(_ context.Context, tok string) (Identity, error) {
+	claims := jwt.MapClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods(p.cfg.Algorithms))
+	if _, err := parser.ParseWithClaims(tok, claims, p.jwks.Keyfunc); err != nil {
+		return Identity{}, err
+	}
+	if !claims.VerifyIssuer(p.cfg.Issuer, true) {
+		return Identity{}, errors.New("bad issuer")
+	}
+	audOK := false
+	for _, a := range p.cfg.Audiences {
+		audOK = audOK || claims.VerifyAudience(a, true)
+	}
+	if !audOK {
+		return Identity{}, errors.New("bad audience")
+	}
+
+	id := Identity{Provider: p.cfg.Name}
+	id.Subject, _ = claims["sub"].(string)
+	for _, name := range []string{p.cfg.EmailClaim, "email", "preferred_username", "upn"} {
+		if e, _ := claims[name].(string); name != "" && strings.Contains(e, "@") {
+			id.Email = strings.ToLower(strings.TrimSpace(e))
+			break
+		}
+	}
+	ev, _ := claims["email_verified"].(bool)
+	id.EmailVerified = id.Email != "" && (ev || p.cfg.TrustEmail)
+	// exp обов'язковий: бібліотека перевіряє його лише якщо він є
+	exp, ok := claims["exp"].(float64)
+	if !ok || !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return Identity{}, errors.New("missing or expired exp")
+	}
+	id.Expires = time.Unix(int64(exp), 0)
+	id.Roles = claimStrings(claimPath(claims, p.cfg.RolesClaim))
+	// найвища з відображених ролей персоналу (staffRoles упорядковані)
+	for _, sr := range staffRoles {
+		for _, ext := range id.Roles {
+			if Role(p.cfg.RoleMap[ext]) == sr {
+				id.Staff = sr
+				break
+			}
+		}
+		if id.Staff != "" {
+			break
+		}
+	}
+	return id, nil
+}
+
+func claimPath(claims map[string]any, path string) any {
+	if path == "" {
+		return nil
+	}
+	var cur any = claims
+	for _, k := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil
+		}
+		cur = m[k]
+	}
+	return cur
+}
+
+// claimStrings — масив рядків або рядок через пробіл (як scope)
+func claimStrings(v any) []string {
+	switch x := v.(type) {
+	case string:
+		return strings.Fields(x)
+	case []any:
+		var out []string
+		for _, e := range x {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/MicahParks/keyfunc"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func testOIDC(t *testing.T) (*oidcProvider, func(jwt.MapClaims) string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &oidcProvider{
+		cfg: oidcConfig{
+			Name: "kc", Issuer: "https://kc.example.com/realms/marki", Audiences: []string{"marki"},
+			RolesClaim: "realm_access.roles", RoleMap: map[string]string{"marki-support": "support", "marki-admin": "super-admin"},
+			Algorithms: []string{"ES256"},
+		},
+		jwks: keyfunc.NewGiven(map[string]keyfunc.GivenKey{"k1": keyfunc.NewGivenECDSA(&key.PublicKey)}),
+	}
+	sign := func(c jwt.MapClaims) string {
+		tok := jwt.NewWithClaims(jwt.SigningMethodES256, c)
+		tok.Header["kid"] = "k1"
+		s, err := tok.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	return p, sign
+}
+
+func TestOIDCVerify(t *testing.T) {
+	p, sign := testOIDC(t)
+	ctx := context.Background()
+	base := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss": p.cfg.Issuer, "aud": "marki", "sub": "s-1", "email": "A@Example.com",
+			"email_verified": true, "exp": time.Now().Add(time.Hour).Unix(),
+			"realm_access": map[string]any{"roles": []string{"offline_access", "marki-support", "marki-admin"}},
+		}
+	}
+
+	id, err := p.Verify(ctx, sign(base()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Provider != "kc" || id.Subject != "s-1" || id.Email != "a@example.com" || !id.EmailVerified {
+		t.Fatalf("identity = %+v", id)
+	}
+	if id.Staff != RoleSuperAdmin {
+		t.Fatalf("staff = %q, want the highest mapped role", id.Staff)
+	}
+
+	for name, mut := range map[string]func(c jwt.MapClaims){
+		"no exp":       func(c jwt.MapClaims) { delete(c, "exp") },
+		"expired":      func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() },
+		"exp string":   func(c jwt.MapClaims) { c["exp"] = "tomorrow" },
+		"bad issuer":   func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" },
+		"bad audience": func(c jwt.MapClaims) { c["aud"] = "other" },
+	} {
+		c := base()
+		mut(c)
+		if _, err := p.Verify(ctx, sign(c)); err == nil {
+			t.Errorf("%s: token accepted", name)
+		}
+	}
+
+	c := base()
+	delete(c, "email_verified")
+	if id, err := p.Verify(ctx, sign(c)); err != nil || id.EmailVerified {
+		t.Fatalf("unverified email: %+v, %v", id, err)
+	}
+}
+
+// значення roleMap нормалізуються при завантаженні конфігурації
+func TestOIDCRoleMapMixedCase(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	jwks := fmt.Sprintf(`{"keys":[{"kty":"EC","crv":"P-256","kid":"k1","alg":"ES256","use":"sig","x":%q,"y":%q}]}`,
+		b64(key.PublicKey.X.FillBytes(make([]byte, 32))), b64(key.PublicKey.Y.FillBytes(make([]byte, 32))))
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(file, []byte(jwks), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := newOIDCProvider(context.Background(), oidcConfig{
+		Name: "kc", Issuer: "https://kc.example.com", Audiences: []string{"marki"}, JWKSFile: file,
+		RolesClaim: "roles", RoleMap: map[string]string{"marki-admin": " Super-Admin "},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.cfg.RoleMap["marki-admin"] != string(RoleSuperAdmin) {
+		t.Fatalf("roleMap value = %q", p.cfg.RoleMap["marki-admin"])
+	}
+	tok := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": "https://kc.example.com", "aud": "marki", "sub": "s-1", "email": "a@example.com",
+		"exp": time.Now().Add(time.Hour).Unix(), "roles": []string{"marki-admin"},
+	})
+	tok.Header["kid"] = "k1"
+	signed, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := p.Verify(context.Background(), signed)
+	if err != nil || id.Staff != RoleSuperAdmin {
+		t.Fatalf("staff = %q, %v", id.Staff, err)
+	}
+
+	if _, err := newOIDCProvider(context.Background(), oidcConfig{
+		Issuer: "https://kc.example.com", Audiences: []string{"marki"}, JWKSFile: file,
+		RoleMap: map[string]string{"x": "root"},
+	}); err == nil {
+		t.Fatal("unknown staff role accepted")
+	}
+}
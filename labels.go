@@ -0,0 +1,368 @@
+// labels.go — QR-коди продуктів (PNG/SVG) і PDF-аркуші етикеток для партії
+// або діапазону tokenId на стандартних шаблонах (Avery A4, US Letter).
+
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+const mmPt = 72.0 / 25.4
+
+type labelTemplate struct {
+	PageW, PageH   float64 // pt
+	Cols, Rows     int
+	W, H           float64 // mm
+	Top, Left      float64 // mm
+	PitchX, PitchY float64 // mm
+}
+
+var labelTemplates = map[string]labelTemplate{
+	// A4, 21 на аркуш, 63.5×38.1 мм
+	"avery-l7160": {595.28, 841.89, 3, 7, 63.5, 38.1, 15.15, 7.25, 66.0, 38.1},
+	// A4, 14 на аркуш, 99.1×38.1 мм
+	"avery-l7163": {595.28, 841.89, 2, 7, 99.1, 38.1, 15.15, 4.65, 101.6, 38.1},
+	// A4, 65 на аркуш, 38.1×21.2 мм
+	"avery-l7651": {595.28, 841.89, 5, 13, 38.1, 21.2, 10.7, 4.75, 40.6, 21.2},
+	// US Letter, 30 на аркуш, 2.625×1 in
+	"letter-5160": {612, 792, 3, 10, 66.675, 25.4, 12.7, 4.7625, 69.85, 25.4},
+}
+
+const defaultLabelTemplate = "avery-l7160"
+
+type labelItem struct {
+	URL    string
+	Brand  string
+	SKU    string
+	Serial string
+	Token  int64
+}
+
+// labelURL — що кодується в QR: GS1 Digital Link (або публічне посилання), а для анти-копі
+// продуктів — свіжий одноразовий код
+func labelURL(ctx context.Context, p Product) (string, error) {
+	if p.DynamicQR {
+		codes, err := fsIssueDynamicCodes(ctx, p.TokenID, 1)
+		if err != nil {
+			return "", err
+		}
+		return dynamicURL(p, codes[0]), nil
+	}
+	if p.GTIN != "" {
+		return digitalLinkURL(p), nil
+	}
+	if p.PublicURL != "" {
+		return p.PublicURL, nil
+	}
+	return makePublicURL(p.TokenID), nil
+}
+
+// ================== PDF ==================
+
+// pdfASCII — стандартні шрифти PDF не мають кирилиці, тож лишаємо ASCII
+func pdfASCII(s string, limit int) string {
+	var b strings.Builder
+	for _, r := range s {
+		if b.Len() >= limit {
+			break
+		}
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+func labelSheetPDF(tpl labelTemplate, items []labelItem, lvl QRLevel) ([]byte, error) {
+	perPage := tpl.Cols * tpl.Rows
+	var pages [][]byte
+	for start := 0; start < len(items); start += perPage {
+		end := min(start+perPage, len(items))
+		var cs bytes.Buffer
+		for i, it := range items[start:end] {
+			col, row := i%tpl.Cols, i/tpl.Cols
+			x := (tpl.Left + float64(col)*tpl.PitchX) * mmPt
+			yTop := tpl.PageH - (tpl.Top+float64(row)*tpl.PitchY)*mmPt
+			w, h := tpl.W*mmPt, tpl.H*mmPt
+			pad := 2 * mmPt
+
+			q, err := encodeQR([]byte(it.URL), lvl)
+			if err != nil {
+				return nil, fmt.Errorf("token %d: %v", it.Token, err)
+			}
+			side := h - 2*pad
+			mod := side / float64(q.Size)
+			qx, qy := x+pad, yTop-pad-side
+			cs.WriteString("0 g\n")
+			for yy := 0; yy < q.Size; yy++ {
+				for xx := 0; xx < q.Size; xx++ {
+					if q.Dark(xx, yy) {
+						fmt.Fprintf(&cs, "%.2f %.2f %.2f %.2f re\n",
+							qx+float64(xx)*mod, qy+float64(q.Size-1-yy)*mod, mod, mod)
+					}
+				}
+			}
+			cs.WriteString("f\n")
+
+			tx := qx + side + pad
+			avail := w - (tx - x) - pad
+			fs := min(8.0, h/6)
+			lines := []struct {
+				font, text string
+			}{
+				{"F2", it.Brand},
+				{"F1", "SKU " + it.SKU},
+				{"F1", "S/N " + it.Serial},
+				{"F1", "#" + strconv.FormatInt(it.Token, 10)},
+			}
+			ty := yTop - pad - fs
+			for _, ln := range lines {
+				if strings.TrimSpace(ln.text) == "" || ln.text == "SKU " {
+					continue
+				}
+				// довгий рядок (серійник) зменшуємо до 4pt, далі обрізаємо
+				lf := max(4.0, min(fs, avail/(float64(len(ln.text))*0.55)))
+				fmt.Fprintf(&cs, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", ln.font, lf, tx, ty, pdfASCII(ln.text, int(avail/(lf*0.55))))
+				ty -= fs * 1.3
+			}
+		}
+		pages = append(pages, cs.Bytes())
+	}
+	if len(pages) == 0 {
+		pages = append(pages, nil)
+	}
+
+	// об'єкти: 1 каталог, 2 дерево сторінок, 3–4 шрифти, далі пари сторінка/контент
+	var out bytes.Buffer
+	var offsets []int
+	obj := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	obj(fmt.Sprintf("<< /Type /Pages /Count %d /Kids [%s] >>", len(pages), strings.Join(kids, " ")))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, content := range pages {
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			tpl.PageW, tpl.PageH, 6+2*i))
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		_, _ = zw.Write(content)
+		_ = zw.Close()
+		obj(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", z.Len(), z.String()))
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes(), nil
+}
+
+// labelItems будує етикетки; назви брендів кешуються на запит
+func labelItems(ctx context.Context, list []Product) ([]labelItem, error) {
+	sort.Slice(list, func(i, j int) bool { return list[i].TokenID < list[j].TokenID })
+	brands := map[string]string{}
+	items := make([]labelItem, 0, len(list))
+	for _, p := range list {
+		name, ok := brands[p.BrandSlug]
+		if !ok && p.BrandSlug != "" {
+			name = p.BrandSlug
+			if b, err := fsGetBrand(ctx, p.BrandSlug); err == nil && b.Name != "" {
+				name = b.Name
+			}
+			brands[p.BrandSlug] = name
+		}
+		u, err := labelURL(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, labelItem{URL: u, Brand: name, SKU: p.SKU, Serial: p.Meta.Serial, Token: p.TokenID})
+	}
+	return items, nil
+}
+
+func writeLabelSheet(ctx context.Context, w http.ResponseWriter, r *http.Request, list []Product, name string) {
+	if r.Method != http.MethodPost && slices.ContainsFunc(list, func(p Product) bool { return p.DynamicQR }) {
+		writeJSON(w, 405, ErrorResp{"labels with dynamic qr issue one-time codes; use POST"})
+		return
+	}
+	tplName := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("template")))
+	if tplName == "" {
+		tplName = defaultLabelTemplate
+	}
+	tpl, ok := labelTemplates[tplName]
+	if !ok {
+		writeJSON(w, 400, ErrorResp{"unknown template"})
+		return
+	}
+	lvl, ok := parseQRLevel(r.URL.Query().Get("ecc"))
+	if !ok {
+		writeJSON(w, 400, ErrorResp{"ecc must be L, M, Q or H"})
+		return
+	}
+	items, err := labelItems(ctx, list)
+	if err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+	pdf, err := labelSheetPDF(tpl, items, lvl)
+	if err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+safeFilename(name)+`"`)
+	_, _ = w.Write(pdf)
+}
+
+// ================== HTTP ==================
+
+// GET /api/products/{id}/qr.png|qr.svg?ecc=M&size=320; для анти-копі продуктів
+// лише POST — кожен такий QR видає новий одноразовий код
+func productQR(w http.ResponseWriter, r *http.Request, id int64, format string) {
+	lvl, ok := parseQRLevel(r.URL.Query().Get("ecc"))
+	if !ok {
+		writeJSON(w, 400, ErrorResp{"ecc must be L, M, Q or H"})
+		return
+	}
+	size := 320
+	if s := strings.TrimSpace(r.URL.Query().Get("size")); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 64 || n > 4096 {
+			writeJSON(w, 400, ErrorResp{"size must be 64..4096"})
+			return
+		}
+		size = n
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+	p, ok, err := fsGetProduct(ctx, id)
+	if err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+	if !ok {
+		writeJSON(w, 404, ErrorResp{"product not found"})
+		return
+	}
+	if p.DynamicQR {
+		if r.Method != http.MethodPost {
+			writeJSON(w, 405, ErrorResp{"dynamic qr issues a one-time code; use POST"})
+			return
+		}
+		// видача коду — лише для бренду
+		u := currentUser(r)
+		if !canBrand(ctx, u, p.BrandSlug, PermBrandProducts) {
+			writeJSON(w, 403, ErrorResp{"forbidden"})
+			return
+		}
+	}
+	link, err := labelURL(ctx, p)
+	if err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+	q, err := encodeQR([]byte(link), lvl)
+	if err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+
+	if p.DynamicQR {
+		w.Header().Set("Cache-Control", "no-store")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+	}
+	if format == "svg" {
+		w.Header().Set("Content-Type", "image/svg+xml")
+		_, _ = w.Write(qrSVG(q, size))
+		return
+	}
+	img, err := qrPNG(q, max(1, size/(q.Size+2*qrQuietZone)))
+	if err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	_, _ = w.Write(img)
+}
+
+// GET /api/manufacturer/labels.pdf?from=&to=&template=&ecc= — діапазон tokenId
+// (POST, якщо серед продуктів є анти-копі — див. writeLabelSheet)
+func manufacturerLabels(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		returnOK(w)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeJSON(w, 405, ErrorResp{"Method not allowed"})
+		return
+	}
+	u := currentUser(r)
+	if u == "" {
+		writeJSON(w, 401, ErrorResp{"missing user"})
+		return
+	}
+	from, err1 := strconv.ParseInt(r.URL.Query().Get("from"), 10, 64)
+	to, err2 := strconv.ParseInt(r.URL.Query().Get("to"), 10, 64)
+	if err1 != nil || err2 != nil || from < 1 || to < from {
+		writeJSON(w, 400, ErrorResp{"from and to required"})
+		return
+	}
+	if to-from >= 1000 {
+		writeJSON(w, 400, ErrorResp{"range too large (max 1000)"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+	list, err := fsQueryProducts(ctx, fsCol("products").
+		Where("tokenId", ">=", from).Where("tokenId", "<=", to).OrderBy("tokenId", firestore.Asc))
+	if err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+	owned := map[string]bool{}
+	var allowed []Product
+	for _, p := range list {
+		ok, seen := owned[p.BrandSlug]
+		if !seen {
+			ok = canBrand(ctx, u, p.BrandSlug, PermBrandBatches)
+			owned[p.BrandSlug] = ok
+		}
+		if ok {
+			allowed = append(allowed, p)
+		}
+	}
+	if len(allowed) == 0 {
+		writeJSON(w, 404, ErrorResp{"no products of your brands in range"})
+		return
+	}
+	writeLabelSheet(ctx, w, r, allowed, fmt.Sprintf("labels-%d-%d.pdf", from, to))
+}
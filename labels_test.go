@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var pdfStreamRe = regexp.MustCompile(`(?s)/Length (\d+) /Filter /FlateDecode >>\nstream\n`)
+
+// pdfStreams розпаковує всі потоки вмісту сторінок по черзі
+func pdfStreams(t *testing.T, pdf []byte) []string {
+	t.Helper()
+	var out []string
+	for _, m := range pdfStreamRe.FindAllSubmatchIndex(pdf, -1) {
+		n, _ := strconv.Atoi(string(pdf[m[2]:m[3]]))
+		zr, err := zlib.NewReader(bytes.NewReader(pdf[m[1] : m[1]+n]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, string(b))
+	}
+	return out
+}
+
+func TestLabelSheetPDF(t *testing.T) {
+	tpl := labelTemplates["avery-l7163"] // 14 на аркуш
+	var items []labelItem
+	for i := 1; i <= 16; i++ {
+		items = append(items, labelItem{
+			URL: makePublicURL(int64(i)), Brand: "ACME (UA)", SKU: "SKU-" + strconv.Itoa(i),
+			Serial: "ABCD-EFGH-JKMN-PQR0", Token: int64(i),
+		})
+	}
+	pdf, err := labelSheetPDF(tpl, items, QRMedium)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatal("not a PDF")
+	}
+	if !bytes.Contains(pdf, []byte("/Type /Pages /Count 2 ")) {
+		t.Fatal("16 labels on a 14-up template must give 2 pages")
+	}
+
+	// xref вказує точно на початок кожного об'єкта
+	s := string(pdf)
+	start, err := strconv.Atoi(strings.TrimSpace(s[strings.LastIndex(s, "startxref\n")+10 : strings.LastIndex(s, "%%EOF")]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	xref := strings.Split(strings.TrimSpace(s[start:strings.Index(s, "trailer")]), "\n")[3:]
+	if len(xref) != 4+2*2 {
+		t.Fatalf("xref has %d objects, want 8", len(xref))
+	}
+	for i, line := range xref {
+		off, _ := strconv.Atoi(line[:10])
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !strings.HasPrefix(s[off:], want) {
+			t.Fatalf("xref entry %d points at %q", i+1, s[off:off+12])
+		}
+	}
+
+	// кожна етикетка — стільки прямокутників, скільки темних модулів у QR
+	streams := pdfStreams(t, pdf)
+	if len(streams) != 2 {
+		t.Fatalf("got %d content streams", len(streams))
+	}
+	for page, content := range streams {
+		want := 0
+		for _, it := range items[page*14 : min(len(items), (page+1)*14)] {
+			q, err := encodeQR([]byte(it.URL), QRMedium)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for y := 0; y < q.Size; y++ {
+				for x := 0; x < q.Size; x++ {
+					if q.Dark(x, y) {
+						want++
+					}
+				}
+			}
+		}
+		if got := strings.Count(content, " re\n"); got != want {
+			t.Errorf("page %d: %d modules drawn, want %d", page+1, got, want)
+		}
+		if !strings.Contains(content, `(ACME \(UA\))`) {
+			t.Errorf("page %d: brand text not escaped", page+1)
+		}
+	}
+}
+
+func TestPDFASCII(t *testing.T) {
+	tests := []struct {
+		in    string
+		limit int
+		want  string
+	}{
+		{"ACME", 10, "ACME"},
+		{`a(b)c\`, 20, `a\(b\)c\\`},
+		{"Марки 1", 20, "????? 1"},
+		{"ABCDEFGH", 4, "ABCD"},
+	}
+	for _, tt := range tests {
+		if got := pdfASCII(tt.in, tt.limit); got != tt.want {
+			t.Errorf("pdfASCII(%q, %d) = %q, want %q", tt.in, tt.limit, got, tt.want)
+		}
+	}
+}
+
+// аркуш з анти-копі продуктами видає одноразові коди — лише через POST
+func TestLabelSheetDynamicRequiresPost(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/manufacturer/labels.pdf?from=1&to=2", nil)
+	writeLabelSheet(context.Background(), w, r, []Product{{TokenID: 1}, {TokenID: 2, DynamicQR: true}}, "labels.pdf")
+	if w.Code != 405 {
+		t.Fatalf("GET with dynamic qr = %d, want 405", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	writeLabelSheet(context.Background(), w, r, []Product{{TokenID: 1}}, "labels.pdf")
+	if w.Code != 200 || w.Header().Get("Content-Type") != "application/pdf" {
+		t.Fatalf("GET without dynamic qr = %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,635 @@
+// ledger.go — журнал подій з хеш-ланцюжком (mint, transfer, claim, revoke,
+// brand verify) і періодичні Merkle-чекпоінти з доказами включення.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+type LedgerKind string
+
+const (
+	LedgerMint        LedgerKind = "mint"
+	LedgerTransfer    LedgerKind = "transfer"
+	LedgerClaim       LedgerKind = "claim"
+	LedgerRevoke      LedgerKind = "revoke"
+	LedgerBrandVerify LedgerKind = "brand_verify"
+	LedgerBrandStatus LedgerKind = "brand_status" // brandstatus.go
+)
+
+type LedgerEntry struct {
+	Seq       int64             `json:"seq"`
+	Kind      LedgerKind        `json:"kind"`
+	TokenID   int64             `json:"tokenId,omitempty"`
+	BrandSlug string            `json:"brandSlug,omitempty"`
+	Actor     string            `json:"actor"`
+	Data      map[string]string `json:"data,omitempty"`
+	At        int64             `json:"at"`
+	PrevHash  string            `json:"prevHash"`
+	Hash      string            `json:"hash"`
+}
+
+type LedgerCheckpoint struct {
+	FromSeq   int64  `json:"fromSeq"`
+	ToSeq     int64  `json:"toSeq"`
+	Root      string `json:"root"`
+	HeadHash  string `json:"headHash"`
+	PrevRoot  string `json:"prevRoot,omitempty"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+type FSLedgerEntry struct {
+	Seq       int64             `firestore:"seq"`
+	Kind      string            `firestore:"kind"`
+	TokenID   int64             `firestore:"tokenId"`
+	BrandSlug string            `firestore:"brandSlug"`
+	Actor     string            `firestore:"actor"`
+	Data      map[string]string `firestore:"data"`
+	At        int64             `firestore:"at"`
+	PrevHash  string            `firestore:"prevHash"`
+	Hash      string            `firestore:"hash"`
+}
+
+type FSLedgerCheckpoint struct {
+	FromSeq   int64     `firestore:"fromSeq"`
+	ToSeq     int64     `firestore:"toSeq"`
+	Root      string    `firestore:"root"`
+	HeadHash  string    `firestore:"headHash"`
+	PrevRoot  string    `firestore:"prevRoot"`
+	CreatedAt time.Time `firestore:"createdAt"`
+}
+
+// genesis-значення prevHash для першого запису
+var ledgerGenesis = strings.Repeat("0", 64)
+
+var ledgerCheckpointEvery = func() time.Duration {
+	if d, err := time.ParseDuration(strings.TrimSpace(os.Getenv("LEDGER_CHECKPOINT_INTERVAL"))); err == nil && d > 0 {
+		return d
+	}
+	return 15 * time.Minute
+}()
+
+func ledgerDocID(seq int64) string { return fmt.Sprintf("%012d", seq) }
+
+// ledgerHash — sha256 від канонічного DAG-CBOR запису (без самого hash)
+func ledgerHash(e LedgerEntry) string {
+	data := map[string]any{}
+	for k, v := range e.Data {
+		data[k] = v
+	}
+	b, err := dagCBOR(map[string]any{
+		"seq":       e.Seq,
+		"kind":      string(e.Kind),
+		"tokenId":   e.TokenID,
+		"brandSlug": e.BrandSlug,
+		"actor":     e.Actor,
+		"data":      data,
+		"at":        e.At,
+		"prevHash":  e.PrevHash,
+	})
+	if err != nil {
+		return ""
+	}
+	return sha256Hex(string(b))
+}
+
+func ledgerFromFS(x FSLedgerEntry) LedgerEntry {
+	return LedgerEntry{
+		Seq: x.Seq, Kind: LedgerKind(x.Kind), TokenID: x.TokenID, BrandSlug: x.BrandSlug,
+		Actor: x.Actor, Data: x.Data, At: x.At, PrevHash: x.PrevHash, Hash: x.Hash,
+	}
+}
+
+// ledgerHead — голова ланцюжка (meta/ledger), прочитана в транзакції, що
+// змінює стан: запис журналу комітиться разом зі зміною або не комітиться
+// взагалі, тож ланцюжок не може пропустити подію.
+type ledgerHead struct {
+	seq  int64
+	prev string
+}
+
+// ledgerReadHead — викликати до будь-яких записів транзакції (вимога Firestore)
+func ledgerReadHead(tx *firestore.Transaction) (*ledgerHead, error) {
+	h := &ledgerHead{prev: ledgerGenesis}
+	snap, err := tx.Get(fsDoc("meta/ledger"))
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "not found") {
+			return h, nil
+		}
+		return nil, err
+	}
+	if v, err := snap.DataAt("seq"); err == nil {
+		h.seq, _ = v.(int64)
+	}
+	if v, err := snap.DataAt("head"); err == nil {
+		if s, ok := v.(string); ok && s != "" {
+			h.prev = s
+		}
+	}
+	return h, nil
+}
+
+// append нумерує записи, зшиває їх через prevHash і пише разом з новою
+// головою; повертає записи з seq і hash (для якорів chain.go)
+func (h *ledgerHead) append(tx *firestore.Transaction, events ...LedgerEntry) ([]LedgerEntry, error) {
+	if len(events) == 0 {
+		return nil, nil
+	}
+	now := time.Now().UnixMilli()
+	out := make([]LedgerEntry, 0, len(events))
+	for _, e := range events {
+		h.seq++
+		e.Seq = h.seq
+		e.Actor = strings.ToLower(e.Actor)
+		e.At = now
+		e.PrevHash = h.prev
+		e.Hash = ledgerHash(e)
+		h.prev = e.Hash
+		if err := tx.Create(fsDoc("ledger/"+ledgerDocID(e.Seq)), FSLedgerEntry{
+			Seq: e.Seq, Kind: string(e.Kind), TokenID: e.TokenID, BrandSlug: e.BrandSlug,
+			Actor: e.Actor, Data: e.Data, At: e.At, PrevHash: e.PrevHash, Hash: e.Hash,
+		}); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, tx.Set(fsDoc("meta/ledger"), map[string]any{"seq": h.seq, "head": h.prev, "updatedAt": time.Now()})
+}
+
+func fsGetLedgerEntry(ctx context.Context, seq int64) (LedgerEntry, bool, error) {
+	d, err := fsDoc("ledger/" + ledgerDocID(seq)).Get(ctx)
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "not found") {
+			return LedgerEntry{}, false, nil
+		}
+		return LedgerEntry{}, false, err
+	}
+	var x FSLedgerEntry
+	if err := d.DataTo(&x); err != nil {
+		return LedgerEntry{}, false, err
+	}
+	return ledgerFromFS(x), true, nil
+}
+
+func fsQueryLedger(ctx context.Context, q firestore.Query) ([]LedgerEntry, error) {
+	it := q.Documents(ctx)
+	defer it.Stop()
+	var out []LedgerEntry
+	for {
+		d, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var x FSLedgerEntry
+		if err := d.DataTo(&x); err != nil {
+			return nil, err
+		}
+		out = append(out, ledgerFromFS(x))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Seq < out[j].Seq })
+	return out, nil
+}
+
+func fsLedgerRange(ctx context.Context, from, to int64) ([]LedgerEntry, error) {
+	return fsQueryLedger(ctx, fsCol("ledger").Where("seq", ">=", from).Where("seq", "<=", to).OrderBy("seq", firestore.Asc))
+}
+
+func fsLedgerByProduct(ctx context.Context, tokenID int64) ([]LedgerEntry, error) {
+	return fsQueryLedger(ctx, fsCol("ledger").Where("tokenId", "==", tokenID))
+}
+
+// ================== MERKLE (RFC 6962) ==================
+
+func merkleLeaf(hexHash string) []byte {
+	raw, _ := hex.DecodeString(hexHash)
+	h := sha256.Sum256(append([]byte{0x00}, raw...))
+	return h[:]
+}
+
+func merkleNode(l, r []byte) []byte {
+	b := make([]byte, 0, 1+len(l)+len(r))
+	b = append(append(append(b, 0x01), l...), r...)
+	h := sha256.Sum256(b)
+	return h[:]
+}
+
+// найбільший степінь двійки, строго менший за n
+func merkleSplit(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+func merkleRoot(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		h := sha256.Sum256(nil)
+		return h[:]
+	case 1:
+		return leaves[0]
+	}
+	k := merkleSplit(len(leaves))
+	return merkleNode(merkleRoot(leaves[:k]), merkleRoot(leaves[k:]))
+}
+
+// merklePath — аудиторський шлях для листа m (від листа до кореня)
+func merklePath(m int, leaves [][]byte) [][]byte {
+	if len(leaves) <= 1 {
+		return nil
+	}
+	k := merkleSplit(len(leaves))
+	if m < k {
+		return append(merklePath(m, leaves[:k]), merkleRoot(leaves[k:]))
+	}
+	return append(merklePath(m-k, leaves[k:]), merkleRoot(leaves[:k]))
+}
+
+// merkleVerify відтворює корінь з листа, індексу, розміру дерева та шляху
+func merkleVerify(leaf []byte, m, n int, path [][]byte, root []byte) bool {
+	if m < 0 || m >= n {
+		return false
+	}
+	var walk func(m, n int, path [][]byte) ([]byte, bool)
+	walk = func(m, n int, path [][]byte) ([]byte, bool) {
+		if n == 1 {
+			return leaf, len(path) == 0
+		}
+		if len(path) == 0 {
+			return nil, false
+		}
+		sib, rest := path[len(path)-1], path[:len(path)-1]
+		k := merkleSplit(n)
+		if m < k {
+			h, ok := walk(m, k, rest)
+			return merkleNode(h, sib), ok
+		}
+		h, ok := walk(m-k, n-k, rest)
+		return merkleNode(sib, h), ok
+	}
+	got, ok := walk(m, n, path)
+	return ok && bytes.Equal(got, root)
+}
+
+// ================== CHECKPOINTS ==================
+
+func checkpointFromFS(x FSLedgerCheckpoint) LedgerCheckpoint {
+	return LedgerCheckpoint{
+		FromSeq: x.FromSeq, ToSeq: x.ToSeq, Root: x.Root, HeadHash: x.HeadHash,
+		PrevRoot: x.PrevRoot, CreatedAt: x.CreatedAt.UnixMilli(),
+	}
+}
+
+func fsLatestCheckpoint(ctx context.Context) (LedgerCheckpoint, bool, error) {
+	list, err := fsListCheckpoints(ctx, 1)
+	if err != nil || len(list) == 0 {
+		return LedgerCheckpoint{}, false, err
+	}
+	return list[0], true, nil
+}
+
+func fsListCheckpoints(ctx context.Context, limit int) ([]LedgerCheckpoint, error) {
+	q := fsCol("ledgerCheckpoints").OrderBy("toSeq", firestore.Desc)
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	it := q.Documents(ctx)
+	defer it.Stop()
+	var out []LedgerCheckpoint
+	for {
+		d, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var x FSLedgerCheckpoint
+		if err := d.DataTo(&x); err != nil {
+			return nil, err
+		}
+		out = append(out, checkpointFromFS(x))
+	}
+	return out, nil
+}
+
+// чекпоінт, що покриває seq: перший з toSeq >= seq
+func fsCheckpointFor(ctx context.Context, seq int64) (LedgerCheckpoint, bool, error) {
+	it := fsCol("ledgerCheckpoints").Where("toSeq", ">=", seq).OrderBy("toSeq", firestore.Asc).Limit(1).Documents(ctx)
+	defer it.Stop()
+	d, err := it.Next()
+	if err == iterator.Done {
+		return LedgerCheckpoint{}, false, nil
+	}
+	if err != nil {
+		return LedgerCheckpoint{}, false, err
+	}
+	var x FSLedgerCheckpoint
+	if err := d.DataTo(&x); err != nil {
+		return LedgerCheckpoint{}, false, err
+	}
+	return checkpointFromFS(x), true, nil
+}
+
+// ledgerCheckpoint фіксує Merkle-корінь записів після останнього чекпоінту.
+// Повертає ok=false, якщо нових записів немає або чекпоінт щойно зробив
+// паралельний запуск.
+func ledgerCheckpoint(ctx context.Context) (LedgerCheckpoint, bool, error) {
+	last, hasLast, err := fsLatestCheckpoint(ctx)
+	if err != nil {
+		return LedgerCheckpoint{}, false, err
+	}
+	from := int64(1)
+	if hasLast {
+		from = last.ToSeq + 1
+	}
+	snap, err := fsDoc("meta/ledger").Get(ctx)
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "not found") {
+			return LedgerCheckpoint{}, false, nil
+		}
+		return LedgerCheckpoint{}, false, err
+	}
+	v, _ := snap.DataAt("seq")
+	to, _ := v.(int64)
+	if to < from {
+		return LedgerCheckpoint{}, false, nil
+	}
+	entries, err := fsLedgerRange(ctx, from, to)
+	if err != nil {
+		return LedgerCheckpoint{}, false, err
+	}
+	if int64(len(entries)) != to-from+1 {
+		return LedgerCheckpoint{}, false, fmt.Errorf("ledger gap: expected %d entries, got %d", to-from+1, len(entries))
+	}
+	leaves := make([][]byte, len(entries))
+	for i, e := range entries {
+		leaves[i] = merkleLeaf(e.Hash)
+	}
+	cp := FSLedgerCheckpoint{
+		FromSeq:   from,
+		ToSeq:     to,
+		Root:      hex.EncodeToString(merkleRoot(leaves)),
+		HeadHash:  entries[len(entries)-1].Hash,
+		CreatedAt: time.Now(),
+	}
+	if hasLast {
+		cp.PrevRoot = last.Root
+	}
+	// голова чекпоінтів у транзакції: паралельний запуск (інший інстанс або
+	// адмін-ендпоінт) з тим самим from програє і нічого не пише — ланцюг
+	// чекпоінтів лишається суцільним
+	head := fsDoc("meta/ledgerCheckpoint")
+	raced := false
+	err = fsClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		raced = false
+		prevTo := from - 1
+		s, err := tx.Get(head)
+		switch {
+		case err == nil:
+			v, _ := s.DataAt("toSeq")
+			prevTo, _ = v.(int64)
+		case !strings.Contains(strings.ToLower(err.Error()), "not found"):
+			return err
+		}
+		if prevTo != from-1 {
+			raced = true
+			return nil
+		}
+		if err := tx.Create(fsDoc("ledgerCheckpoints/"+ledgerDocID(to)), cp); err != nil {
+			return err
+		}
+		return tx.Set(head, map[string]any{"toSeq": to, "root": cp.Root, "updatedAt": cp.CreatedAt})
+	})
+	if err != nil || raced {
+		return LedgerCheckpoint{}, false, err
+	}
+	return checkpointFromFS(cp), true, nil
+}
+
+func ledgerCheckpointLoop(ctx context.Context) {
+	t := time.NewTicker(ledgerCheckpointEvery)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			cctx, cancel := context.WithTimeout(ctx, time.Minute)
+			if cp, ok, err := ledgerCheckpoint(cctx); err != nil {
+				log.Printf("[ledger] checkpoint error: %v\n", err)
+			} else if ok {
+				log.Printf("[ledger] checkpoint %d..%d root=%s\n", cp.FromSeq, cp.ToSeq, cp.Root)
+			}
+			cancel()
+		}
+	}
+}
+
+// ledgerVerifyChain перераховує хеші та корені всіх чекпоінтів.
+// Повертає seq першого зламаного запису (0 — все цілісне).
+func ledgerVerifyChain(ctx context.Context) (int64, string, error) {
+	entries, err := fsQueryLedger(ctx, fsCol("ledger").OrderBy("seq", firestore.Asc))
+	if err != nil {
+		return 0, "", err
+	}
+	prev := ledgerGenesis
+	for i, e := range entries {
+		if e.Seq != int64(i+1) {
+			return int64(i + 1), "missing entry", nil
+		}
+		if e.PrevHash != prev {
+			return e.Seq, "prevHash mismatch", nil
+		}
+		if ledgerHash(e) != e.Hash {
+			return e.Seq, "hash mismatch", nil
+		}
+		prev = e.Hash
+	}
+	cps, err := fsListCheckpoints(ctx, 0)
+	if err != nil {
+		return 0, "", err
+	}
+	for _, cp := range cps {
+		if cp.FromSeq < 1 || cp.ToSeq > int64(len(entries)) || cp.FromSeq > cp.ToSeq {
+			return cp.ToSeq, "checkpoint out of range", nil
+		}
+		rng := entries[cp.FromSeq-1 : cp.ToSeq]
+		leaves := make([][]byte, len(rng))
+		for i, e := range rng {
+			leaves[i] = merkleLeaf(e.Hash)
+		}
+		if hex.EncodeToString(merkleRoot(leaves)) != cp.Root || rng[len(rng)-1].Hash != cp.HeadHash {
+			return cp.ToSeq, "checkpoint root mismatch", nil
+		}
+	}
+	return 0, "", nil
+}
+
+// ================== HTTP ==================
+
+// /api/ledger/checkpoints            GET (публічно) | POST (адмін, примусово)
+// /api/ledger/products/{id}          GET події продукту
+// /api/ledger/proof/{seq}            GET доказ включення
+// /api/ledger/verify                 GET (адмін) перевірка всього ланцюжка
+func ledgerRoutes(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		returnOK(w)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/ledger/"), "/")
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	switch {
+	case len(parts) == 1 && parts[0] == "checkpoints" && r.Method == http.MethodGet:
+		list, err := fsListCheckpoints(ctx, 50)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		writeJSON(w, 200, list)
+
+	case len(parts) == 1 && parts[0] == "checkpoints" && r.Method == http.MethodPost:
+		if !can(ctx, currentUser(r), PermSystemManage) {
+			writeJSON(w, 403, ErrorResp{"forbidden"})
+			return
+		}
+		cp, ok, err := ledgerCheckpoint(ctx)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		if !ok {
+			writeJSON(w, 200, map[string]any{"ok": true, "checkpoint": nil})
+			return
+		}
+		writeJSON(w, 201, map[string]any{"ok": true, "checkpoint": cp})
+
+	case len(parts) == 1 && parts[0] == "verify" && r.Method == http.MethodGet:
+		if !can(ctx, currentUser(r), PermSystemManage) {
+			writeJSON(w, 403, ErrorResp{"forbidden"})
+			return
+		}
+		bad, reason, err := ledgerVerifyChain(ctx)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		if bad != 0 {
+			writeJSON(w, 200, map[string]any{"ok": false, "brokenAt": bad, "reason": reason})
+			return
+		}
+		writeJSON(w, 200, map[string]any{"ok": true})
+
+	case len(parts) == 2 && parts[0] == "products" && r.Method == http.MethodGet:
+		id, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			writeJSON(w, 400, ErrorResp{"bad id"})
+			return
+		}
+		if !canReadLedger(ctx, r, id) {
+			writeJSON(w, 403, ErrorResp{"forbidden"})
+			return
+		}
+		list, err := fsLedgerByProduct(ctx, id)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		writeJSON(w, 200, list)
+
+	case len(parts) == 2 && parts[0] == "proof" && r.Method == http.MethodGet:
+		seq, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || seq < 1 {
+			writeJSON(w, 400, ErrorResp{"bad seq"})
+			return
+		}
+		e, ok, err := fsGetLedgerEntry(ctx, seq)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		if !ok {
+			writeJSON(w, 404, ErrorResp{"entry not found"})
+			return
+		}
+		if !canReadLedger(ctx, r, e.TokenID) {
+			writeJSON(w, 403, ErrorResp{"forbidden"})
+			return
+		}
+		cp, ok, err := fsCheckpointFor(ctx, seq)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		if !ok {
+			writeJSON(w, 409, ErrorResp{"entry not yet checkpointed"})
+			return
+		}
+		rng, err := fsLedgerRange(ctx, cp.FromSeq, cp.ToSeq)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		leaves := make([][]byte, len(rng))
+		for i, x := range rng {
+			leaves[i] = merkleLeaf(x.Hash)
+		}
+		idx := int(seq - cp.FromSeq)
+		path := merklePath(idx, leaves)
+		hexPath := make([]string, len(path))
+		for i, p := range path {
+			hexPath[i] = hex.EncodeToString(p)
+		}
+		writeJSON(w, 200, map[string]any{
+			"entry":      e,
+			"checkpoint": cp,
+			"leafIndex":  idx,
+			"treeSize":   len(leaves),
+			"path":       hexPath,
+			"algorithm":  "rfc6962-sha256",
+		})
+
+	default:
+		writeJSON(w, 404, ErrorResp{"not found"})
+	}
+}
+
+// журнал продукту бачать персонал з ledger.read, поточний власник і команда бренду
+func canReadLedger(ctx context.Context, r *http.Request, tokenID int64) bool {
+	u := currentUser(r)
+	if u == "" {
+		return false
+	}
+	if can(ctx, u, PermLedgerRead) {
+		return true
+	}
+	if tokenID == 0 {
+		return false
+	}
+	p, ok, err := fsGetProduct(ctx, tokenID)
+	if err != nil || !ok {
+		return false
+	}
+	return strings.EqualFold(p.Owner, u) || canBrand(ctx, u, p.BrandSlug, PermBrandRead)
+}
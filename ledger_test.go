@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+// вхідні дані листів і корені з тестових векторів RFC 6962 (certificate-transparency)
+var rfc6962Leaves = []string{
+	"", "00", "10", "2021", "3031", "40414243",
+	"5051525354555657", "606162636465666768696a6b6c6d6e6f",
+}
+
+var rfc6962Roots = []string{
+	"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+	"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+	"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+	"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+	"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+	"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+	"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+	"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+}
+
+func rfc6962LeafHashes(n int) [][]byte {
+	out := make([][]byte, n)
+	for i := range out {
+		out[i] = merkleLeaf(rfc6962Leaves[i])
+	}
+	return out
+}
+
+func TestMerkleRootRFC6962(t *testing.T) {
+	if got := hex.EncodeToString(merkleRoot(nil)); got != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Fatalf("empty root = %s", got)
+	}
+	for n := 1; n <= len(rfc6962Roots); n++ {
+		if got := hex.EncodeToString(merkleRoot(rfc6962LeafHashes(n))); got != rfc6962Roots[n-1] {
+			t.Errorf("root(%d) = %s, want %s", n, got, rfc6962Roots[n-1])
+		}
+	}
+}
+
+func TestMerklePathRFC6962(t *testing.T) {
+	tests := []struct {
+		leaf, size int
+		path       []string
+	}{
+		{0, 8, []string{
+			"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4",
+		}},
+		{5, 8, []string{
+			"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+			"ca854ea128ed050b41b35ffc1b87b8eb2bde461e9e3b5596ece6b9d5975a0ae0",
+			"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		}},
+		{2, 3, []string{"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125"}},
+		{0, 1, nil},
+	}
+	for _, tt := range tests {
+		path := merklePath(tt.leaf, rfc6962LeafHashes(tt.size))
+		if len(path) != len(tt.path) {
+			t.Errorf("path(%d, %d) has %d nodes, want %d", tt.leaf, tt.size, len(path), len(tt.path))
+			continue
+		}
+		for i := range path {
+			if got := hex.EncodeToString(path[i]); got != tt.path[i] {
+				t.Errorf("path(%d, %d)[%d] = %s, want %s", tt.leaf, tt.size, i, got, tt.path[i])
+			}
+		}
+	}
+}
+
+// шлях кожного листа перевіряється для всіх розмірів 1..33, включно з
+// розмірами, що не є степенем двійки
+func TestMerkleInclusionRoundTrip(t *testing.T) {
+	for n := 1; n <= 33; n++ {
+		leaves := make([][]byte, n)
+		for i := range leaves {
+			leaves[i] = merkleLeaf(sha256Hex(fmt.Sprint(i)))
+		}
+		root := merkleRoot(leaves)
+		for m := 0; m < n; m++ {
+			path := merklePath(m, leaves)
+			if !merkleVerify(leaves[m], m, n, path, root) {
+				t.Fatalf("n=%d m=%d: valid proof rejected", n, m)
+			}
+			// чужий лист і зсунутий індекс — відхиляються
+			if n > 1 && merkleVerify(leaves[(m+1)%n], m, n, path, root) {
+				t.Fatalf("n=%d m=%d: proof accepted for another leaf", n, m)
+			}
+			if n > 1 && merkleVerify(leaves[m], (m+1)%n, n, path, root) {
+				t.Fatalf("n=%d m=%d: proof accepted at wrong index", n, m)
+			}
+			if len(path) > 0 {
+				bad := append([][]byte{}, path...)
+				bad[0] = bytes.Repeat([]byte{0xff}, 32)
+				if merkleVerify(leaves[m], m, n, bad, root) {
+					t.Fatalf("n=%d m=%d: tampered path accepted", n, m)
+				}
+				if merkleVerify(leaves[m], m, n, path[:len(path)-1], root) {
+					t.Fatalf("n=%d m=%d: truncated path accepted", n, m)
+				}
+			}
+		}
+		if merkleVerify(leaves[0], n, n, nil, root) || merkleVerify(leaves[0], -1, n, nil, root) {
+			t.Fatalf("n=%d: out-of-range index accepted", n)
+		}
+	}
+}
+
+func TestLedgerHashCoversFields(t *testing.T) {
+	base := LedgerEntry{
+		Seq: 7, Kind: LedgerTransfer, TokenID: 42, BrandSlug: "ACME", Actor: "u_1",
+		Data: map[string]string{"from": "u_1", "to": "u_2"}, At: 1700000000000, PrevHash: ledgerGenesis,
+	}
+	h := ledgerHash(base)
+	if len(h) != 64 || h != ledgerHash(base) {
+		t.Fatalf("ledgerHash not deterministic: %q", h)
+	}
+	mutations := map[string]func(e *LedgerEntry){
+		"seq":      func(e *LedgerEntry) { e.Seq++ },
+		"kind":     func(e *LedgerEntry) { e.Kind = LedgerRevoke },
+		"tokenId":  func(e *LedgerEntry) { e.TokenID++ },
+		"brand":    func(e *LedgerEntry) { e.BrandSlug = "ACME2" },
+		"actor":    func(e *LedgerEntry) { e.Actor = "u_2" },
+		"data":     func(e *LedgerEntry) { e.Data = map[string]string{"from": "u_1", "to": "u_3"} },
+		"at":       func(e *LedgerEntry) { e.At++ },
+		"prevHash": func(e *LedgerEntry) { e.PrevHash = h },
+	}
+	for name, mut := range mutations {
+		e := base
+		mut(&e)
+		if ledgerHash(e) == h {
+			t.Errorf("changing %s does not change the hash", name)
+		}
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -62,9 +63,13 @@ type Product struct {
 	BrandSlug    string   `json:"brandSlug,omitempty"`
 	Meta         Metadata `json:"meta"`
 	SKU          string   `json:"sku,omitempty"`
+	GTIN         string   `json:"gtin,omitempty"`
 	BatchID      string   `json:"batchId,omitempty"`
 	IPFSHash     string   `json:"ipfsHash,omitempty"`
 	SerialHash   string   `json:"serialHash,omitempty"`
+	SerialKeyID  string   `json:"serialKeyId,omitempty"`
+	SerialCommit string   `json:"serialCommit,omitempty"` // серійник у CID-блоці (serial.go)
+	SerialSalt   string   `json:"-"`
 	State        State    `json:"state"`
 	CreatedAt    int64    `json:"createdAt"`
 	PublicURL    string   `json:"publicUrl,omitempty"`
@@ -72,21 +77,32 @@ type Product struct {
 	Seller       string   `json:"seller,omitempty"`
 	EditionNo    int      `json:"editionNo,omitempty"`
 	EditionTotal int      `json:"editionTotal,omitempty"`
+	DynamicQR    bool     `json:"dynamicQr,omitempty"`
+	MintTx       string   `json:"mintTx,omitempty"`
+	TransferTxs  []string `json:"transferTxs,omitempty"`
 }
 
 type Manufacturer struct {
 	Name       string `json:"name"`
 	Slug       string `json:"slug"`
-	Owner      string `json:"owner"` // email
+	Owner      string `json:"owner"` // ID користувача
 	Verified   bool   `json:"verified"`
 	VerifiedBy string `json:"verifiedBy,omitempty"`
 	VerifiedAt int64  `json:"verifiedAt,omitempty"`
-	CreatedAt  int64  `json:"createdAt"`
+	// brandstatus.go: призупинений бренд не мінтить
+	Suspended     bool         `json:"suspended"`
+	SuspendReason string       `json:"suspendReason,omitempty"`
+	SuspendedBy   string       `json:"suspendedBy,omitempty"`
+	SuspendedAt   int64        `json:"suspendedAt,omitempty"`
+	CreatedAt     int64        `json:"createdAt"`
+	Role          string       `json:"role,omitempty"` // роль поточного користувача в бренді
+	Profile       BrandProfile `json:"profile"`
 }
 
 type CompanyApplication struct {
 	ID             string    `json:"id"`
-	User           string    `json:"user"`
+	User           string    `json:"user"` // ID користувача
+	UserEmail      string    `json:"userEmail,omitempty"`
 	FullName       string    `json:"fullName"`
 	ContactEmail   string    `json:"contactEmail"`
 	LegalName      string    `json:"legalName"`
@@ -110,6 +126,7 @@ type Batch struct {
 	ID        string `json:"id"`
 	Title     string `json:"title"`
 	Owner     string `json:"owner"`
+	BrandSlug string `json:"brandSlug,omitempty"`
 	CreatedAt int64  `json:"createdAt"`
 }
 
@@ -119,13 +136,18 @@ type ErrorResp struct {
 
 // Firestore DTOs
 type FSBrand struct {
-	Name       string    `firestore:"name"`
-	Slug       string    `firestore:"slug"`
-	OwnerEmail string    `firestore:"ownerEmail"`
-	Verified   bool      `firestore:"verified"`
-	VerifiedBy string    `firestore:"verifiedBy,omitempty"`
-	VerifiedAt time.Time `firestore:"verifiedAt,omitempty"`
-	CreatedAt  time.Time `firestore:"createdAt"`
+	Name          string       `firestore:"name"`
+	Slug          string       `firestore:"slug"`
+	OwnerID       string       `firestore:"ownerId"` // ID користувача (users.go)
+	Verified      bool         `firestore:"verified"`
+	VerifiedBy    string       `firestore:"verifiedBy,omitempty"`
+	VerifiedAt    time.Time    `firestore:"verifiedAt,omitempty"`
+	Suspended     bool         `firestore:"suspended"`
+	SuspendReason string       `firestore:"suspendReason,omitempty"`
+	SuspendedBy   string       `firestore:"suspendedBy,omitempty"`
+	SuspendedAt   time.Time    `firestore:"suspendedAt,omitempty"`
+	CreatedAt     time.Time    `firestore:"createdAt"`
+	Profile       BrandProfile `firestore:"profile,omitempty"` // brandprofile.go
 }
 
 type FSProduct struct {
@@ -138,9 +160,13 @@ type FSProduct struct {
 	Image        string    `firestore:"image"`
 	Version      int       `firestore:"version"`
 	SKU          string    `firestore:"sku"`
+	GTIN         string    `firestore:"gtin"`
 	BatchID      string    `firestore:"batchId"`
 	IPFSHash     string    `firestore:"ipfsHash"`
 	SerialHash   string    `firestore:"serialHash"`
+	SerialKeyID  string    `firestore:"serialKeyId"`
+	SerialCommit string    `firestore:"serialCommit,omitempty"`
+	SerialSalt   string    `firestore:"serialSalt,omitempty"`
 	State        string    `firestore:"state"`
 	CreatedAt    time.Time `firestore:"createdAt"`
 	PublicURL    string    `firestore:"publicUrl"`
@@ -148,11 +174,15 @@ type FSProduct struct {
 	Seller       string    `firestore:"seller"`
 	EditionNo    int       `firestore:"editionNo"`
 	EditionTotal int       `firestore:"editionTotal"`
+	DynamicQR    bool      `firestore:"dynamicQr"`
+	MintTx       string    `firestore:"mintTx"`
+	TransferTxs  []string  `firestore:"transferTxs"`
 }
 
 type FSCompanyApplication struct {
 	ID             string    `firestore:"id"`
 	User           string    `firestore:"user"`
+	UserEmail      string    `firestore:"userEmail,omitempty"`
 	FullName       string    `firestore:"fullName"`
 	ContactEmail   string    `firestore:"contactEmail"`
 	LegalName      string    `firestore:"legalName"`
@@ -176,6 +206,7 @@ type FSBatch struct {
 	ID        string    `firestore:"id"`
 	Title     string    `firestore:"title"`
 	Owner     string    `firestore:"owner"`
+	BrandSlug string    `firestore:"brandSlug,omitempty"`
 	CreatedAt time.Time `firestore:"createdAt"`
 }
 
@@ -188,10 +219,8 @@ var (
 	authClient *auth.Client
 	fsEnabled  = false
 
-	defaultAdmin = "alankharisov1@gmail.com"
-
-	// Дозволити дев-фолбек X-User (тільки якщо явно ALLOW_XUSER_DEV=true)
-	allowXUser = strings.EqualFold(strings.TrimSpace(os.Getenv("ALLOW_XUSER_DEV")), "true")
+	// DEFAULT_ADMIN_EMAIL — перший super-admin, лише поки admins порожня
+	defaultAdmin = strings.ToLower(strings.TrimSpace(os.Getenv("DEFAULT_ADMIN_EMAIL")))
 
 	// Білий список CORS-оріджинів: ALLOWED_ORIGINS="https://a.com,https://b.com"
 	allowedOrigins = func() map[string]struct{} {
@@ -289,12 +318,23 @@ func withCORS(h http.HandlerFunc) http.HandlerFunc {
 			}
 		}
 		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,DELETE,OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-User, X-Api-Key")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Api-Key, X-Request-ID, X-Marki-Timestamp, X-Marki-Nonce, X-Marki-Signature")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
+		r = withRequestID(w, r)
 		if r.Method == http.MethodOptions {
 			returnOK(w)
 			return
 		}
-		h.ServeHTTP(w, r)
+		// машинні клієнти бренду: X-Api-Key
+		if strings.TrimSpace(r.Header.Get("X-Api-Key")) != "" {
+			var code int
+			var msg string
+			if r, code, msg = authAPIKey(r); code != 0 {
+				writeJSON(w, code, ErrorResp{msg})
+				return
+			}
+		}
+		h.ServeHTTP(w, withPrincipal(r))
 	}
 }
 
@@ -308,40 +348,23 @@ func withSecurityHeaders(h http.Handler) http.Handler {
 	})
 }
 
-func emailFromIDToken(r *http.Request) string {
-	ah := strings.TrimSpace(r.Header.Get("Authorization"))
-	if !strings.HasPrefix(strings.ToLower(ah), "bearer ") {
-		return ""
-	}
-	tok := strings.TrimSpace(ah[7:])
-	if tok == "" || authClient == nil {
-		return ""
-	}
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
-	t, err := authClient.VerifyIDToken(ctx, tok)
-	if err != nil {
-		return ""
-	}
-	if e, ok := t.Claims["email"].(string); ok {
-		return strings.ToLower(strings.TrimSpace(e))
-	}
-	return ""
+// лише перевірені ідентичності: API-ключ або Bearer-токен провайдера (idp.go, devauth.go);
+// повертає стабільний ID користувача (users.go)
+func currentUser(r *http.Request) string {
+	return requestPrincipal(r).UserID
 }
 
-// дев-фолбек X-User допускається лише якщо ALLOW_XUSER_DEV=true
-func currentUser(r *http.Request) string {
-	if e := emailFromIDToken(r); e != "" {
-		return e
-	}
-	if !allowXUser {
-		return ""
-	}
-	u := strings.TrimSpace(r.Header.Get("X-User"))
-	if u == "" {
-		u = strings.TrimSpace(r.URL.Query().Get("user"))
+// currentEmail — поточний email користувача (атрибут, може змінюватися)
+func currentEmail(r *http.Request) string {
+	return requestPrincipal(r).Email
+}
+
+// currentVerifiedEmail — email лише якщо провайдер його підтвердив, інакше ""
+func currentVerifiedEmail(r *http.Request) string {
+	if p := requestPrincipal(r); p.EmailVerified {
+		return p.Email
 	}
-	return strings.ToLower(u)
+	return ""
 }
 
 func slugify(s string) string {
@@ -383,15 +406,6 @@ func randToken(n int) string {
 	return base64.RawURLEncoding.EncodeToString(b)
 }
 
-func genSerial(baseName string, editionNo, editionTotal int) string {
-	base := slugify(baseName)
-	y := time.Now().Year()
-	if editionTotal > 1 && editionNo > 0 {
-		return fmt.Sprintf("%s-%d-%d/%d-%s", base, y, editionNo, editionTotal, shortID())
-	}
-	return fmt.Sprintf("%s-%d-%s", base, y, shortID())
-}
-
 func sha256Hex(s string) string { h := sha256.Sum256([]byte(s)); return hex.EncodeToString(h[:]) }
 
 func mustJSON(v any) []byte { b, _ := json.Marshal(v); return b }
@@ -420,31 +434,53 @@ func clientIP(r *http.Request) string {
 func fsDoc(path string) *firestore.DocumentRef   { return fsClient.Doc(path) }
 func fsCol(path string) *firestore.CollectionRef { return fsClient.Collection(path) }
 
+// ensureDefaultAdmin — засіває DEFAULT_ADMIN_EMAIL лише в порожню базу;
+// відкликаний пізніше адмін не відновлюється при наступному старті
 func ensureDefaultAdmin(ctx context.Context) {
 	if !fsEnabled {
 		return
 	}
-	doc := fsDoc("admins/" + strings.ToLower(defaultAdmin))
-	_, err := doc.Get(ctx)
-	if err == nil {
+	if _, err := fsCol("admins").Limit(1).Documents(ctx).Next(); err == nil {
+		return
+	}
+	if defaultAdmin == "" {
+		log.Println("[admin] DEFAULT_ADMIN_EMAIL not set")
+		ensureBootstrapToken(ctx)
+		return
+	}
+	// лише користувач, що вже входив, і однозначно за email
+	u, ok, err := fsUserByEmail(ctx, defaultAdmin)
+	if err != nil || !ok {
+		log.Printf("[admin] default admin %s not seeded (sign in first): %v\n", defaultAdmin, err)
+		ensureBootstrapToken(ctx)
 		return
 	}
-	if strings.Contains(strings.ToLower(err.Error()), "not found") {
-		_, _ = doc.Create(ctx, map[string]any{
-			"email":     strings.ToLower(defaultAdmin),
-			"createdAt": time.Now(),
+	err = fsClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		admins, err := tx.Documents(fsCol("admins").Limit(1)).GetAll()
+		if err != nil || len(admins) > 0 {
+			return err
+		}
+		now := time.Now()
+		return tx.Create(adminDoc(u.ID), FSAdmin{
+			UserID: u.ID, Email: u.Email, Role: string(RoleSuperAdmin), GrantedBy: "config",
+			GrantedAt: now, CreatedAt: now,
 		})
+	})
+	if err != nil {
+		log.Printf("[admin] default admin: %v\n", err)
 	}
 }
 
-func isAdmin(email string) bool {
-	if email == "" || !fsEnabled {
+func isBrandOwner(ctx context.Context, slug, uid string) bool {
+	if slug == "" || uid == "" || !fsEnabled {
 		return false
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
-	defer cancel()
-	_, err := fsDoc("admins/" + strings.ToLower(email)).Get(ctx)
-	return err == nil
+	// API-ключ діє лише в межах свого бренду
+	if k := apiKeyFromContext(ctx); k != nil && k.BrandSlug != slug {
+		return false
+	}
+	b, err := fsGetBrand(ctx, slug)
+	return err == nil && b.Owner == uid
 }
 
 // --- Brands ---
@@ -452,22 +488,30 @@ func fsCreateBrand(ctx context.Context, name, owner string) (Manufacturer, error
 	if !fsEnabled {
 		return Manufacturer{}, fmt.Errorf("firestore disabled")
 	}
-	owner = strings.ToLower(owner)
 	slug := slugify(name)
 	b := FSBrand{
-		Name:       name,
-		Slug:       slug,
-		OwnerEmail: owner,
-		Verified:   false,
-		CreatedAt:  time.Now(),
+		Name:      name,
+		Slug:      slug,
+		OwnerID:   owner,
+		Verified:  false,
+		CreatedAt: time.Now(),
 	}
 	_, err := fsDoc("brands/"+slug).Create(ctx, b)
 	if err != nil {
 		if strings.Contains(strings.ToLower(err.Error()), "already exists") {
-			return fsGetBrand(ctx, slug)
+			// повторний запит власника — ідемпотентно; чужий бренд — конфлікт
+			m, gerr := fsGetBrand(ctx, slug)
+			if gerr == nil && m.Owner != owner {
+				return Manufacturer{}, opError{409, "brand name already taken"}
+			}
+			return m, gerr
 		}
 		return Manufacturer{}, err
 	}
+	// власник — перший учасник команди
+	if err := fsSetMember(ctx, slug, owner, MemberOwner, owner); err != nil {
+		return Manufacturer{}, err
+	}
 	return Manufacturer{
 		Name: name, Slug: slug, Owner: owner, Verified: false, CreatedAt: time.Now().UnixMilli(),
 	}, nil
@@ -481,18 +525,25 @@ func fsGetBrand(ctx context.Context, slug string) (Manufacturer, error) {
 	if err := dsnap.DataTo(&b); err != nil {
 		return Manufacturer{}, err
 	}
-	var verAt int64
-	if !b.VerifiedAt.IsZero() {
-		verAt = b.VerifiedAt.UnixMilli()
+	return brandFromFS(b, dsnap.CreateTime), nil
+}
+
+func brandFromFS(b FSBrand, created time.Time) Manufacturer {
+	ms := func(t time.Time) int64 {
+		if t.IsZero() {
+			return 0
+		}
+		return t.UnixMilli()
 	}
 	return Manufacturer{
-		Name: b.Name, Slug: b.Slug, Owner: b.OwnerEmail,
-		Verified: b.Verified, VerifiedBy: b.VerifiedBy, VerifiedAt: verAt,
-		CreatedAt: dsnap.CreateTime.UnixMilli(),
-	}, nil
+		Name: b.Name, Slug: b.Slug, Owner: b.OwnerID,
+		Verified: b.Verified, VerifiedBy: b.VerifiedBy, VerifiedAt: ms(b.VerifiedAt),
+		Suspended: b.Suspended, SuspendReason: b.SuspendReason, SuspendedBy: b.SuspendedBy, SuspendedAt: ms(b.SuspendedAt),
+		CreatedAt: created.UnixMilli(), Profile: b.Profile,
+	}
 }
 func fsListBrandsByOwner(ctx context.Context, owner string) ([]Manufacturer, error) {
-	iter := fsCol("brands").Where("ownerEmail", "==", strings.ToLower(owner)).Documents(ctx)
+	iter := fsCol("brands").Where("ownerId", "==", owner).Documents(ctx)
 	defer iter.Stop()
 	var out []Manufacturer
 	for {
@@ -507,47 +558,35 @@ func fsListBrandsByOwner(ctx context.Context, owner string) ([]Manufacturer, err
 		if err := d.DataTo(&b); err != nil {
 			return nil, err
 		}
-		var verAt int64
-		if !b.VerifiedAt.IsZero() {
-			verAt = b.VerifiedAt.UnixMilli()
-		}
-		out = append(out, Manufacturer{
-			Name: b.Name, Slug: b.Slug, Owner: b.OwnerEmail,
-			Verified: b.Verified, VerifiedBy: b.VerifiedBy, VerifiedAt: verAt,
-			CreatedAt: d.CreateTime.UnixMilli(),
-		})
+		out = append(out, brandFromFS(b, d.CreateTime))
 	}
 	return out, nil
 }
-func fsVerifyBrand(ctx context.Context, slug, by string) (Manufacturer, error) {
+
+// fsVerifyBrand — верифікація і подія brand_verify в одній транзакції
+func fsVerifyBrand(ctx context.Context, slug, by string, data map[string]string) (Manufacturer, error) {
 	by = strings.ToLower(by)
-	_, err := fsDoc("brands/"+slug).Set(ctx, map[string]any{
-		"verified":   true,
-		"verifiedBy": by,
-		"verifiedAt": time.Now(),
-	}, firestore.MergeAll)
+	err := fsClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		head, err := ledgerReadHead(tx)
+		if err != nil {
+			return err
+		}
+		if err := tx.Set(fsDoc("brands/"+slug), map[string]any{
+			"verified":   true,
+			"verifiedBy": by,
+			"verifiedAt": time.Now(),
+		}, firestore.MergeAll); err != nil {
+			return err
+		}
+		_, err = head.append(tx, LedgerEntry{Kind: LedgerBrandVerify, BrandSlug: slug, Actor: by, Data: data})
+		return err
+	})
 	if err != nil {
 		return Manufacturer{}, err
 	}
+	brandCache.del(slug)
 	return fsGetBrand(ctx, slug)
 }
-func fsFirstBrandSlugByOwner(ctx context.Context, owner string) (string, bool, error) {
-	q := fsCol("brands").Where("ownerEmail", "==", strings.ToLower(owner)).Limit(1)
-	iter := q.Documents(ctx)
-	defer iter.Stop()
-	d, err := iter.Next()
-	if err != nil {
-		if err == iterator.Done {
-			return "", false, nil
-		}
-		return "", false, err
-	}
-	var b FSBrand
-	if err := d.DataTo(&b); err != nil {
-		return "", false, err
-	}
-	return b.Slug, true, nil
-}
 
 // --- Product ID sequence ---
 func nextProductID(ctx context.Context) (int64, error) {
@@ -579,11 +618,12 @@ func nextProductID(ctx context.Context) (int64, error) {
 }
 
 // --- Products ---
-func fsCreateProduct(ctx context.Context, p Product) (Product, error) {
+// fsCreateProduct пише продукт і його подію мінту в одній транзакції
+func fsCreateProduct(ctx context.Context, p Product, actor string) (Product, LedgerEntry, error) {
 	if p.TokenID == 0 {
 		n, err := nextProductID(ctx)
 		if err != nil {
-			return Product{}, err
+			return Product{}, LedgerEntry{}, err
 		}
 		p.TokenID = n
 	}
@@ -598,9 +638,13 @@ func fsCreateProduct(ctx context.Context, p Product) (Product, error) {
 		Image:        p.Meta.Image,
 		Version:      p.Meta.Version,
 		SKU:          p.SKU,
+		GTIN:         p.GTIN,
 		BatchID:      p.BatchID,
 		IPFSHash:     p.IPFSHash,
 		SerialHash:   p.SerialHash,
+		SerialKeyID:  p.SerialKeyID,
+		SerialCommit: p.SerialCommit,
+		SerialSalt:   p.SerialSalt,
 		State:        string(p.State),
 		CreatedAt:    time.Now(),
 		PublicURL:    p.PublicURL,
@@ -609,13 +653,23 @@ func fsCreateProduct(ctx context.Context, p Product) (Product, error) {
 		EditionNo:    p.EditionNo,
 		EditionTotal: p.EditionTotal,
 	}
-	_, err := fsDoc("products/"+docID).Create(ctx, fp)
-	if err != nil {
-		if strings.Contains(strings.ToLower(err.Error()), "already exists") {
-			_, err = fsDoc("products/"+docID).Set(ctx, fp)
+	var ev LedgerEntry
+	err := fsClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		head, err := ledgerReadHead(tx)
+		if err != nil {
+			return err
 		}
-	}
-	return p, err
+		if err := tx.Set(fsDoc("products/"+docID), fp); err != nil {
+			return err
+		}
+		recorded, err := head.append(tx, mintEvent(p, actor))
+		if err != nil {
+			return err
+		}
+		ev = recorded[0]
+		return nil
+	})
+	return p, ev, err
 }
 func fsGetProduct(ctx context.Context, id int64) (Product, bool, error) {
 	doc := fsDoc("products/" + strconv.FormatInt(id, 10))
@@ -630,6 +684,9 @@ func fsGetProduct(ctx context.Context, id int64) (Product, bool, error) {
 	if err := s.DataTo(&fp); err != nil {
 		return Product{}, false, err
 	}
+	return productFromFS(fp, s.CreateTime), true, nil
+}
+func productFromFS(fp FSProduct, created time.Time) Product {
 	meta := Metadata{
 		Name:           fp.Name,
 		ManufacturedAt: fp.Manufactured,
@@ -643,23 +700,62 @@ func fsGetProduct(ctx context.Context, id int64) (Product, bool, error) {
 		BrandSlug:    fp.BrandSlug,
 		Meta:         meta,
 		SKU:          fp.SKU,
+		GTIN:         fp.GTIN,
 		BatchID:      fp.BatchID,
 		IPFSHash:     fp.IPFSHash,
 		SerialHash:   fp.SerialHash,
+		SerialKeyID:  fp.SerialKeyID,
+		SerialCommit: fp.SerialCommit,
+		SerialSalt:   fp.SerialSalt,
 		State:        State(fp.State),
-		CreatedAt:    s.CreateTime.UnixMilli(),
+		CreatedAt:    created.UnixMilli(),
 		PublicURL:    fp.PublicURL,
 		Owner:        fp.Owner,
 		Seller:       fp.Seller,
 		EditionNo:    fp.EditionNo,
 		EditionTotal: fp.EditionTotal,
-	}, true, nil
+		DynamicQR:    fp.DynamicQR,
+		MintTx:       fp.MintTx,
+		TransferTxs:  fp.TransferTxs,
+	}
 }
 func fsListProductsByOwner(ctx context.Context, owner string, sku string) ([]Product, error) {
 	q := fsCol("products").Where("owner", "==", strings.ToLower(owner))
 	if strings.TrimSpace(sku) != "" {
 		q = q.Where("sku", "==", strings.ToUpper(strings.TrimSpace(sku)))
 	}
+	return fsQueryProducts(ctx, q)
+}
+func fsListProductsByBrands(ctx context.Context, slugs []string, sku string) ([]Product, error) {
+	sku = strings.ToUpper(strings.TrimSpace(sku))
+	var out []Product
+	// Firestore "in" — до 30 значень
+	for len(slugs) > 0 {
+		n := min(len(slugs), 30)
+		q := fsCol("products").Where("brandSlug", "in", slugs[:n])
+		if sku != "" {
+			q = q.Where("sku", "==", sku)
+		}
+		list, err := fsQueryProducts(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, list...)
+		slugs = slugs[n:]
+	}
+	return out, nil
+}
+func fsListProductsByBatch(ctx context.Context, batchID string) ([]Product, error) {
+	return fsQueryProducts(ctx, fsCol("products").Where("batchId", "==", batchID))
+}
+func fsGetProductByCID(ctx context.Context, cid string) (Product, bool, error) {
+	list, err := fsQueryProducts(ctx, fsCol("products").Where("ipfsHash", "==", cid).Limit(1))
+	if err != nil || len(list) == 0 {
+		return Product{}, false, err
+	}
+	return list[0], true, nil
+}
+func fsQueryProducts(ctx context.Context, q firestore.Query) ([]Product, error) {
 	iter := q.Documents(ctx)
 	defer iter.Stop()
 
@@ -676,36 +772,16 @@ func fsListProductsByOwner(ctx context.Context, owner string, sku string) ([]Pro
 		if err := d.DataTo(&fp); err != nil {
 			return nil, err
 		}
-		meta := Metadata{
-			Name:           fp.Name,
-			ManufacturedAt: fp.Manufactured,
-			Serial:         fp.Serial,
-			Certificates:   append([]string{}, fp.Certificates...),
-			Image:          fp.Image,
-			Version:        fp.Version,
-		}
-		out = append(out, Product{
-			TokenID:      fp.TokenID,
-			BrandSlug:    fp.BrandSlug,
-			Meta:         meta,
-			SKU:          fp.SKU,
-			BatchID:      fp.BatchID,
-			IPFSHash:     fp.IPFSHash,
-			SerialHash:   fp.SerialHash,
-			State:        State(fp.State),
-			CreatedAt:    d.CreateTime.UnixMilli(),
-			PublicURL:    fp.PublicURL,
-			Owner:        fp.Owner,
-			Seller:       fp.Seller,
-			EditionNo:    fp.EditionNo,
-			EditionTotal: fp.EditionTotal,
-		})
+		out = append(out, productFromFS(fp, d.CreateTime))
 	}
 	return out, nil
 }
-func fsTransferProductOwner(ctx context.Context, tokenID int64, newOwner string) error {
+
+// fsTransferProductOwner — зміна власника і подія transfer в одній транзакції
+func fsTransferProductOwner(ctx context.Context, tokenID int64, newOwner string) (LedgerEntry, error) {
 	doc := fsDoc("products/" + strconv.FormatInt(tokenID, 10))
-	return fsClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+	var ev LedgerEntry
+	err := fsClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
 		s, err := tx.Get(doc)
 		if err != nil {
 			return err
@@ -717,11 +793,62 @@ func fsTransferProductOwner(ctx context.Context, tokenID int64, newOwner string)
 		if strings.EqualFold(fp.Owner, newOwner) {
 			return fmt.Errorf("already owned by you")
 		}
-		return tx.Update(doc, []firestore.Update{
+		head, err := ledgerReadHead(tx)
+		if err != nil {
+			return err
+		}
+		if err := tx.Update(doc, []firestore.Update{
 			{Path: "owner", Value: strings.ToLower(newOwner)},
 			{Path: "state", Value: string(StatePurchased)},
+		}); err != nil {
+			return err
+		}
+		recorded, err := head.append(tx, LedgerEntry{
+			Kind: LedgerTransfer, TokenID: tokenID, BrandSlug: fp.BrandSlug, Actor: newOwner,
+			Data: map[string]string{"from": strings.ToLower(fp.Owner), "to": strings.ToLower(newOwner), "cid": fp.IPFSHash},
 		})
+		if err != nil {
+			return err
+		}
+		ev = recorded[0]
+		return nil
 	})
+	return ev, err
+}
+
+// fsSetProductState змінює стан у транзакції; check може відхилити перехід.
+// ev (Kind, Actor, Data) пишеться в журнал тією ж транзакцією.
+func fsSetProductState(ctx context.Context, tokenID int64, to State, check func(Product) error, ev LedgerEntry) (Product, error) {
+	doc := fsDoc("products/" + strconv.FormatInt(tokenID, 10))
+	var p Product
+	err := fsClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		s, err := tx.Get(doc)
+		if err != nil {
+			return err
+		}
+		var fp FSProduct
+		if err := s.DataTo(&fp); err != nil {
+			return err
+		}
+		p = productFromFS(fp, s.CreateTime)
+		if check != nil {
+			if err := check(p); err != nil {
+				return err
+			}
+		}
+		head, err := ledgerReadHead(tx)
+		if err != nil {
+			return err
+		}
+		if err := tx.Update(doc, []firestore.Update{{Path: "state", Value: string(to)}}); err != nil {
+			return err
+		}
+		ev.TokenID, ev.BrandSlug = p.TokenID, p.BrandSlug
+		_, err = head.append(tx, ev)
+		return err
+	})
+	p.State = to
+	return p, err
 }
 
 // --- Applications ---
@@ -734,7 +861,8 @@ func fsCreateCompanyApplication(ctx context.Context, app CompanyApplication) (Co
 	}
 	fsa := FSCompanyApplication{
 		ID:           app.ID,
-		User:         strings.ToLower(app.User),
+		User:         app.User,
+		UserEmail:    strings.ToLower(app.UserEmail),
 		FullName:     app.FullName,
 		ContactEmail: strings.ToLower(app.ContactEmail),
 		LegalName:    app.LegalName,
@@ -780,6 +908,7 @@ func fsGetApplication(ctx context.Context, id string) (CompanyApplication, bool,
 	return CompanyApplication{
 		ID:             x.ID,
 		User:           x.User,
+		UserEmail:      x.UserEmail,
 		FullName:       x.FullName,
 		ContactEmail:   x.ContactEmail,
 		LegalName:      x.LegalName,
@@ -827,6 +956,7 @@ func fsLatestApplicationForUser(ctx context.Context, user string) (CompanyApplic
 	return CompanyApplication{
 		ID:             x.ID,
 		User:           x.User,
+		UserEmail:      x.UserEmail,
 		FullName:       x.FullName,
 		ContactEmail:   x.ContactEmail,
 		LegalName:      x.LegalName,
@@ -873,6 +1003,7 @@ func fsListApplicationsByStatus(ctx context.Context, status AppStatus) ([]Compan
 		out = append(out, CompanyApplication{
 			ID:             x.ID,
 			User:           x.User,
+			UserEmail:      x.UserEmail,
 			FullName:       x.FullName,
 			ContactEmail:   x.ContactEmail,
 			LegalName:      x.LegalName,
@@ -958,7 +1089,7 @@ func fsRejectApplication(ctx context.Context, id, reviewedBy, reason string) (Co
 }
 
 // --- Batches ---
-func fsCreateBatch(ctx context.Context, title, owner string) (Batch, error) {
+func fsCreateBatch(ctx context.Context, title, owner, brandSlug string) (Batch, error) {
 	if !fsEnabled {
 		return Batch{}, fmt.Errorf("firestore disabled")
 	}
@@ -966,16 +1097,69 @@ func fsCreateBatch(ctx context.Context, title, owner string) (Batch, error) {
 		ID:        strings.ToLower(shortID() + "_" + randToken(3)),
 		Title:     strings.TrimSpace(title),
 		Owner:     strings.ToLower(owner),
+		BrandSlug: brandSlug,
 		CreatedAt: time.Now(),
 	}
 	_, err := fsDoc("batches/"+b.ID).Create(ctx, b)
 	if err != nil {
 		return Batch{}, err
 	}
-	return Batch{ID: b.ID, Title: b.Title, Owner: b.Owner, CreatedAt: b.CreatedAt.UnixMilli()}, nil
+	return batchFromFS(b), nil
+}
+func fsGetBatch(ctx context.Context, id string) (Batch, bool, error) {
+	d, err := fsDoc("batches/" + id).Get(ctx)
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "not found") {
+			return Batch{}, false, nil
+		}
+		return Batch{}, false, err
+	}
+	var x FSBatch
+	if err := d.DataTo(&x); err != nil {
+		return Batch{}, false, err
+	}
+	return batchFromFS(x), true, nil
+}
+func batchFromFS(x FSBatch) Batch {
+	return Batch{ID: x.ID, Title: x.Title, Owner: x.Owner, BrandSlug: x.BrandSlug, CreatedAt: x.CreatedAt.UnixMilli()}
 }
 func fsListBatchesByOwner(ctx context.Context, owner string) ([]Batch, error) {
 	q := fsCol("batches").Where("owner", "==", strings.ToLower(owner)).OrderBy("createdAt", firestore.Desc)
+	return fsQueryBatches(ctx, q)
+}
+
+// fsListBatchesForUser — власні партії та партії брендів, де користувач у команді
+func fsListBatchesForUser(ctx context.Context, email string) ([]Batch, error) {
+	out, err := fsListBatchesByOwner(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	slugs, err := fsBrandSlugsWith(ctx, email, PermBrandBatches)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, b := range out {
+		seen[b.ID] = true
+	}
+	for len(slugs) > 0 {
+		n := min(len(slugs), 30)
+		list, err := fsQueryBatches(ctx, fsCol("batches").Where("brandSlug", "in", slugs[:n]))
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range list {
+			if !seen[b.ID] {
+				seen[b.ID] = true
+				out = append(out, b)
+			}
+		}
+		slugs = slugs[n:]
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt > out[j].CreatedAt })
+	return out, nil
+}
+func fsQueryBatches(ctx context.Context, q firestore.Query) ([]Batch, error) {
 	it := q.Documents(ctx)
 	defer it.Stop()
 	var out []Batch
@@ -991,9 +1175,7 @@ func fsListBatchesByOwner(ctx context.Context, owner string) ([]Batch, error) {
 		if err := d.DataTo(&x); err != nil {
 			return nil, err
 		}
-		out = append(out, Batch{
-			ID: x.ID, Title: x.Title, Owner: x.Owner, CreatedAt: x.CreatedAt.UnixMilli(),
-		})
+		out = append(out, batchFromFS(x))
 	}
 	return out, nil
 }
@@ -1002,42 +1184,88 @@ func fsListBatchesByOwner(ctx context.Context, owner string) ([]Batch, error) {
 
 func main() {
 	ctx := context.Background()
+
+	// CLI: marki-secure dev-token <email> [role] [ttl] — Firestore не потрібен
+	if len(os.Args) > 1 && os.Args[1] == "dev-token" {
+		devTokenCLI(os.Args[2:])
+		return
+	}
 	initFirebase(ctx)
+	initIdentityProviders(ctx)
 	defer fsClose()
 
 	if !fsEnabled {
 		log.Fatal("Firestore не ініціалізовано — встанови FIRESTORE_PROJECT_ID і ключ сервіс-аккаунта")
 	}
 
+	// CLI: marki-secure bootstrap-token — новий одноразовий токен для /api/admins/bootstrap
+	if len(os.Args) > 1 && os.Args[1] == "bootstrap-token" {
+		tok, exp, err := issueBootstrapToken(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s\n(valid until %s, single use)\n", tok, exp.UTC().Format(time.RFC3339))
+		return
+	}
+	// CLI: marki-secure migrate-users — email-власність → ID користувачів (один раз після оновлення)
+	if len(os.Args) > 1 && os.Args[1] == "migrate-users" {
+		stats, err := migrateUsers(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		auditRecord(ctx, nil, AuditUsersMigrate, "users", nil, stats)
+		fmt.Printf("updated: %v\n", stats)
+		return
+	}
+
 	ensureDefaultAdmin(ctx)
+	go ledgerCheckpointLoop(ctx)
+	go chainWorkerLoop(ctx)
 
 	mux := http.NewServeMux()
 
 	// health
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, 200, map[string]any{
-			"ok":           true,
-			"time":         time.Now().UTC(),
-			"publicBase":   publicBase,
-			"firestore":    fsEnabled,
-			"auth":         authClient != nil,
-			"defaultAdmin": defaultAdmin,
-			"allowXUser":   allowXUser,
+			"ok":         true,
+			"time":       time.Now().UTC(),
+			"publicBase": publicBase,
+			"firestore":  fsEnabled,
+			"auth":       authClient != nil,
+			"devAuth":    devAuth,
 		})
 	})
 
 	// ===== API (with CORS) =====
 	mux.HandleFunc("/api/me", withCORS(handleMe))
+	mux.HandleFunc("/api/me/link", withCORS(meLink))
+	if devAuth {
+		mux.HandleFunc("/api/dev/token", withCORS(devToken)) // loopback + X-Dev-Auth-Secret
+	}
+	mux.HandleFunc("/api/invites", withCORS(myInvites))
+	mux.HandleFunc("/api/invites/", withCORS(myInvites)) // /{id}/accept|decline
 
 	// admins
-	mux.HandleFunc("/api/admins", withCORS(adminsList))
+	mux.HandleFunc("/api/admins", withCORS(requirePerm(PermAdminsManage, adminsList)))
 	mux.HandleFunc("/api/admins/bootstrap", withCORS(adminBootstrap))
-	mux.HandleFunc("/api/admins/grant", withCORS(adminGrant))
-	mux.HandleFunc("/api/admins/create-manufacturer", withCORS(adminCreateManufacturerForUser))
+	mux.HandleFunc("/api/admins/grant", withCORS(requirePerm(PermAdminsManage, adminGrant)))
+	mux.HandleFunc("/api/admins/revoke", withCORS(requirePerm(PermAdminsManage, adminRevoke)))
+	mux.HandleFunc("/api/admins/migrate-users", withCORS(requirePerm(PermSystemManage, adminMigrateUsers)))
+	mux.HandleFunc("/api/admins/audit", withCORS(requirePerm(PermAuditRead, adminAudit)))
+	mux.HandleFunc("/api/admins/approvals", withCORS(adminApprovals))
+	mux.HandleFunc("/api/admins/approvals/", withCORS(adminApprovals))
+	mux.HandleFunc("/api/admins/revoke-products", withCORS(requirePerm(PermBrandRevoke, adminRevokeProducts)))
+	mux.HandleFunc("/api/admins/audit/", withCORS(requirePerm(PermAuditRead, adminAudit)))
+	mux.HandleFunc("/api/admins/reserved-names", withCORS(requirePerm(PermBrandsVerify, adminReservedNames)))
+	mux.HandleFunc("/api/admins/reserved-names/", withCORS(requirePerm(PermBrandsVerify, adminReservedNames)))
+	mux.HandleFunc("/api/admins/create-manufacturer", withCORS(requirePerm(PermBrandsCreate, adminCreateManufacturerForUser)))
+	mux.HandleFunc("/api/admins/serial-keys/rehash", withCORS(requirePerm(PermSystemManage, adminRehashSerials)))
+	mux.HandleFunc("/api/admins/chain", withCORS(requirePerm(PermSystemManage, adminChain)))
+	mux.HandleFunc("/api/admins/chain/retry", withCORS(requirePerm(PermSystemManage, adminChain)))
 
 	// applications moderation
-	mux.HandleFunc("/api/admins/company-applications", withCORS(adminListApplications))
-	mux.HandleFunc("/api/admins/company-applications/", withCORS(adminModerateApplication)) // /{id}/approve|reject
+	mux.HandleFunc("/api/admins/company-applications", withCORS(requirePerm(PermApplicationsRead, adminListApplications)))
+	mux.HandleFunc("/api/admins/company-applications/", withCORS(requirePerm(PermApplicationsReview, adminModerateApplication))) // /{id}/approve|reject
 
 	// company application create
 	mux.HandleFunc("/api/company/apply", withCORS(companyApply))
@@ -1048,16 +1276,35 @@ func main() {
 
 	// batches
 	mux.HandleFunc("/api/manufacturer/batches", withCORS(manufacturerBatches))
+	mux.HandleFunc("/api/manufacturer/batches/", withCORS(manufacturerBatchActions))        // /{id}/car|labels.pdf
+	mux.HandleFunc("/api/manufacturer/labels.pdf", withCORS(manufacturerLabels))            // ?from=&to= tokenId
+	mux.HandleFunc("/api/manufacturer/offline-bundle", withCORS(manufacturerOfflineBundle)) // ?batch= | ?sku=&brand=
 
 	// products (user/company create + my list + actions)
 	mux.HandleFunc("/api/user/products", withCORS(userCreateProduct))
 	mux.HandleFunc("/api/manufacturer/products", withCORS(companyProducts)) // GET (my by SKU), POST (create)
 	mux.HandleFunc("/api/products", withCORS(productsList))                 // GET my list (optional sku)
-	mux.HandleFunc("/api/products/", withCORS(productActions))              // /{id}/purchase
+	mux.HandleFunc("/api/products/", withCORS(productActions))              // /{id}/purchase|claim|revoke|nfc|dynamic-qr|qr.png|qr.svg|vc
 
 	// verification
 	mux.HandleFunc("/api/verify/", withCORS(verifyProduct))
 
+	// NFC tap-to-verify (NTAG 424 DNA SUN)
+	mux.HandleFunc("/api/nfc/", withCORS(nfcVerify))
+
+	// журнал подій: чекпоінти, події продукту, докази включення
+	mux.HandleFunc("/api/ledger/", withCORS(ledgerRoutes))
+
+	// IPFS: сирі блоки метаданих за CID
+	mux.HandleFunc("/api/ipfs/", withCORS(ipfsBlock))
+
+	// GS1 Digital Link: /01/{gtin}/21/{serial}, /01/{gtin}/10/{lot}
+	mux.HandleFunc("/01/", withCORS(digitalLinkResolve))
+
+	// W3C Verifiable Credentials: перевірка, статус, DID-документ емітента
+	mux.HandleFunc("/api/vc/", withCORS(vcRoutes))
+	mux.HandleFunc("/.well-known/did.json", withCORS(didDocument))
+
 	// ===== Static =====
 	root := os.Getenv("DOCS_DIR")
 	if root == "" {
@@ -1102,7 +1349,7 @@ func handleMe(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 6*time.Second)
 	defer cancel()
 
-	brands, err := fsListBrandsByOwner(ctx, u)
+	brands, err := fsBrandsForUser(ctx, u)
 	if err != nil {
 		writeJSON(w, 500, ErrorResp{err.Error()})
 		return
@@ -1118,9 +1365,13 @@ func handleMe(w http.ResponseWriter, r *http.Request) {
 		status = app.Status
 	}
 
+	role, perms := permsFor(ctx, u)
 	writeJSON(w, 200, map[string]any{
-		"email":                    u,
-		"isAdmin":                  isAdmin(u),
+		"userId":                   u,
+		"email":                    currentEmail(r),
+		"isAdmin":                  role == RoleSuperAdmin,
+		"role":                     role,
+		"permissions":              perms,
 		"isManufacturer":           len(brands) > 0,
 		"brands":                   brands,
 		"companyApplicationStatus": status, // "pending"/"approved"/"rejected" or null
@@ -1143,22 +1394,10 @@ func adminsList(w http.ResponseWriter, r *http.Request) {
 
 	ctx, cancel := context.WithTimeout(r.Context(), 6*time.Second)
 	defer cancel()
-	iter := fsCol("admins").Documents(ctx)
-	defer iter.Stop()
-
-	var out []string
-	for {
-		d, err := iter.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			writeJSON(w, 500, ErrorResp{err.Error()})
-			return
-		}
-		if e, ok := d.Data()["email"].(string); ok && e != "" {
-			out = append(out, e)
-		}
+	out, err := fsListAdmins(ctx)
+	if err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
 	}
 	writeJSON(w, 200, map[string]any{"admins": out})
 }
@@ -1177,29 +1416,74 @@ func adminBootstrap(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, 401, ErrorResp{"missing user"})
 		return
 	}
+	// одноразовий токен з логу старту або `marki-secure bootstrap-token`
+	var body struct {
+		Token string `json:"token"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	if strings.TrimSpace(body.Token) == "" {
+		writeJSON(w, 400, ErrorResp{"bootstrap token required"})
+		return
+	}
 	ctx, cancel := context.WithTimeout(r.Context(), 6*time.Second)
 	defer cancel()
-	it := fsCol("admins").Limit(1).Documents(ctx)
-	_, err := it.Next()
-	if err == nil {
-		writeJSON(w, 403, ErrorResp{"already initialized"})
+	if err := consumeBootstrapToken(ctx, body.Token, u, currentEmail(r)); err != nil {
+		switch msg := err.Error(); {
+		case strings.Contains(msg, "already initialized"):
+			writeJSON(w, 403, ErrorResp{"already initialized"})
+		case strings.Contains(msg, "bootstrap token"):
+			writeJSON(w, 403, ErrorResp{msg})
+		default:
+			writeJSON(w, 500, ErrorResp{msg})
+		}
 		return
 	}
-	if err != iterator.Done && err != nil {
-		writeJSON(w, 500, ErrorResp{err.Error()})
+	invalidateStaffRole(u)
+	auditRecord(ctx, r, AuditAdminBootstrap, auditUser(u), nil, map[string]any{"role": RoleSuperAdmin})
+	writeJSON(w, 200, map[string]any{"ok": true, "admin": u, "email": currentEmail(r)})
+}
+
+// POST /api/admins/grant {userId або email, role}; email — лише однозначний
+func adminGrant(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		returnOK(w)
 		return
 	}
-	_, err = fsDoc("admins/"+strings.ToLower(u)).Create(ctx, map[string]any{
-		"email": strings.ToLower(u), "createdAt": time.Now(),
-	})
+	if r.Method != http.MethodPost {
+		writeJSON(w, 405, ErrorResp{"Method not allowed"})
+		return
+	}
+
+	var body struct {
+		UserID string `json:"userId"`
+		Email  string `json:"email"`
+		Role   string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSON(w, 400, ErrorResp{"invalid json"})
+		return
+	}
+	role := RoleSuperAdmin
+	if strings.TrimSpace(body.Role) != "" {
+		var ok bool
+		if role, ok = validStaffRole(body.Role); !ok {
+			writeJSON(w, 400, ErrorResp{"bad role"})
+			return
+		}
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	user, err := fsUserRef(ctx, body.UserID, body.Email)
 	if err != nil {
-		writeJSON(w, 500, ErrorResp{err.Error()})
+		writeOpErr(w, err)
 		return
 	}
-	writeJSON(w, 200, map[string]any{"ok": true, "admin": strings.ToLower(u)})
+	// approvals.go: може чекати на погодження другим адміном
+	runOrPropose(ctx, w, r, AuditAdminGrant, auditUser(user.ID), map[string]string{"userId": user.ID, "role": string(role)})
 }
 
-func adminGrant(w http.ResponseWriter, r *http.Request) {
+// POST /api/admins/revoke {email} або {userId}
+func adminRevoke(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodOptions {
 		returnOK(w)
 		return
@@ -1208,34 +1492,42 @@ func adminGrant(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, 405, ErrorResp{"Method not allowed"})
 		return
 	}
-
-	actor := currentUser(r)
-	if !isAdmin(actor) {
-		writeJSON(w, 403, ErrorResp{"forbidden"})
-		return
+	var body struct {
+		Email  string `json:"email"`
+		UserID string `json:"userId"`
 	}
-	var body struct{ Email string }
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		writeJSON(w, 400, ErrorResp{"invalid json"})
 		return
 	}
+	uid := strings.TrimSpace(body.UserID)
 	email := strings.ToLower(strings.TrimSpace(body.Email))
-	if email == "" {
-		writeJSON(w, 400, ErrorResp{"email required"})
+	if uid == "" && email == "" {
+		writeJSON(w, 400, ErrorResp{"email or userId required"})
 		return
 	}
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
-	_, err := fsDoc("admins/"+email).Set(ctx, map[string]any{
-		"email": email, "updatedAt": time.Now(),
-	}, firestore.MergeAll)
-	if err != nil {
+	if uid == "" {
+		u, err := fsUserRef(ctx, "", email)
+		if err != nil {
+			writeOpErr(w, err)
+			return
+		}
+		uid = u.ID
+	}
+	if _, err := adminDoc(uid).Get(ctx); err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "not found") {
+			writeJSON(w, 404, ErrorResp{"admin not found"})
+			return
+		}
 		writeJSON(w, 500, ErrorResp{err.Error()})
 		return
 	}
-	writeJSON(w, 200, map[string]any{"ok": true})
+	runOrPropose(ctx, w, r, AuditAdminRevoke, auditUser(uid), map[string]string{"userId": uid})
 }
 
+// POST /api/admins/create-manufacturer {name, userId або email} — власник має вже входити
 func adminCreateManufacturerForUser(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodOptions {
 		returnOK(w)
@@ -1246,29 +1538,33 @@ func adminCreateManufacturerForUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	actor := currentUser(r)
-	if !isAdmin(actor) {
-		writeJSON(w, 403, ErrorResp{"forbidden"})
-		return
+	var body struct {
+		Name   string `json:"name"`
+		UserID string `json:"userId"`
+		Email  string `json:"email"`
 	}
-	var body struct{ Name, Email string }
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		writeJSON(w, 400, ErrorResp{"invalid json"})
 		return
 	}
 	name := strings.TrimSpace(body.Name)
-	email := strings.ToLower(strings.TrimSpace(body.Email))
-	if name == "" || email == "" {
-		writeJSON(w, 400, ErrorResp{"name and email required"})
+	if name == "" {
+		writeJSON(w, 400, ErrorResp{"name required"})
 		return
 	}
 	ctx, cancel := context.WithTimeout(r.Context(), 6*time.Second)
 	defer cancel()
-	m, err := fsCreateBrand(ctx, name, email)
+	owner, err := fsUserRef(ctx, body.UserID, body.Email)
 	if err != nil {
-		writeJSON(w, 500, ErrorResp{err.Error()})
+		writeOpErr(w, err)
+		return
+	}
+	m, err := fsCreateBrand(ctx, name, owner.ID)
+	if err != nil {
+		writeOpErr(w, err)
 		return
 	}
+	auditRecord(ctx, r, AuditBrandCreate, auditBrand(m.Slug), nil, map[string]any{"name": m.Name, "owner": m.Owner, "ownerEmail": owner.Email})
 	writeJSON(w, 201, m)
 }
 
@@ -1282,11 +1578,6 @@ func adminListApplications(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	actor := currentUser(r)
-	if !isAdmin(actor) {
-		writeJSON(w, 403, ErrorResp{"forbidden"})
-		return
-	}
 	statusStr := strings.TrimSpace(r.URL.Query().Get("status"))
 	if statusStr == "" {
 		statusStr = string(AppPending)
@@ -1320,10 +1611,6 @@ func adminModerateApplication(w http.ResponseWriter, r *http.Request) {
 	}
 
 	actor := currentUser(r)
-	if !isAdmin(actor) {
-		writeJSON(w, 403, ErrorResp{"forbidden"})
-		return
-	}
 	rest := strings.TrimPrefix(r.URL.Path, "/api/admins/company-applications/")
 	parts := strings.Split(rest, "/")
 	if len(parts) != 2 {
@@ -1342,6 +1629,7 @@ func adminModerateApplication(w http.ResponseWriter, r *http.Request) {
 			writeJSON(w, 405, ErrorResp{"Method not allowed"})
 			return
 		}
+		prev, _, _ := fsGetApplication(ctx, id)
 		app, err := fsApproveApplication(ctx, id, actor)
 		if err != nil {
 			if strings.Contains(strings.ToLower(err.Error()), "already") {
@@ -1351,21 +1639,35 @@ func adminModerateApplication(w http.ResponseWriter, r *http.Request) {
 			writeJSON(w, 500, ErrorResp{err.Error()})
 			return
 		}
-		// авто-створюємо бренд і одразу його верифікуємо
-		ownerEmail := app.ContactEmail
-		if ownerEmail == "" {
-			ownerEmail = app.User
-		}
+		after := map[string]any{"status": app.Status, "user": app.User}
+		resp := map[string]any{"ok": true}
+		// авто-створюємо бренд неверифікованим; верифікація — як і на
+		// /verify, через чотири ока (approvals.go), лише якщо рецензент має право
 		if strings.TrimSpace(app.BrandName) == "" {
 			app.BrandName = app.LegalName
 		}
-		if strings.TrimSpace(app.BrandName) != "" && ownerEmail != "" {
-			b, err := fsCreateBrand(ctx, app.BrandName, ownerEmail)
-			if err == nil {
-				_, _ = fsVerifyBrand(ctx, b.Slug, actor)
+		// власник — заявник (ID користувача), а не контактний email
+		if strings.TrimSpace(app.BrandName) != "" && app.User != "" {
+			b, err := fsCreateBrand(ctx, app.BrandName, app.User)
+			if err != nil {
+				after["brandError"] = err.Error()
+			} else {
+				after["brand"] = b.Slug
+				resp["brand"] = b.Slug
+				if !b.Verified && can(ctx, actor, PermBrandsVerify) {
+					_, v, err := proposeOrRun(ctx, r, AuditBrandVerify, auditBrand(b.Slug), map[string]string{"slug": b.Slug})
+					if err != nil {
+						after["verifyError"] = err.Error()
+						resp["verifyError"] = err.Error()
+					} else {
+						resp["verification"] = v
+					}
+				}
 			}
 		}
-		writeJSON(w, 200, map[string]any{"ok": true, "app": app})
+		auditRecord(ctx, r, AuditAppApprove, "application:"+id, map[string]any{"status": prev.Status}, after)
+		resp["app"] = app
+		writeJSON(w, 200, resp)
 		return
 
 	case "reject":
@@ -1375,11 +1677,14 @@ func adminModerateApplication(w http.ResponseWriter, r *http.Request) {
 		}
 		var body struct{ Reason string `json:"reason"` }
 		_ = json.NewDecoder(r.Body).Decode(&body)
+		prev, _, _ := fsGetApplication(ctx, id)
 		app, err := fsRejectApplication(ctx, id, actor, strings.TrimSpace(body.Reason))
 		if err != nil {
 			writeJSON(w, 500, ErrorResp{err.Error()})
 			return
 		}
+		auditRecord(ctx, r, AuditAppReject, "application:"+id,
+			map[string]any{"status": prev.Status}, map[string]any{"status": app.Status, "reason": app.RejectedReason})
 		writeJSON(w, 200, map[string]any{"ok": true, "app": app})
 		return
 	default:
@@ -1412,6 +1717,7 @@ func companyApply(w http.ResponseWriter, r *http.Request) {
 	}
 	app := CompanyApplication{
 		User:         user,
+		UserEmail:    currentEmail(r),
 		FullName:     strings.TrimSpace(body.FullName),
 		ContactEmail: strings.ToLower(strings.TrimSpace(body.ContactEmail)),
 		LegalName:    strings.TrimSpace(body.LegalName),
@@ -1448,7 +1754,7 @@ func manufacturerCreateOrList(w http.ResponseWriter, r *http.Request) {
 		}
 		ctx, cancel := context.WithTimeout(r.Context(), 6*time.Second)
 		defer cancel()
-		list, err := fsListBrandsByOwner(ctx, u)
+		list, err := fsBrandsForUser(ctx, u)
 		if err != nil {
 			writeJSON(w, 500, ErrorResp{err.Error()})
 			return
@@ -1473,9 +1779,21 @@ func manufacturerCreateOrList(w http.ResponseWriter, r *http.Request) {
 		}
 		ctx, cancel := context.WithTimeout(r.Context(), 6*time.Second)
 		defer cancel()
+		// відомі марки — лише через заявку компанії або персонал
+		if !can(ctx, u, PermBrandsCreate) {
+			rn, reserved, err := fsReservedFor(ctx, slugify(name))
+			if err != nil {
+				writeJSON(w, 500, ErrorResp{err.Error()})
+				return
+			}
+			if reserved {
+				writeJSON(w, 403, ErrorResp{"brand name " + rn.Name + " is reserved; submit a company application"})
+				return
+			}
+		}
 		m, err := fsCreateBrand(ctx, name, u)
 		if err != nil {
-			writeJSON(w, 500, ErrorResp{err.Error()})
+			writeOpErr(w, err)
 			return
 		}
 		writeJSON(w, 201, m)
@@ -1511,23 +1829,46 @@ func manufacturerGetOrVerify(w http.ResponseWriter, r *http.Request) {
 			writeJSON(w, 500, ErrorResp{err.Error()})
 			return
 		}
-		writeJSON(w, 200, m)
+		// публічна картка: власника не розкриваємо
+		writeJSON(w, 200, brandPublicView(m))
+		return
+
+	case len(parts) == 2 && parts[1] == "profile":
+		brandProfile(w, r, slug)
+		return
+
+	case len(parts) == 2 && (parts[1] == "status" || parts[1] == "suspend" || parts[1] == "unverify" || parts[1] == "reinstate"):
+		brandStatus(w, r, slug, parts[1])
 		return
 
 	case len(parts) == 2 && parts[1] == "verify" && r.Method == http.MethodPost:
 		u := currentUser(r)
-		if !isAdmin(u) {
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+		if !can(ctx, u, PermBrandsVerify) {
 			writeJSON(w, 403, ErrorResp{"forbidden"})
 			return
 		}
-		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-		defer cancel()
-		m, err := fsVerifyBrand(ctx, slug, u)
-		if err != nil {
-			writeJSON(w, 500, ErrorResp{err.Error()})
+		if _, err := fsGetBrand(ctx, slug); err != nil {
+			writeJSON(w, 404, ErrorResp{"manufacturer not found"})
 			return
 		}
-		writeJSON(w, 200, m)
+		runOrPropose(ctx, w, r, AuditBrandVerify, auditBrand(slug), map[string]string{"slug": slug})
+		return
+
+	case len(parts) >= 2 && len(parts) <= 4 && parts[1] == "api-keys":
+		id, action := "", ""
+		if len(parts) >= 3 {
+			id = parts[2]
+		}
+		if len(parts) == 4 {
+			action = parts[3]
+		}
+		brandAPIKeys(w, r, slug, id, action)
+		return
+
+	case len(parts) >= 2 && (parts[1] == "members" || parts[1] == "invites"):
+		brandMembers(w, r, slug, parts[1:])
 		return
 
 	default:
@@ -1549,7 +1890,7 @@ func manufacturerBatches(w http.ResponseWriter, r *http.Request) {
 		}
 		ctx, cancel := context.WithTimeout(r.Context(), 6*time.Second)
 		defer cancel()
-		list, err := fsListBatchesByOwner(ctx, u)
+		list, err := fsListBatchesForUser(ctx, u)
 		if err != nil {
 			writeJSON(w, 500, ErrorResp{err.Error()})
 			return
@@ -1562,14 +1903,22 @@ func manufacturerBatches(w http.ResponseWriter, r *http.Request) {
 			writeJSON(w, 401, ErrorResp{"missing user"})
 			return
 		}
-		var body struct{ Title string `json:"title"` }
+		var body struct {
+			Title     string `json:"title"`
+			BrandSlug string `json:"brandSlug"`
+		}
 		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 			writeJSON(w, 400, ErrorResp{"invalid json"})
 			return
 		}
 		ctx, cancel := context.WithTimeout(r.Context(), 6*time.Second)
 		defer cancel()
-		b, err := fsCreateBatch(ctx, body.Title, u)
+		brand, err := fsChooseBrand(ctx, u, body.BrandSlug, PermBrandBatches)
+		if err != nil {
+			writeOpErr(w, err)
+			return
+		}
+		b, err := fsCreateBatch(ctx, body.Title, u, brand)
 		if err != nil {
 			writeJSON(w, 500, ErrorResp{err.Error()})
 			return
@@ -1631,8 +1980,14 @@ func userCreateProduct(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	var created []Product
+	var events []LedgerEntry
+	defer func() { chainEnqueue(ctx, events...) }()
 	for i := 1; i <= total; i++ {
-		serial := genSerial(name, i, total)
+		serial, err := genSerial(ctx)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
 		meta := Metadata{
 			Name:           name,
 			ManufacturedAt: manAt,
@@ -1641,8 +1996,13 @@ func userCreateProduct(w http.ResponseWriter, r *http.Request) {
 			Image:          strings.TrimSpace(req.Image),
 			Version:        1,
 		}
-		ipfs := sha256Hex(string(mustJSON(meta)))[:46]
-		serH := sha256Hex(serial)
+		serH, serKey := serialHash(serial)
+		commit, salt, err := serialCommitment(serial)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		ipfs := metadataCID(meta, commit)
 
 		p := Product{
 			TokenID:      0,
@@ -1652,6 +2012,9 @@ func userCreateProduct(w http.ResponseWriter, r *http.Request) {
 			BatchID:      "", // без партії у юзера
 			IPFSHash:     ipfs,
 			SerialHash:   serH,
+			SerialKeyID:  serKey,
+			SerialCommit: commit,
+			SerialSalt:   salt,
 			State:        StateCreated,
 			CreatedAt:    time.Now().UnixMilli(),
 			PublicURL:    "",
@@ -1666,12 +2029,14 @@ func userCreateProduct(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		p.TokenID = id
-		p.PublicURL = makePublicURL(id)
-		if _, err := fsCreateProduct(ctx, p); err != nil {
+		p.PublicURL = digitalLinkURL(p)
+		_, ev, err := fsCreateProduct(ctx, p, user)
+		if err != nil {
 			writeJSON(w, 500, ErrorResp{err.Error()})
 			return
 		}
 		created = append(created, p)
+		events = append(events, ev)
 	}
 
 	if len(created) == 1 {
@@ -1691,6 +2056,8 @@ type companyCreateReq struct {
 	EditionCount   int      `json:"editionCount,omitempty"`
 	Certificates   []string `json:"certificates,omitempty"`
 	BatchID        string   `json:"batchId,omitempty"`
+	GTIN           string   `json:"gtin,omitempty"`
+	BrandSlug      string   `json:"brandSlug,omitempty"` // обов'язковий, якщо брендів кілька
 }
 
 func companyProducts(w http.ResponseWriter, r *http.Request) {
@@ -1709,7 +2076,17 @@ func companyProducts(w http.ResponseWriter, r *http.Request) {
 		sku := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("sku")))
 		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 		defer cancel()
-		list, err := fsListProductsByOwner(ctx, u, sku)
+		slugs, err := fsBrandSlugsWith(ctx, u, PermBrandRead)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		var list []Product
+		if len(slugs) > 0 {
+			list, err = fsListProductsByBrands(ctx, slugs, sku)
+		} else {
+			list, err = fsListProductsByOwner(ctx, u, sku)
+		}
 		if err != nil {
 			writeJSON(w, 500, ErrorResp{err.Error()})
 			return
@@ -1738,13 +2115,45 @@ func companyProducts(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
 		defer cancel()
 
-		brandSlug, ok, err := fsFirstBrandSlugByOwner(ctx, u)
+		batchID := strings.TrimSpace(req.BatchID)
+		var batch Batch
+		if batchID != "" {
+			var ok bool
+			var err error
+			if batch, ok, err = fsGetBatch(ctx, batchID); err != nil {
+				writeJSON(w, 500, ErrorResp{err.Error()})
+				return
+			} else if !ok {
+				writeJSON(w, 404, ErrorResp{"batch not found"})
+				return
+			}
+			// партія вже визначає бренд
+			if req.BrandSlug == "" {
+				req.BrandSlug = batch.BrandSlug
+			}
+		}
+		brandSlug, err := fsChooseBrand(ctx, u, req.BrandSlug, PermBrandProducts)
+		if err != nil {
+			writeOpErr(w, err)
+			return
+		}
+		if batchID != "" && batch.BrandSlug != brandSlug {
+			writeJSON(w, 400, ErrorResp{"batch belongs to another brand"})
+			return
+		}
+		// продукти належать бренду (його власнику), хто б із команди їх не створив
+		brand, err := fsGetBrand(ctx, brandSlug)
 		if err != nil {
 			writeJSON(w, 500, ErrorResp{err.Error()})
 			return
 		}
-		if !ok {
-			writeJSON(w, 403, ErrorResp{"no brand for this account"})
+		// мінт під брендом — лише після верифікації адміном і поки бренд не призупинено
+		if brand.Suspended {
+			writeJSON(w, 403, ErrorResp{"brand " + brand.Slug + " is suspended"})
+			return
+		}
+		if !brand.Verified {
+			writeJSON(w, 403, ErrorResp{"brand " + brand.Slug + " is not verified yet"})
 			return
 		}
 
@@ -1757,11 +2166,24 @@ func companyProducts(w http.ResponseWriter, r *http.Request) {
 			total = 1
 		}
 		sku := strings.ToUpper(strings.TrimSpace(req.SKU))
-		batchID := strings.TrimSpace(req.BatchID)
+		gtin := ""
+		if strings.TrimSpace(req.GTIN) != "" {
+			var ok bool
+			if gtin, ok = normalizeGTIN(req.GTIN); !ok {
+				writeJSON(w, 400, ErrorResp{"invalid gtin"})
+				return
+			}
+		}
 
 		var created []Product
+		var events []LedgerEntry
+		defer func() { chainEnqueue(ctx, events...) }()
 		for i := 1; i <= total; i++ {
-			serial := genSerial(name, i, total)
+			serial, err := genSerial(ctx)
+			if err != nil {
+				writeJSON(w, 500, ErrorResp{err.Error()})
+				return
+			}
 			meta := Metadata{
 				Name:           name,
 				ManufacturedAt: manAt,
@@ -1770,22 +2192,31 @@ func companyProducts(w http.ResponseWriter, r *http.Request) {
 				Image:          strings.TrimSpace(req.Image),
 				Version:        1,
 			}
-			ipfs := sha256Hex(string(mustJSON(meta)))[:46]
-			serH := sha256Hex(serial)
+			serH, serKey := serialHash(serial)
+			commit, salt, err := serialCommitment(serial)
+			if err != nil {
+				writeJSON(w, 500, ErrorResp{err.Error()})
+				return
+			}
+			ipfs := metadataCID(meta, commit)
 
 			p := Product{
 				TokenID:      0,
 				BrandSlug:    brandSlug,
 				Meta:         meta,
 				SKU:          sku,
+				GTIN:         gtin,
 				BatchID:      batchID,
 				IPFSHash:     ipfs,
 				SerialHash:   serH,
+				SerialKeyID:  serKey,
+				SerialCommit: commit,
+				SerialSalt:   salt,
 				State:        StateCreated,
 				CreatedAt:    time.Now().UnixMilli(),
 				PublicURL:    "",
-				Owner:        u,
-				Seller:       u,
+				Owner:        brand.Owner,
+				Seller:       brand.Owner,
 				EditionNo:    i,
 				EditionTotal: total,
 			}
@@ -1795,12 +2226,14 @@ func companyProducts(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 			p.TokenID = id
-			p.PublicURL = makePublicURL(id)
-			if _, err := fsCreateProduct(ctx, p); err != nil {
+			p.PublicURL = digitalLinkURL(p)
+			_, ev, err := fsCreateProduct(ctx, p, u)
+			if err != nil {
 				writeJSON(w, 500, ErrorResp{err.Error()})
 				return
 			}
 			created = append(created, p)
+			events = append(events, ev)
 		}
 		if len(created) == 1 {
 			writeJSON(w, 201, created[0])
@@ -1844,7 +2277,7 @@ func productsList(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, 200, list)
 }
 
-// ==== PRODUCT actions (/api/products/{id}/purchase) ====
+// ==== PRODUCT actions (/api/products/{id}/purchase|claim|revoke|nfc|dynamic-qr|qr.*|vc) ====
 
 func productActions(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodOptions {
@@ -1885,7 +2318,8 @@ func productActions(w http.ResponseWriter, r *http.Request) {
 			writeJSON(w, 409, ErrorResp{"already owned by you"})
 			return
 		}
-		if err := fsTransferProductOwner(ctx, id, buyer); err != nil {
+		ev, err := fsTransferProductOwner(ctx, id, buyer)
+		if err != nil {
 			if strings.Contains(strings.ToLower(err.Error()), "already owned") {
 				writeJSON(w, 409, ErrorResp{"already owned by you"})
 				return
@@ -1893,13 +2327,117 @@ func productActions(w http.ResponseWriter, r *http.Request) {
 			writeJSON(w, 500, ErrorResp{err.Error()})
 			return
 		}
+		chainEnqueue(ctx, ev)
 		writeJSON(w, 200, map[string]any{"ok": true, "state": StatePurchased})
 		return
 	}
 
+	// власник підтверджує, що отримав річ
+	if len(parts) == 2 && parts[1] == "claim" && r.Method == http.MethodPost {
+		u := currentUser(r)
+		if u == "" {
+			writeJSON(w, 401, ErrorResp{"missing user"})
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		_, err := fsSetProductState(ctx, id, StateClaimed, func(p Product) error {
+			if !strings.EqualFold(p.Owner, u) {
+				return fmt.Errorf("forbidden")
+			}
+			if p.State == StateRevoked || p.State == StateClaimed {
+				return fmt.Errorf("already %s", p.State)
+			}
+			return nil
+		}, LedgerEntry{Kind: LedgerClaim, Actor: u})
+		if err != nil {
+			writeStateErr(w, err)
+			return
+		}
+		writeJSON(w, 200, map[string]any{"ok": true, "state": StateClaimed})
+		return
+	}
+
+	// відкликання: адмін або власник бренду
+	if len(parts) == 2 && parts[1] == "revoke" && r.Method == http.MethodPost {
+		u := currentUser(r)
+		if u == "" {
+			writeJSON(w, 401, ErrorResp{"missing user"})
+			return
+		}
+		var body struct {
+			Reason string `json:"reason"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		_, err := fsSetProductState(ctx, id, StateRevoked, func(p Product) error {
+			if !canBrand(ctx, u, p.BrandSlug, PermBrandRevoke) {
+				return fmt.Errorf("forbidden")
+			}
+			if p.State == StateRevoked {
+				return fmt.Errorf("already revoked")
+			}
+			return nil
+		}, LedgerEntry{Kind: LedgerRevoke, Actor: u, Data: map[string]string{"reason": strings.TrimSpace(body.Reason)}})
+		if err != nil {
+			writeStateErr(w, err)
+			return
+		}
+		writeJSON(w, 200, map[string]any{"ok": true, "state": StateRevoked})
+		return
+	}
+
+	if len(parts) == 2 && (parts[1] == "qr.png" || parts[1] == "qr.svg") && (r.Method == http.MethodGet || r.Method == http.MethodPost) {
+		productQR(w, r, id, strings.TrimPrefix(parts[1], "qr."))
+		return
+	}
+
+	if len(parts) >= 2 && len(parts) <= 3 && parts[1] == "dynamic-qr" && r.Method == http.MethodPost {
+		action := ""
+		if len(parts) == 3 {
+			action = parts[2]
+		}
+		productDynamicQR(w, r, id, action)
+		return
+	}
+
+	if len(parts) == 2 && parts[1] == "nfc" && r.Method == http.MethodPost {
+		productNFCEnroll(w, r, id)
+		return
+	}
+
+	if len(parts) == 2 && parts[1] == "vc" && r.Method == http.MethodPost {
+		productIssueVC(w, r, id)
+		return
+	}
+
 	writeJSON(w, 405, ErrorResp{"Method not allowed"})
 }
 
+func mintEvent(p Product, actor string) LedgerEntry {
+	return LedgerEntry{
+		Kind: LedgerMint, TokenID: p.TokenID, BrandSlug: p.BrandSlug, Actor: actor,
+		Data: map[string]string{"owner": strings.ToLower(p.Owner), "serialHash": p.SerialHash, "cid": p.IPFSHash},
+	}
+}
+
+func writeStateErr(w http.ResponseWriter, err error) {
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "not found"):
+		writeJSON(w, 404, ErrorResp{"product not found"})
+	case msg == "forbidden":
+		writeJSON(w, 403, ErrorResp{"forbidden"})
+	case strings.Contains(msg, "already"):
+		writeJSON(w, 409, ErrorResp{err.Error()})
+	default:
+		writeJSON(w, 500, ErrorResp{err.Error()})
+	}
+}
+
 // ==== VERIFY (/api/verify/{id}) ====
 
 func verifyProduct(w http.ResponseWriter, r *http.Request) {
@@ -1931,31 +2469,94 @@ func verifyProduct(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, 404, ErrorResp{"product not found"})
 		return
 	}
+	res := verifyResult(r, p)
+	if p.DynamicQR {
+		// одноразовий код з етикетки; повторне сканування = ймовірна копія
+		res["code"] = dynamicCodeResult(ctx, p, r.URL.Query().Get("code"))
+	}
+	writeJSON(w, 200, res)
+}
 
+// verifyResult — спільна відповідь перевірки (QR, NFC тощо)
+func verifyResult(r *http.Request, p Product) map[string]any {
 	requester := currentUser(r)
 	canAcquire := requester != "" && !strings.EqualFold(requester, p.Owner)
 
 	// приховаємо серійник якщо не власник/адмін
 	meta := p.Meta
-	if requester == "" || (!strings.EqualFold(requester, p.Owner) && !isAdmin(requester)) {
+	readAny := can(r.Context(), requester, PermProductsReadAny)
+	if requester == "" || (!strings.EqualFold(requester, p.Owner) && !readAny) {
 		meta.Serial = ""
 	}
 	scope := "public"
-	if requester != "" && (strings.EqualFold(requester, p.Owner) || isAdmin(requester)) {
+	if requester != "" && (strings.EqualFold(requester, p.Owner) || readAny) {
 		scope = "full"
 	}
 
-	writeJSON(w, 200, map[string]any{
+	// ?serial= — звірка надрукованого серійника з хешем без його розкриття
+	var serialValid any = nil
+	if sv := strings.TrimSpace(r.URL.Query().Get("serial")); sv != "" {
+		// контрольний символ відсікає описки ще до HMAC (старі серійники — без нього)
+		serialValid = (validSerial(sv) || !validSerial(p.Meta.Serial)) && serialHashMatches(sv, p.SerialKeyID, p.SerialHash)
+	}
+
+	// Digital Link містить серійник — стороннім віддаємо посилання без нього
+	publicURL := p.PublicURL
+	if meta.Serial == "" && p.GTIN != "" {
+		publicURL = makePublicURL(p.TokenID)
+	}
+
+	var brand any = nil
+	var brandWarning any = nil
+	if p.BrandSlug != "" {
+		b := brandPublic(r.Context(), p.BrandSlug)
+		brand = b
+		// призупинений бренд — попереджаємо покупця (brandstatus.go)
+		if b["suspended"] == true {
+			brandWarning = "brand suspended by MARKI Secure; authenticity cannot be guaranteed"
+		}
+	}
+
+	return map[string]any{
 		"state":        p.State,
 		"tokenId":      p.TokenID,
 		"brandSlug":    p.BrandSlug,
+		"brand":        brand,
+		"brandWarning": brandWarning,
 		"metadata":     meta,
-		"publicUrl":    p.PublicURL,
+		"publicUrl":    publicURL,
 		"editionNo":    p.EditionNo,
 		"editionTotal": p.EditionTotal,
 		"sku":          p.SKU,
+		"gtin":         p.GTIN,
 		"batchId":      p.BatchID,
 		"scope":        scope,
 		"canAcquire":   canAcquire,
-	})
+		"serialValid":  serialValid,
+		"mintTx":       p.MintTx,
+		"transferTxs":  p.TransferTxs,
+	}
+}
+
+// brandCache — назва й статус бренду для публічних перевірок (QR/NFC/GS1)
+var brandCache = newTTLCache[map[string]any]()
+
+const brandCacheTTL = time.Minute
+
+// brandPublic — публічна картка бренду (brandprofile.go); бренд, якого немає, — неверифікований
+func brandPublic(ctx context.Context, slug string) map[string]any {
+	if b, ok := brandCache.get(slug); ok {
+		return b
+	}
+	out := map[string]any{"slug": slug, "name": "", "verified": false}
+	m, err := fsGetBrand(ctx, slug)
+	if err != nil {
+		if !strings.Contains(strings.ToLower(err.Error()), "not found") {
+			return out // тимчасова помилка — не кешуємо
+		}
+	} else {
+		out = brandPublicView(m)
+	}
+	brandCache.set(slug, out, brandCacheTTL)
+	return out
 }
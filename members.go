@@ -0,0 +1,596 @@
+// members.go — команда бренду: учасники з ролями (owner, manager, operator,
+// viewer), запрошення за email з прийняттям і видалення учасників.
+// Учасники — brandMembers/{slug}:{userId}; запрошення адресовані email-у і
+// прив'язуються до користувача при прийнятті. Бренди без учасників (старі)
+// спираються на ownerId.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+const (
+	MemberOwner    = "owner"
+	MemberManager  = "manager"
+	MemberOperator = "operator"
+	MemberViewer   = "viewer"
+)
+
+var memberRoles = map[string]Role{
+	MemberOwner:    RoleBrandOwner,
+	MemberManager:  RoleBrandManager,
+	MemberOperator: RoleBrandOperator,
+	MemberViewer:   RoleBrandViewer,
+}
+
+const (
+	AuditMemberInvite       = "member.invite"
+	AuditMemberInviteRevoke = "member.invite_revoke"
+	AuditMemberAdd          = "member.add"
+	AuditMemberRole         = "member.role"
+	AuditMemberRemove       = "member.remove"
+)
+
+const inviteTTL = 14 * 24 * time.Hour
+
+const (
+	InvitePending  = "pending"
+	InviteAccepted = "accepted"
+	InviteDeclined = "declined"
+	InviteRevoked  = "revoked"
+)
+
+type BrandMember struct {
+	BrandSlug string `json:"brandSlug"`
+	UserID    string `json:"userId"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	AddedBy   string `json:"addedBy,omitempty"`
+	AddedAt   int64  `json:"addedAt"`
+}
+
+type BrandInvite struct {
+	ID        string `json:"id"`
+	BrandSlug string `json:"brandSlug"`
+	BrandName string `json:"brandName"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	InvitedBy string `json:"invitedBy"`
+	Status    string `json:"status"`
+	CreatedAt int64  `json:"createdAt"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+type FSBrandMember struct {
+	BrandSlug string    `firestore:"brandSlug"`
+	UserID    string    `firestore:"userId"`
+	Email     string    `firestore:"email"` // на момент додавання; актуальний — у users/{id}
+	Role      string    `firestore:"role"`
+	AddedBy   string    `firestore:"addedBy"`
+	AddedAt   time.Time `firestore:"addedAt"`
+}
+
+type FSBrandInvite struct {
+	ID         string    `firestore:"id"`
+	BrandSlug  string    `firestore:"brandSlug"`
+	BrandName  string    `firestore:"brandName"`
+	Email      string    `firestore:"email"`
+	Role       string    `firestore:"role"`
+	InvitedBy  string    `firestore:"invitedBy"`
+	Status     string    `firestore:"status"`
+	CreatedAt  time.Time `firestore:"createdAt"`
+	ExpiresAt  time.Time `firestore:"expiresAt"`
+	AnsweredAt time.Time `firestore:"answeredAt,omitempty"`
+}
+
+func memberFromFS(m FSBrandMember) BrandMember {
+	return BrandMember{BrandSlug: m.BrandSlug, UserID: m.UserID, Email: m.Email, Role: m.Role, AddedBy: m.AddedBy, AddedAt: m.AddedAt.UnixMilli()}
+}
+
+func inviteFromFS(x FSBrandInvite) BrandInvite {
+	return BrandInvite{
+		ID: x.ID, BrandSlug: x.BrandSlug, BrandName: x.BrandName, Email: x.Email, Role: x.Role,
+		InvitedBy: x.InvitedBy, Status: x.Status, CreatedAt: x.CreatedAt.UnixMilli(), ExpiresAt: x.ExpiresAt.UnixMilli(),
+	}
+}
+
+func memberDoc(slug, uid string) *firestore.DocumentRef {
+	return fsDoc("brandMembers/" + slug + ":" + uid)
+}
+
+// ================== FIRESTORE ==================
+
+func fsGetMember(ctx context.Context, slug, uid string) (FSBrandMember, bool, error) {
+	s, err := memberDoc(slug, uid).Get(ctx)
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "not found") {
+			return FSBrandMember{}, false, nil
+		}
+		return FSBrandMember{}, false, err
+	}
+	var m FSBrandMember
+	if err := s.DataTo(&m); err != nil {
+		return FSBrandMember{}, false, err
+	}
+	return m, true, nil
+}
+
+func fsSetMember(ctx context.Context, slug, uid, role, by string) error {
+	_, err := memberDoc(slug, uid).Set(ctx, FSBrandMember{
+		BrandSlug: slug, UserID: uid, Email: fsUserEmail(ctx, uid), Role: role, AddedBy: by, AddedAt: time.Now(),
+	})
+	return err
+}
+
+func fsListMembers(ctx context.Context, slug string) ([]BrandMember, error) {
+	docs, err := fsCol("brandMembers").Where("brandSlug", "==", slug).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]BrandMember, 0, len(docs))
+	ids := make([]string, 0, len(docs))
+	for _, d := range docs {
+		var m FSBrandMember
+		if err := d.DataTo(&m); err != nil {
+			return nil, err
+		}
+		ids = append(ids, m.UserID)
+		out = append(out, memberFromFS(m))
+	}
+	// email міг змінитися після додавання
+	emails, err := fsUserEmails(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range out {
+		if e := emails[out[i].UserID]; e != "" {
+			out[i].Email = e
+		}
+	}
+	return out, nil
+}
+
+// fsEnsureOwnerMember — старі бренди: ownerId стає учасником-власником
+func fsEnsureOwnerMember(ctx context.Context, b Manufacturer) error {
+	if b.Owner == "" {
+		return nil
+	}
+	_, ok, err := fsGetMember(ctx, b.Slug, b.Owner)
+	if err != nil || ok {
+		return err
+	}
+	return fsSetMember(ctx, b.Slug, b.Owner, MemberOwner, b.Owner)
+}
+
+// fsBrandsForUser — бренди, де користувач учасник (разом зі старим ownerId)
+func fsBrandsForUser(ctx context.Context, uid string) ([]Manufacturer, error) {
+	if k := apiKeyFromContext(ctx); k != nil {
+		b, err := fsGetBrand(ctx, k.BrandSlug)
+		if err != nil {
+			return nil, err
+		}
+		b.Role = MemberOperator
+		return []Manufacturer{b}, nil
+	}
+	owned, err := fsListBrandsByOwner(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var out []Manufacturer
+	for _, b := range owned {
+		b.Role = MemberOwner
+		seen[b.Slug] = true
+		out = append(out, b)
+	}
+	docs, err := fsCol("brandMembers").Where("userId", "==", uid).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range docs {
+		var m FSBrandMember
+		if err := d.DataTo(&m); err != nil {
+			return nil, err
+		}
+		if seen[m.BrandSlug] {
+			continue
+		}
+		b, err := fsGetBrand(ctx, m.BrandSlug)
+		if err != nil {
+			continue
+		}
+		b.Role = m.Role
+		seen[m.BrandSlug] = true
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+// fsBrandSlugsWith — slug-и брендів користувача, де роль дає дозвіл p
+func fsBrandSlugsWith(ctx context.Context, uid string, p Perm) ([]string, error) {
+	brands, err := fsBrandsForUser(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, b := range brands {
+		if roleHas(memberRoles[b.Role], p) {
+			out = append(out, b.Slug)
+		}
+	}
+	return out, nil
+}
+
+// fsChooseBrand — бренд для створення продуктів/партій: явний slug має бути
+// брендом, де користувач власник або учасник з дозволом p; без slug — єдиний
+// такий бренд, інакше opError (немає брендів або треба обрати)
+func fsChooseBrand(ctx context.Context, uid, slug string, p Perm) (string, error) {
+	if slug = strings.TrimSpace(slug); slug != "" {
+		if _, err := fsGetBrand(ctx, slug); err != nil {
+			if strings.Contains(strings.ToLower(err.Error()), "not found") {
+				return "", opError{404, "manufacturer not found"}
+			}
+			return "", err
+		}
+		return chooseBrand(slug, roleHas(brandRole(ctx, slug, uid), p), nil)
+	}
+	slugs, err := fsBrandSlugsWith(ctx, uid, p)
+	if err != nil {
+		return "", err
+	}
+	return chooseBrand("", false, slugs)
+}
+
+// chooseBrand — рішення без Firestore: member — чи має користувач дозвіл у
+// явному slug, slugs — його бренди з дозволом (коли slug порожній)
+func chooseBrand(slug string, member bool, slugs []string) (string, error) {
+	if slug != "" {
+		if !member {
+			return "", opError{403, "not a member of brand " + slug}
+		}
+		return slug, nil
+	}
+	switch len(slugs) {
+	case 0:
+		return "", opError{403, "no brand for this account"}
+	case 1:
+		return slugs[0], nil
+	}
+	return "", opError{400, "brandSlug required: account has several brands (" + strings.Join(slugs, ", ") + ")"}
+}
+
+func fsCreateInvite(ctx context.Context, b Manufacturer, email, role, by string) (BrandInvite, error) {
+	now := time.Now()
+	x := FSBrandInvite{
+		ID:        strings.ToLower(shortID()) + randToken(6),
+		BrandSlug: b.Slug, BrandName: b.Name,
+		Email: strings.ToLower(email), Role: role, InvitedBy: by,
+		Status: InvitePending, CreatedAt: now, ExpiresAt: now.Add(inviteTTL),
+	}
+	if _, err := fsDoc("brandInvites/"+x.ID).Create(ctx, x); err != nil {
+		return BrandInvite{}, err
+	}
+	return inviteFromFS(x), nil
+}
+
+func fsListInvites(ctx context.Context, field, value string) ([]BrandInvite, error) {
+	docs, err := fsCol("brandInvites").Where(field, "==", value).
+		Where("status", "==", InvitePending).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]BrandInvite, 0, len(docs))
+	for _, d := range docs {
+		var x FSBrandInvite
+		if err := d.DataTo(&x); err != nil {
+			return nil, err
+		}
+		if time.Now().Before(x.ExpiresAt) {
+			out = append(out, inviteFromFS(x))
+		}
+	}
+	return out, nil
+}
+
+// fsAnswerInvite — прийняти/відхилити запрошення; лише адресат (за поточним
+// підтвердженим email, неперевірений передається як "")
+func fsAnswerInvite(ctx context.Context, id, uid, email string, accept bool) (BrandInvite, error) {
+	var out FSBrandInvite
+	err := fsClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		ref := fsDoc("brandInvites/" + id)
+		s, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		if err := s.DataTo(&out); err != nil {
+			return err
+		}
+		switch {
+		case email == "" || !strings.EqualFold(out.Email, email):
+			return fmt.Errorf("forbidden")
+		case out.Status != InvitePending:
+			return fmt.Errorf("already %s", out.Status)
+		case time.Now().After(out.ExpiresAt):
+			return fmt.Errorf("invite expired")
+		}
+		out.Status = InviteDeclined
+		if accept {
+			// наявне членство не перезаписуємо: інакше власник, запрошений
+			// раніше як viewer, понизив би себе прийняттям
+			if _, err := tx.Get(memberDoc(out.BrandSlug, uid)); err == nil {
+				return fmt.Errorf("already a member")
+			} else if !strings.Contains(strings.ToLower(err.Error()), "not found") {
+				return err
+			}
+			out.Status = InviteAccepted
+			if err := tx.Create(memberDoc(out.BrandSlug, uid), FSBrandMember{
+				BrandSlug: out.BrandSlug, UserID: uid, Email: strings.ToLower(email), Role: out.Role,
+				AddedBy: out.InvitedBy, AddedAt: time.Now(),
+			}); err != nil {
+				return err
+			}
+		}
+		return tx.Update(ref, []firestore.Update{
+			{Path: "status", Value: out.Status},
+			{Path: "answeredAt", Value: time.Now()},
+		})
+	})
+	return inviteFromFS(out), err
+}
+
+// fsRevokeInvite — відкликати лише запрошення, що ще чекає відповіді;
+// прийняті й відхилені лишаються в історії як є
+func fsRevokeInvite(ctx context.Context, slug, id string) (BrandInvite, error) {
+	var out FSBrandInvite
+	err := fsClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		ref := fsDoc("brandInvites/" + id)
+		s, err := tx.Get(ref)
+		if err != nil {
+			if strings.Contains(strings.ToLower(err.Error()), "not found") {
+				return opError{404, "invite not found"}
+			}
+			return err
+		}
+		if err := s.DataTo(&out); err != nil {
+			return err
+		}
+		if out.BrandSlug != slug {
+			return opError{404, "invite not found"}
+		}
+		if out.Status != InvitePending {
+			return opError{409, "invite already " + out.Status}
+		}
+		out.Status = InviteRevoked
+		return tx.Update(ref, []firestore.Update{{Path: "status", Value: out.Status}})
+	})
+	return inviteFromFS(out), err
+}
+
+// ================== HTTP ==================
+
+// GET    /api/manufacturers/{slug}/members                — учасники
+// POST   /api/manufacturers/{slug}/members/invite         — запросити {email, role}
+// POST   /api/manufacturers/{slug}/members/{userId}       — змінити роль {role}
+// DELETE /api/manufacturers/{slug}/members/{userId}       — видалити
+// GET    /api/manufacturers/{slug}/invites                — очікують відповіді
+// DELETE /api/manufacturers/{slug}/invites/{id}           — відкликати запрошення
+func brandMembers(w http.ResponseWriter, r *http.Request, slug string, parts []string) {
+	u := currentUser(r)
+	if u == "" {
+		writeJSON(w, 401, ErrorResp{"missing user"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	b, err := fsGetBrand(ctx, slug)
+	if err != nil {
+		writeJSON(w, 404, ErrorResp{"manufacturer not found"})
+		return
+	}
+	if !canBrand(ctx, u, slug, PermBrandRead) {
+		writeJSON(w, 403, ErrorResp{"forbidden"})
+		return
+	}
+	if err := fsEnsureOwnerMember(ctx, b); err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+	if r.Method != http.MethodGet && !canBrand(ctx, u, slug, PermBrandMembers) {
+		writeJSON(w, 403, ErrorResp{"forbidden"})
+		return
+	}
+	// менеджер не керує власниками
+	ownerOK := canBrand(ctx, u, slug, PermBrandOwners)
+
+	var body struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSON(w, 400, ErrorResp{"invalid json"})
+			return
+		}
+		body.Role = strings.ToLower(strings.TrimSpace(body.Role))
+		if _, ok := memberRoles[body.Role]; !ok {
+			writeJSON(w, 400, ErrorResp{"role must be owner, manager, operator or viewer"})
+			return
+		}
+		if body.Role == MemberOwner && !ownerOK {
+			writeJSON(w, 403, ErrorResp{"only owners can grant owner role"})
+			return
+		}
+	}
+
+	switch {
+	case parts[0] == "members" && len(parts) == 1 && r.Method == http.MethodGet:
+		list, err := fsListMembers(ctx, slug)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		writeJSON(w, 200, list)
+
+	case parts[0] == "members" && len(parts) == 2 && parts[1] == "invite" && r.Method == http.MethodPost:
+		email := strings.ToLower(strings.TrimSpace(body.Email))
+		if !strings.Contains(email, "@") {
+			writeJSON(w, 400, ErrorResp{"email required"})
+			return
+		}
+		// email не унікальний — перевіряємо кожного користувача з ним
+		invitees, err := fsUsersByEmail(ctx, email)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		for _, invitee := range invitees {
+			if _, ok, err := fsGetMember(ctx, slug, invitee.ID); err != nil {
+				writeJSON(w, 500, ErrorResp{err.Error()})
+				return
+			} else if ok {
+				writeJSON(w, 409, ErrorResp{"already a member"})
+				return
+			}
+		}
+		inv, err := fsCreateInvite(ctx, b, email, body.Role, u)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		auditRecord(ctx, r, AuditMemberInvite, auditBrand(slug), nil,
+			map[string]any{"invite": inv.ID, "email": inv.Email, "role": inv.Role})
+		writeJSON(w, 201, inv)
+
+	case parts[0] == "members" && len(parts) == 2 && (r.Method == http.MethodPost || r.Method == http.MethodDelete):
+		uid := parts[1]
+		m, ok, err := fsGetMember(ctx, slug, uid)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		if !ok {
+			writeJSON(w, 404, ErrorResp{"member not found"})
+			return
+		}
+		if m.Role == MemberOwner && !ownerOK {
+			writeJSON(w, 403, ErrorResp{"only owners can change owners"})
+			return
+		}
+		// основний власник (ownerId) лишається власником — бренд не залишиться без власника
+		if uid == b.Owner {
+			writeJSON(w, 409, ErrorResp{"cannot remove or demote the primary owner"})
+			return
+		}
+		before := map[string]any{"userId": uid, "role": m.Role}
+		action := AuditMemberRemove
+		if r.Method == http.MethodDelete {
+			_, err = memberDoc(slug, uid).Delete(ctx)
+		} else {
+			action = AuditMemberRole
+			_, err = memberDoc(slug, uid).Update(ctx, []firestore.Update{{Path: "role", Value: body.Role}})
+			m.Role = body.Role
+		}
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		var after any
+		if action == AuditMemberRole {
+			after = map[string]any{"userId": uid, "role": m.Role}
+		}
+		auditRecord(ctx, r, action, auditBrand(slug), before, after)
+		writeJSON(w, 200, map[string]any{"ok": true, "member": memberFromFS(m), "removed": r.Method == http.MethodDelete})
+
+	case parts[0] == "invites" && len(parts) == 1 && r.Method == http.MethodGet:
+		list, err := fsListInvites(ctx, "brandSlug", slug)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		writeJSON(w, 200, list)
+
+	case parts[0] == "invites" && len(parts) == 2 && r.Method == http.MethodDelete:
+		inv, err := fsRevokeInvite(ctx, slug, parts[1])
+		if err != nil {
+			writeOpErr(w, err)
+			return
+		}
+		auditRecord(ctx, r, AuditMemberInviteRevoke, auditBrand(slug),
+			map[string]any{"invite": inv.ID, "email": inv.Email, "role": inv.Role, "status": InvitePending},
+			map[string]any{"invite": inv.ID, "status": inv.Status})
+		writeJSON(w, 200, map[string]any{"ok": true})
+
+	default:
+		writeJSON(w, 405, ErrorResp{"Method not allowed"})
+	}
+}
+
+// GET  /api/invites                   — мої запрошення
+// POST /api/invites/{id}/accept|decline
+func myInvites(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		returnOK(w)
+		return
+	}
+	u := currentUser(r)
+	if u == "" {
+		writeJSON(w, 401, ErrorResp{"missing user"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/invites"), "/")
+	parts := strings.Split(rest, "/")
+	switch {
+	case rest == "" && r.Method == http.MethodGet:
+		email := currentVerifiedEmail(r)
+		if email == "" {
+			writeJSON(w, 200, []BrandInvite{})
+			return
+		}
+		list, err := fsListInvites(ctx, "email", email)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		writeJSON(w, 200, list)
+
+	case len(parts) == 2 && (parts[1] == "accept" || parts[1] == "decline") && r.Method == http.MethodPost:
+		if currentVerifiedEmail(r) == "" {
+			writeJSON(w, 403, ErrorResp{"verified email required"})
+			return
+		}
+		inv, err := fsAnswerInvite(ctx, parts[0], u, currentVerifiedEmail(r), parts[1] == "accept")
+		if err != nil {
+			msg := strings.ToLower(err.Error())
+			switch {
+			case strings.Contains(msg, "not found"):
+				writeJSON(w, 404, ErrorResp{"invite not found"})
+			case msg == "forbidden":
+				writeJSON(w, 403, ErrorResp{"forbidden"})
+			case strings.Contains(msg, "already"), strings.Contains(msg, "expired"):
+				writeJSON(w, 409, ErrorResp{err.Error()})
+			default:
+				writeJSON(w, 500, ErrorResp{err.Error()})
+			}
+			return
+		}
+		if inv.Status == InviteAccepted {
+			auditRecord(ctx, r, AuditMemberAdd, auditBrand(inv.BrandSlug), nil,
+				map[string]any{"userId": u, "role": inv.Role, "invite": inv.ID})
+		}
+		writeJSON(w, 200, map[string]any{"ok": true, "invite": inv})
+
+	default:
+		writeJSON(w, 405, ErrorResp{"Method not allowed"})
+	}
+}
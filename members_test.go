@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestChooseBrand(t *testing.T) {
+	tests := []struct {
+		name     string
+		slug     string
+		member   bool
+		slugs    []string
+		want     string
+		wantCode int
+	}{
+		{"no brands", "", false, nil, "", 403},
+		{"single brand", "", false, []string{"acme"}, "acme", 0},
+		{"several, no slug", "", false, []string{"acme", "globex"}, "", 400},
+		{"several, slug member", "globex", true, []string{"acme", "globex"}, "globex", 0},
+		{"slug, not a member", "globex", false, nil, "", 403},
+	}
+	for _, tt := range tests {
+		got, err := chooseBrand(tt.slug, tt.member, tt.slugs)
+		var oe opError
+		code := 0
+		if errors.As(err, &oe) {
+			code = oe.Code
+		} else if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if got != tt.want || code != tt.wantCode {
+			t.Errorf("%s: (%q, %d), want (%q, %d)", tt.name, got, code, tt.want, tt.wantCode)
+		}
+	}
+}
@@ -0,0 +1,430 @@
+// nfc.go — tap-to-verify для NTAG 424 DNA: перевірка SUN-повідомлень
+// (зашифровані UID + SDMReadCtr і SDMMAC, NXP AN12196) та захист від повторів.
+//
+// У режимі batch ключі спільні для всієї партії, а MAC не покриває tokenId,
+// тож SUN мітки A був би валідним і для продукту B. Тому UID мітки
+// записується під час підключення і дотик з іншим UID відхиляється.
+
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+// NFC_MASTER_KEY (base64) — з нього диверсифікуються ключі міток
+var nfcMasterKey = func() []byte {
+	k, err := base64.StdEncoding.DecodeString(strings.TrimSpace(os.Getenv("NFC_MASTER_KEY")))
+	if err != nil || len(k) < 16 {
+		return nil
+	}
+	return k
+}()
+
+const (
+	nfcModeProduct = "product"
+	nfcModeBatch   = "batch"
+
+	// PICCDataTag: UID mirroring + SDMReadCtr mirroring, довжина UID 7
+	piccTagUIDCtr = 0xC7
+)
+
+type FSNFCTag struct {
+	TokenID   int64     `firestore:"tokenId"`
+	Mode      string    `firestore:"mode"`
+	UID       string    `firestore:"uid"`
+	Counter   int64     `firestore:"counter"`
+	EnabledBy string    `firestore:"enabledBy"`
+	EnabledAt time.Time `firestore:"enabledAt"`
+	LastTapAt time.Time `firestore:"lastTapAt,omitempty"`
+}
+
+type sunMessage struct {
+	UID     []byte
+	Counter uint32
+}
+
+// ================== CRYPTO ==================
+
+func cmacSubkey(b []byte) []byte {
+	out := make([]byte, len(b))
+	var carry byte
+	for i := len(b) - 1; i >= 0; i-- {
+		out[i] = b[i]<<1 | carry
+		carry = b[i] >> 7
+	}
+	if carry != 0 {
+		out[len(out)-1] ^= 0x87
+	}
+	return out
+}
+
+// aesCMAC — RFC 4493
+func aesCMAC(key, msg []byte) ([]byte, error) {
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	const bs = aes.BlockSize
+	l := make([]byte, bs)
+	c.Encrypt(l, l)
+	k1 := cmacSubkey(l)
+	k2 := cmacSubkey(k1)
+
+	n := (len(msg) + bs - 1) / bs
+	complete := n > 0 && len(msg)%bs == 0
+	if n == 0 {
+		n = 1
+	}
+	last := make([]byte, bs)
+	if complete {
+		copy(last, msg[(n-1)*bs:])
+		for i := range last {
+			last[i] ^= k1[i]
+		}
+	} else {
+		tail := msg[(n-1)*bs:]
+		copy(last, tail)
+		last[len(tail)] = 0x80
+		for i := range last {
+			last[i] ^= k2[i]
+		}
+	}
+	x := make([]byte, bs)
+	for i := 0; i < n-1; i++ {
+		for j := 0; j < bs; j++ {
+			x[j] ^= msg[i*bs+j]
+		}
+		c.Encrypt(x, x)
+	}
+	for j := 0; j < bs; j++ {
+		x[j] ^= last[j]
+	}
+	c.Encrypt(x, x)
+	return x, nil
+}
+
+// decryptPICCData — AES-128-CBC з нульовим IV, один блок
+func decryptPICCData(metaKey, enc []byte) (sunMessage, error) {
+	if len(enc) != aes.BlockSize {
+		return sunMessage{}, fmt.Errorf("picc data must be 16 bytes")
+	}
+	c, err := aes.NewCipher(metaKey)
+	if err != nil {
+		return sunMessage{}, err
+	}
+	plain := make([]byte, aes.BlockSize)
+	cipher.NewCBCDecrypter(c, make([]byte, aes.BlockSize)).CryptBlocks(plain, enc)
+	if plain[0] != piccTagUIDCtr {
+		return sunMessage{}, fmt.Errorf("unexpected picc data tag")
+	}
+	return sunMessage{
+		UID:     append([]byte{}, plain[1:8]...),
+		Counter: uint32(plain[8]) | uint32(plain[9])<<8 | uint32(plain[10])<<16,
+	}, nil
+}
+
+// sdmMAC — SDMMAC над порожнім вхідним рядком (SDMMACInputOffset == SDMMACOffset):
+// сесійний ключ із SV2, потім CMAC і усічення до непарних байтів.
+func sdmMAC(fileKey []byte, m sunMessage) ([]byte, error) {
+	sv2 := []byte{0x3C, 0xC3, 0x00, 0x01, 0x00, 0x80}
+	sv2 = append(sv2, m.UID...)
+	sv2 = append(sv2, byte(m.Counter), byte(m.Counter>>8), byte(m.Counter>>16))
+	ks, err := aesCMAC(fileKey, sv2)
+	if err != nil {
+		return nil, err
+	}
+	full, err := aesCMAC(ks, nil)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, 8)
+	for i := 1; i < len(full); i += 2 {
+		out = append(out, full[i])
+	}
+	return out, nil
+}
+
+// verifySUN розшифровує PICCData і перевіряє SDMMAC
+func verifySUN(metaKey, fileKey []byte, piccHex, macHex string) (sunMessage, error) {
+	enc, err := hex.DecodeString(strings.TrimSpace(piccHex))
+	if err != nil {
+		return sunMessage{}, fmt.Errorf("bad picc data")
+	}
+	mac, err := hex.DecodeString(strings.TrimSpace(macHex))
+	if err != nil || len(mac) != 8 {
+		return sunMessage{}, fmt.Errorf("bad cmac")
+	}
+	m, err := decryptPICCData(metaKey, enc)
+	if err != nil {
+		return sunMessage{}, err
+	}
+	want, err := sdmMAC(fileKey, m)
+	if err != nil {
+		return sunMessage{}, err
+	}
+	if subtle.ConstantTimeCompare(want, mac) != 1 {
+		return sunMessage{}, fmt.Errorf("cmac mismatch")
+	}
+	return m, nil
+}
+
+// nfcKeys — диверсифіковані AES-128 ключі мітки: SDMMetaRead і SDMFileRead.
+// У режимі batch ключі спільні для партії, у product — окремі для токена.
+func nfcKeys(mode string, p Product) (metaKey, fileKey []byte, err error) {
+	if nfcMasterKey == nil {
+		return nil, nil, fmt.Errorf("nfc not configured")
+	}
+	scope := "product:" + strconv.FormatInt(p.TokenID, 10)
+	if mode == nfcModeBatch {
+		if p.BatchID == "" {
+			return nil, nil, fmt.Errorf("product has no batch")
+		}
+		scope = "batch:" + p.BatchID
+	}
+	derive := func(purpose string) []byte {
+		m := hmac.New(sha256.New, nfcMasterKey)
+		m.Write([]byte("marki-ntag424|" + scope + "|" + purpose))
+		return m.Sum(nil)[:16]
+	}
+	return derive("sdm-meta-read"), derive("sdm-file-read"), nil
+}
+
+func nfcTapURL(id int64) string {
+	return fmt.Sprintf("%s/api/nfc/%d?picc_data={PICCData}&cmac={SDMMAC}", publicBase, id)
+}
+
+// ================== FIRESTORE ==================
+
+func fsGetNFCTag(ctx context.Context, id int64) (FSNFCTag, bool, error) {
+	d, err := fsDoc("nfcTags/" + strconv.FormatInt(id, 10)).Get(ctx)
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "not found") {
+			return FSNFCTag{}, false, nil
+		}
+		return FSNFCTag{}, false, err
+	}
+	var t FSNFCTag
+	if err := d.DataTo(&t); err != nil {
+		return FSNFCTag{}, false, err
+	}
+	return t, true, nil
+}
+
+// checkNFCTap: лічильник має строго зростати, UID прив'язується при першому
+// дотику (batch — при підключенні) і далі не може змінитись.
+func checkNFCTap(t FSNFCTag, uid string, m sunMessage) error {
+	if t.Mode == nfcModeBatch && t.UID == "" {
+		return fmt.Errorf("batch tag has no enrolled uid")
+	}
+	if t.UID != "" && t.UID != uid {
+		return fmt.Errorf("tag uid mismatch")
+	}
+	if t.UID != "" && int64(m.Counter) <= t.Counter {
+		return fmt.Errorf("replayed tap counter")
+	}
+	return nil
+}
+
+// fsAcceptNFCTap атомарно приймає дотик (див. checkNFCTap)
+func fsAcceptNFCTap(ctx context.Context, id int64, m sunMessage) error {
+	ref := fsDoc("nfcTags/" + strconv.FormatInt(id, 10))
+	uid := strings.ToUpper(hex.EncodeToString(m.UID))
+	return fsClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		s, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		var t FSNFCTag
+		if err := s.DataTo(&t); err != nil {
+			return err
+		}
+		if err := checkNFCTap(t, uid, m); err != nil {
+			return err
+		}
+		return tx.Update(ref, []firestore.Update{
+			{Path: "uid", Value: uid},
+			{Path: "counter", Value: int64(m.Counter)},
+			{Path: "lastTapAt", Value: time.Now()},
+		})
+	})
+}
+
+// ================== HTTP ==================
+
+// nfcEnrollUID — UID мітки з тіла запиту (7 байтів hex); обов'язковий для batch
+func nfcEnrollUID(mode, raw string) (string, error) {
+	raw = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(raw), ":", ""))
+	if raw == "" {
+		if mode == nfcModeBatch {
+			return "", fmt.Errorf("uid of the programmed tag is required in batch mode")
+		}
+		return "", nil
+	}
+	if b, err := hex.DecodeString(raw); err != nil || len(b) != 7 {
+		return "", fmt.Errorf("uid must be 7 bytes hex")
+	}
+	return raw, nil
+}
+
+// POST /api/products/{id}/nfc {mode, uid?} — увімкнути NFC і видати ключі для
+// програмування мітки; у batch UID мітки обов'язковий
+func productNFCEnroll(w http.ResponseWriter, r *http.Request, id int64) {
+	u := currentUser(r)
+	if u == "" {
+		writeJSON(w, 401, ErrorResp{"missing user"})
+		return
+	}
+	if nfcMasterKey == nil {
+		writeJSON(w, 503, ErrorResp{"nfc not configured"})
+		return
+	}
+	var body struct {
+		Mode string `json:"mode"`
+		UID  string `json:"uid"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	mode := strings.ToLower(strings.TrimSpace(body.Mode))
+	if mode == "" {
+		mode = nfcModeProduct
+	}
+	if mode != nfcModeProduct && mode != nfcModeBatch {
+		writeJSON(w, 400, ErrorResp{"mode must be product or batch"})
+		return
+	}
+	tagUID, err := nfcEnrollUID(mode, body.UID)
+	if err != nil {
+		writeJSON(w, 400, ErrorResp{err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+	p, ok, err := fsGetProduct(ctx, id)
+	if err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+	if !ok {
+		writeJSON(w, 404, ErrorResp{"product not found"})
+		return
+	}
+	if !canBrand(ctx, u, p.BrandSlug, PermBrandProducts) {
+		writeJSON(w, 403, ErrorResp{"forbidden"})
+		return
+	}
+	metaKey, fileKey, err := nfcKeys(mode, p)
+	if err != nil {
+		writeJSON(w, 400, ErrorResp{err.Error()})
+		return
+	}
+	// перевипуск скидає прив'язку UID і лічильник — мітку програмують заново
+	_, err = fsDoc("nfcTags/"+strconv.FormatInt(id, 10)).Set(ctx, FSNFCTag{
+		TokenID: id, Mode: mode, UID: tagUID, EnabledBy: strings.ToLower(u), EnabledAt: time.Now(),
+	})
+	if err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+	writeJSON(w, 200, map[string]any{
+		"ok":              true,
+		"mode":            mode,
+		"uid":             tagUID,
+		"sdmMetaReadKey":  strings.ToUpper(hex.EncodeToString(metaKey)),
+		"sdmFileReadKey":  strings.ToUpper(hex.EncodeToString(fileKey)),
+		"urlTemplate":     nfcTapURL(id),
+		"piccDataTag":     fmt.Sprintf("%02X", piccTagUIDCtr),
+		"sdmMacInputNote": "SDMMACInputOffset must equal SDMMACOffset (empty MAC input)",
+	})
+}
+
+// GET /api/nfc/{id}?picc_data=..&cmac=.. (також e= / c=) — відповідь як у /api/verify/{id}
+func nfcVerify(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		returnOK(w)
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeJSON(w, 405, ErrorResp{"Method not allowed"})
+		return
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/nfc/"), 10, 64)
+	if err != nil {
+		writeJSON(w, 400, ErrorResp{"bad id"})
+		return
+	}
+	q := r.URL.Query()
+	picc, mac := q.Get("picc_data"), q.Get("cmac")
+	if picc == "" {
+		picc = q.Get("e")
+	}
+	if mac == "" {
+		mac = q.Get("c")
+	}
+	if picc == "" || mac == "" {
+		writeJSON(w, 400, ErrorResp{"picc_data and cmac required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+	tag, ok, err := fsGetNFCTag(ctx, id)
+	if err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+	if !ok {
+		writeJSON(w, 404, ErrorResp{"nfc not enabled for product"})
+		return
+	}
+	p, ok, err := fsGetProduct(ctx, id)
+	if err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+	if !ok {
+		writeJSON(w, 404, ErrorResp{"product not found"})
+		return
+	}
+	metaKey, fileKey, err := nfcKeys(tag.Mode, p)
+	if err != nil {
+		writeJSON(w, 503, ErrorResp{err.Error()})
+		return
+	}
+	m, err := verifySUN(metaKey, fileKey, picc, mac)
+	if err != nil {
+		writeJSON(w, 403, ErrorResp{"invalid tag message: " + err.Error()})
+		return
+	}
+	if err := fsAcceptNFCTap(ctx, id, m); err != nil {
+		msg := err.Error()
+		if strings.Contains(msg, "replayed") || strings.Contains(msg, "mismatch") || strings.Contains(msg, "enrolled") {
+			writeJSON(w, 409, ErrorResp{msg})
+			return
+		}
+		writeJSON(w, 500, ErrorResp{msg})
+		return
+	}
+
+	res := verifyResult(r, p)
+	res["nfc"] = map[string]any{
+		"uid":     strings.ToUpper(hex.EncodeToString(m.UID)),
+		"counter": m.Counter,
+	}
+	writeJSON(w, 200, res)
+}
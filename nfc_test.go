@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// RFC 4493, розділ 4: ключ 2b7e1516..., повідомлення довжиною 0, 16, 40 і 64
+func TestAESCMACRFC4493(t *testing.T) {
+	key := mustHex(t, "2b7e151628aed2a6abf7158809cf4f3c")
+	msg := mustHex(t, "6bc1bee22e409f96e93d7e117393172a"+
+		"ae2d8a571e03ac9c9eb76fac45af8e51"+
+		"30c81c46a35ce411e5fbc1191a0a52ef"+
+		"f69f2445df4f9b17ad2b417be66c3710")
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "bb1d6929e95937287fa37d129b756746"},
+		{16, "070a16b46b4d4144f79bdd9dd04a287c"},
+		{40, "dfa66747de9ae63030ca32611497c827"},
+		{64, "51f0bebf7e3b9d92fc49741779363cfe"},
+	}
+	for _, tt := range tests {
+		got, err := aesCMAC(key, msg[:tt.n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(got) != tt.want {
+			t.Errorf("CMAC(Mlen=%d) = %x, want %s", tt.n, got, tt.want)
+		}
+	}
+	if got := hex.EncodeToString(cmacSubkey(mustHex(t, "7df76b0c1ab899b33e42f047b91b546f"))); got != "fbeed618357133667c85e08f7236a8de" {
+		t.Errorf("K1 = %s", got)
+	}
+}
+
+// NXP AN12196, SUN-повідомлення з нульовими ключами SDMMetaRead і SDMFileRead
+const (
+	an12196PICC = "EF963FF7828658A599F3041510671E88"
+	an12196CMAC = "94EED9EE65337086"
+)
+
+func TestVerifySUNAN12196(t *testing.T) {
+	zero := make([]byte, 16)
+	m, err := verifySUN(zero, zero, an12196PICC, an12196CMAC)
+	if err != nil {
+		t.Fatalf("verifySUN: %v", err)
+	}
+	if got := strings.ToUpper(hex.EncodeToString(m.UID)); got != "04DE5F1EACC040" {
+		t.Errorf("UID = %s, want 04DE5F1EACC040", got)
+	}
+	if m.Counter != 61 {
+		t.Errorf("counter = %d, want 61", m.Counter)
+	}
+	// регістр і пробіли в параметрах URL не важливі
+	if _, err := verifySUN(zero, zero, " "+strings.ToLower(an12196PICC)+" ", strings.ToLower(an12196CMAC)); err != nil {
+		t.Errorf("lower-case input rejected: %v", err)
+	}
+}
+
+func TestVerifySUNRejectsTampering(t *testing.T) {
+	zero := make([]byte, 16)
+	other := bytes.Repeat([]byte{1}, 16)
+	tests := []struct {
+		name             string
+		metaKey, fileKey []byte
+		picc, cmac       string
+	}{
+		{"flipped cmac bit", zero, zero, an12196PICC, "94EED9EE65337087"},
+		{"zero cmac", zero, zero, an12196PICC, "0000000000000000"},
+		{"short cmac", zero, zero, an12196PICC, "94EED9EE653370"},
+		{"not hex", zero, zero, an12196PICC, "94EED9EE6533708Z"},
+		{"tampered picc", zero, zero, "EF963FF7828658A599F3041510671E89", an12196CMAC},
+		{"short picc", zero, zero, an12196PICC[:30], an12196CMAC},
+		{"wrong file key", zero, other, an12196PICC, an12196CMAC},
+		{"wrong meta key", other, zero, an12196PICC, an12196CMAC},
+	}
+	for _, tt := range tests {
+		if _, err := verifySUN(tt.metaKey, tt.fileKey, tt.picc, tt.cmac); err == nil {
+			t.Errorf("%s: accepted", tt.name)
+		}
+	}
+}
+
+func TestCheckNFCTapReplay(t *testing.T) {
+	const uid = "04DE5F1EACC040"
+	tag := FSNFCTag{UID: uid, Counter: 61}
+	tests := []struct {
+		name    string
+		tag     FSNFCTag
+		uid     string
+		counter uint32
+		ok      bool
+	}{
+		{"first tap binds uid", FSNFCTag{}, uid, 61, true},
+		{"next counter", tag, uid, 62, true},
+		{"replayed counter", tag, uid, 61, false},
+		{"older counter", tag, uid, 10, false},
+		{"other uid", tag, "04AABBCCDDEEFF", 62, false},
+	}
+	for _, tt := range tests {
+		err := checkNFCTap(tt.tag, tt.uid, sunMessage{Counter: tt.counter})
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v", tt.name, err)
+		}
+	}
+
+	// той самий валідний SUN-URL, показаний удруге, відхиляється
+	zero := make([]byte, 16)
+	m, err := verifySUN(zero, zero, an12196PICC, an12196CMAC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.ToUpper(hex.EncodeToString(m.UID))
+	if err := checkNFCTap(FSNFCTag{}, got, m); err != nil {
+		t.Fatalf("first tap: %v", err)
+	}
+	if err := checkNFCTap(FSNFCTag{UID: got, Counter: int64(m.Counter)}, got, m); err == nil {
+		t.Fatal("replayed SUN message accepted")
+	}
+}
+
+// sunFor — SUN-повідомлення, яке мітка з цими ключами згенерувала б (PICCData і SDMMAC)
+func sunFor(t *testing.T, metaKey, fileKey []byte, m sunMessage) (picc, mac string) {
+	t.Helper()
+	plain := make([]byte, aes.BlockSize)
+	plain[0] = piccTagUIDCtr
+	copy(plain[1:8], m.UID)
+	plain[8], plain[9], plain[10] = byte(m.Counter), byte(m.Counter>>8), byte(m.Counter>>16)
+	c, err := aes.NewCipher(metaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := make([]byte, aes.BlockSize)
+	cipher.NewCBCEncrypter(c, make([]byte, aes.BlockSize)).CryptBlocks(enc, plain)
+	tag, err := sdmMAC(fileKey, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(enc), hex.EncodeToString(tag)
+}
+
+// ключі партії спільні, тож SUN мітки A криптографічно валідний і для B —
+// відхиляє його лише UID, записаний при підключенні B
+func TestNFCBatchSUNNotTransferable(t *testing.T) {
+	saved := nfcMasterKey
+	t.Cleanup(func() { nfcMasterKey = saved })
+	nfcMasterKey = bytes.Repeat([]byte{7}, 32)
+
+	a := Product{TokenID: 1, BatchID: "b-1"}
+	b := Product{TokenID: 2, BatchID: "b-1"}
+	metaA, fileA, err := nfcKeys(nfcModeBatch, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metaB, fileB, _ := nfcKeys(nfcModeBatch, b)
+	if !bytes.Equal(metaA, metaB) || !bytes.Equal(fileA, fileB) {
+		t.Fatal("batch keys must be shared")
+	}
+
+	uidA, uidB := mustHex(t, "04A1A2A3A4A5A6"), mustHex(t, "04B1B2B3B4B5B6")
+	picc, mac := sunFor(t, metaA, fileA, sunMessage{UID: uidA, Counter: 5})
+
+	m, err := verifySUN(metaB, fileB, picc, mac)
+	if err != nil {
+		t.Fatalf("SUN of A under B's keys: %v", err)
+	}
+	got := strings.ToUpper(hex.EncodeToString(m.UID))
+	tagA := FSNFCTag{TokenID: 1, Mode: nfcModeBatch, UID: strings.ToUpper(hex.EncodeToString(uidA))}
+	tagB := FSNFCTag{TokenID: 2, Mode: nfcModeBatch, UID: strings.ToUpper(hex.EncodeToString(uidB))}
+	if err := checkNFCTap(tagA, got, m); err != nil {
+		t.Fatalf("own tap rejected: %v", err)
+	}
+	if err := checkNFCTap(tagB, got, m); err == nil {
+		t.Fatal("SUN of product A accepted for product B in the same batch")
+	}
+	if err := checkNFCTap(FSNFCTag{TokenID: 2, Mode: nfcModeBatch}, got, m); err == nil {
+		t.Fatal("batch tag without an enrolled uid accepted a tap")
+	}
+}
+
+func TestNFCEnrollUID(t *testing.T) {
+	tests := []struct {
+		mode, in, want string
+		ok             bool
+	}{
+		{nfcModeBatch, "04de5f1eacc040", "04DE5F1EACC040", true},
+		{nfcModeBatch, "04:DE:5F:1E:AC:C0:40", "04DE5F1EACC040", true},
+		{nfcModeBatch, "", "", false},
+		{nfcModeBatch, "04DE5F", "", false},
+		{nfcModeBatch, "zz", "", false},
+		{nfcModeProduct, "", "", true},
+		{nfcModeProduct, "04DE5F1EACC040", "04DE5F1EACC040", true},
+	}
+	for _, tt := range tests {
+		got, err := nfcEnrollUID(tt.mode, tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("nfcEnrollUID(%s, %q) = %q, %v", tt.mode, tt.in, got, err)
+		}
+	}
+}
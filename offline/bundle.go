@@ -0,0 +1,215 @@
+// Package offline — підписані пакети записів перевірки продуктів для точок
+// продажу без стабільного зв'язку: сервер експортує пакет для партії або SKU,
+// каса перевіряє скани локально і бачить, наскільки пакет застарів.
+package offline
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const Version = 1
+
+// Record — мінімум для перевірки на касі
+type Record struct {
+	TokenID    int64  `json:"t"`
+	SerialHash string `json:"h"` // SerialHash(bundle.Salt, serial)
+	State      string `json:"s"`
+	Brand      string `json:"b"`
+}
+
+type Bundle struct {
+	Version   int      `json:"v"`
+	Issuer    string   `json:"iss"`
+	KeyID     string   `json:"kid"`
+	Scope     string   `json:"scope"` // "batch:<id>" або "sku:<brand>/<sku>"
+	CreatedAt int64    `json:"iat"`   // unix seconds
+	Salt      []byte   `json:"salt"`  // власна сіль пакета: серверний HMAC-ключ не покидає сервер
+	Records   []Record `json:"records"`
+
+	index map[int64]int
+}
+
+// envelope — те, що лежить у файлі: gzip(JSON пакета) + підпис Ed25519
+type envelope struct {
+	Version int    `json:"v"`
+	KeyID   string `json:"kid"`
+	Payload string `json:"payload"`
+	Sig     string `json:"sig"`
+}
+
+var (
+	ErrBadSignature = errors.New("offline: bad bundle signature")
+	ErrUnknownKey   = errors.New("offline: unknown bundle key")
+)
+
+// NormalizeSerial повторює серверну нормалізацію надрукованого серійника
+func NormalizeSerial(s string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(s), " ", ""))
+}
+
+// SerialHash — sha256(salt || serial), hex
+func SerialHash(salt []byte, serial string) string {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(NormalizeSerial(serial)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func signedBytes(kid string, payload []byte) []byte {
+	return append([]byte(fmt.Sprintf("marki-offline|%d|%s|", Version, kid)), payload...)
+}
+
+// Seal підписує пакет і повертає компактний файл
+func Seal(b Bundle, priv ed25519.PrivateKey) ([]byte, error) {
+	b.Version = Version
+	raw, err := json.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+	var gz bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&gz, gzip.BestCompression)
+	if _, err := zw.Write(raw); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(envelope{
+		Version: Version,
+		KeyID:   b.KeyID,
+		Payload: base64.RawStdEncoding.EncodeToString(gz.Bytes()),
+		Sig:     base64.RawStdEncoding.EncodeToString(ed25519.Sign(priv, signedBytes(b.KeyID, gz.Bytes()))),
+	})
+}
+
+// Open перевіряє підпис одним із відомих ключів і розпаковує пакет
+func Open(data []byte, keys map[string]ed25519.PublicKey) (*Bundle, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("offline: malformed bundle: %v", err)
+	}
+	if env.Version != Version {
+		return nil, fmt.Errorf("offline: unsupported bundle version %d", env.Version)
+	}
+	pub, ok := keys[env.KeyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	gz, err1 := base64.RawStdEncoding.DecodeString(env.Payload)
+	sig, err2 := base64.RawStdEncoding.DecodeString(env.Sig)
+	if err1 != nil || err2 != nil {
+		return nil, fmt.Errorf("offline: malformed bundle encoding")
+	}
+	if !ed25519.Verify(pub, signedBytes(env.KeyID, gz), sig) {
+		return nil, ErrBadSignature
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		return nil, err
+	}
+	raw, err := io.ReadAll(io.LimitReader(zr, 64<<20))
+	if err != nil {
+		return nil, err
+	}
+	var b Bundle
+	if err := json.Unmarshal(raw, &b); err != nil {
+		return nil, err
+	}
+	if b.KeyID != env.KeyID {
+		return nil, ErrBadSignature
+	}
+	b.index = make(map[int64]int, len(b.Records))
+	for i, r := range b.Records {
+		b.index[r.TokenID] = i
+	}
+	return &b, nil
+}
+
+// KeysFromDID дістає Ed25519-ключі з DID-документа емітента (/.well-known/did.json)
+func KeysFromDID(doc []byte) (map[string]ed25519.PublicKey, error) {
+	var d struct {
+		VerificationMethod []struct {
+			PublicKeyJwk struct {
+				Kty string `json:"kty"`
+				Crv string `json:"crv"`
+				Kid string `json:"kid"`
+				X   string `json:"x"`
+			} `json:"publicKeyJwk"`
+		} `json:"verificationMethod"`
+	}
+	if err := json.Unmarshal(doc, &d); err != nil {
+		return nil, err
+	}
+	out := map[string]ed25519.PublicKey{}
+	for _, m := range d.VerificationMethod {
+		k := m.PublicKeyJwk
+		if k.Kty != "OKP" || k.Crv != "Ed25519" {
+			continue
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			continue
+		}
+		out[k.Kid] = ed25519.PublicKey(x)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("offline: no Ed25519 keys in DID document")
+	}
+	return out, nil
+}
+
+// ================== CHECK ==================
+
+type Status string
+
+const (
+	Authentic      Status = "authentic"
+	Revoked        Status = "revoked"
+	SerialMismatch Status = "serial_mismatch"
+	Unknown        Status = "unknown" // немає в пакеті — перевірити онлайн пізніше
+)
+
+type Result struct {
+	Status Status        `json:"status"`
+	Record *Record       `json:"record,omitempty"`
+	Age    time.Duration `json:"age"`
+	Stale  bool          `json:"stale"` // пакет старший за maxAge
+}
+
+// Age — скільки минуло з моменту експорту
+func (b *Bundle) Age(now time.Time) time.Duration {
+	return now.Sub(time.Unix(b.CreatedAt, 0))
+}
+
+// Check звіряє скан (tokenId і, якщо є, надрукований серійник) з пакетом.
+// maxAge <= 0 — без обмеження свіжості.
+func (b *Bundle) Check(tokenID int64, serial string, now time.Time, maxAge time.Duration) Result {
+	res := Result{Status: Unknown, Age: b.Age(now)}
+	res.Stale = maxAge > 0 && res.Age > maxAge
+	i, ok := b.index[tokenID]
+	if !ok {
+		return res
+	}
+	r := b.Records[i]
+	res.Record = &r
+	switch {
+	case r.State == "revoked":
+		res.Status = Revoked
+	case strings.TrimSpace(serial) != "" && SerialHash(b.Salt, serial) != r.SerialHash:
+		res.Status = SerialMismatch
+	default:
+		res.Status = Authentic
+	}
+	return res
+}
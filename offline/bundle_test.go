@@ -0,0 +1,161 @@
+package offline
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func testKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub, priv
+}
+
+func testBundle(kid string) Bundle {
+	salt := []byte("0123456789abcdef")
+	return Bundle{
+		Issuer: "did:web:marki.example", KeyID: kid, Scope: "batch:b1",
+		CreatedAt: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), Salt: salt,
+		Records: []Record{
+			{TokenID: 1, SerialHash: SerialHash(salt, "ABCD-EFGH-JKMN-PQR0"), State: "minted", Brand: "ACME"},
+			{TokenID: 2, SerialHash: SerialHash(salt, "ZZZZ-ZZZZ-ZZZZ-ZZZ1"), State: "revoked", Brand: "ACME"},
+		},
+	}
+}
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	pub, priv := testKey(t)
+	data, err := Seal(testBundle("k1"), priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Open(data, map[string]ed25519.PublicKey{"k1": pub})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Version != Version || b.Scope != "batch:b1" || len(b.Records) != 2 || b.Records[1].State != "revoked" {
+		t.Fatalf("opened bundle = %+v", b)
+	}
+}
+
+func TestOpenRejects(t *testing.T) {
+	pub, priv := testKey(t)
+	otherPub, otherPriv := testKey(t)
+	keys := map[string]ed25519.PublicKey{"k1": pub, "k2": otherPub}
+	data, err := Seal(testBundle("k1"), priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		t.Fatal(err)
+	}
+	reseal := func(e envelope) []byte {
+		b, _ := json.Marshal(e)
+		return b
+	}
+
+	// перевернутий байт у стиснутому payload
+	gz, _ := base64.RawStdEncoding.DecodeString(env.Payload)
+	gz[len(gz)/2] ^= 0x01
+	flipped := env
+	flipped.Payload = base64.RawStdEncoding.EncodeToString(gz)
+	if _, err := Open(reseal(flipped), keys); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("flipped payload byte: %v", err)
+	}
+
+	// підпис k1, але в конверті заявлено k2
+	swapped := env
+	swapped.KeyID = "k2"
+	if _, err := Open(reseal(swapped), keys); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("envelope kid swapped: %v", err)
+	}
+
+	// конверт чесно підписаний k2, але всередині пакет заявляє k1
+	inner, _ := json.Marshal(testBundle("k1"))
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write(inner)
+	_ = zw.Close()
+	mismatched := envelope{
+		Version: Version, KeyID: "k2",
+		Payload: base64.RawStdEncoding.EncodeToString(buf.Bytes()),
+		Sig:     base64.RawStdEncoding.EncodeToString(ed25519.Sign(otherPriv, signedBytes("k2", buf.Bytes()))),
+	}
+	if _, err := Open(reseal(mismatched), keys); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("inner kid mismatch: %v", err)
+	}
+
+	if _, err := Open(data, map[string]ed25519.PublicKey{"k2": otherPub}); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("unknown key: %v", err)
+	}
+	if _, err := Open(data, map[string]ed25519.PublicKey{"k1": otherPub}); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("wrong key under the same kid: %v", err)
+	}
+}
+
+func TestKeysFromDID(t *testing.T) {
+	pub, _ := testKey(t)
+	x := base64.RawURLEncoding.EncodeToString(pub)
+	doc := fmt.Sprintf(`{"id":"did:web:marki.example","verificationMethod":[
+		{"publicKeyJwk":{"kty":"EC","crv":"P-256","kid":"ec","x":"AA"}},
+		{"publicKeyJwk":{"kty":"OKP","crv":"Ed25519","kid":"short","x":"AAAA"}},
+		{"publicKeyJwk":{"kty":"OKP","crv":"Ed25519","kid":"k1","x":%q}}]}`, x)
+	keys, err := KeysFromDID([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || !keys["k1"].Equal(pub) {
+		t.Fatalf("keys = %v", keys)
+	}
+	if _, err := KeysFromDID([]byte(`{"verificationMethod":[]}`)); err == nil {
+		t.Fatal("document without Ed25519 keys accepted")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	pub, priv := testKey(t)
+	data, _ := Seal(testBundle("k1"), priv)
+	b, err := Open(data, map[string]ed25519.PublicKey{"k1": pub})
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Unix(b.CreatedAt, 0)
+	fresh, old := created.Add(time.Hour), created.Add(48*time.Hour)
+	tests := []struct {
+		name   string
+		token  int64
+		serial string
+		now    time.Time
+		want   Status
+		stale  bool
+	}{
+		{"authentic", 1, "ABCD-EFGH-JKMN-PQR0", fresh, Authentic, false},
+		{"authentic, lower case with spaces", 1, " abcd-efgh-jkmn-pqr0 ", fresh, Authentic, false},
+		{"authentic without serial", 1, "", fresh, Authentic, false},
+		{"revoked", 2, "ZZZZ-ZZZZ-ZZZZ-ZZZ1", fresh, Revoked, false},
+		{"serial mismatch", 1, "ABCD-EFGH-JKMN-PQR1", fresh, SerialMismatch, false},
+		{"unknown token", 99, "", fresh, Unknown, false},
+		{"stale", 1, "ABCD-EFGH-JKMN-PQR0", old, Authentic, true},
+	}
+	for _, tt := range tests {
+		res := b.Check(tt.token, tt.serial, tt.now, 24*time.Hour)
+		if res.Status != tt.want || res.Stale != tt.stale {
+			t.Errorf("%s: status %s stale %v", tt.name, res.Status, res.Stale)
+		}
+	}
+	if res := b.Check(1, "", old, 0); res.Stale {
+		t.Error("maxAge 0 must disable staleness")
+	}
+}
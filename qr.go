@@ -0,0 +1,498 @@
+// qr.go — серверний QR-енкодер (byte mode, версії 1–40, рівні L/M/Q/H)
+// з рендерингом у PNG і SVG. Алгоритм за ISO/IEC 18004.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+)
+
+type QRLevel int
+
+const (
+	QRLow QRLevel = iota
+	QRMedium
+	QRQuartile
+	QRHigh
+)
+
+// індекс 0 не використовується; рядки — L, M, Q, H
+var qrECCPerBlock = [4][41]int{
+	{-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{-1, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+var qrECCBlocks = [4][41]int{
+	{-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{-1, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{-1, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// біти рівня у форматній інформації
+var qrFormatBits = [4]int{1, 0, 3, 2}
+
+func parseQRLevel(s string) (QRLevel, bool) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "L":
+		return QRLow, true
+	case "", "M":
+		return QRMedium, true
+	case "Q":
+		return QRQuartile, true
+	case "H":
+		return QRHigh, true
+	}
+	return 0, false
+}
+
+// QRCode — матриця модулів; true = темний
+type QRCode struct {
+	Size    int
+	modules [][]bool
+	isFunc  [][]bool
+}
+
+func (q *QRCode) Dark(x, y int) bool {
+	return x >= 0 && y >= 0 && x < q.Size && y < q.Size && q.modules[y][x]
+}
+
+func qrRawDataModules(ver int) int {
+	res := (16*ver+128)*ver + 64
+	if ver >= 2 {
+		n := ver/7 + 2
+		res -= (25*n-10)*n - 55
+		if ver >= 7 {
+			res -= 36
+		}
+	}
+	return res
+}
+
+func qrDataCodewords(ver int, lvl QRLevel) int {
+	return qrRawDataModules(ver)/8 - qrECCPerBlock[lvl][ver]*qrECCBlocks[lvl][ver]
+}
+
+// encodeQR кодує байти в QR мінімальної версії для заданого рівня корекції
+func encodeQR(data []byte, lvl QRLevel) (*QRCode, error) {
+	ver := 0
+	for v := 1; v <= 40; v++ {
+		ccBits := 8
+		if v >= 10 {
+			ccBits = 16
+		}
+		if 4+ccBits+len(data)*8 <= qrDataCodewords(v, lvl)*8 {
+			ver = v
+			break
+		}
+	}
+	if ver == 0 {
+		return nil, fmt.Errorf("qr: data too long (%d bytes)", len(data))
+	}
+
+	// бітовий потік: режим byte (0100), довжина, дані, термінатор, доповнення
+	var bits []bool
+	put := func(v, n int) {
+		for i := n - 1; i >= 0; i-- {
+			bits = append(bits, (v>>i)&1 != 0)
+		}
+	}
+	put(0x4, 4)
+	if ver >= 10 {
+		put(len(data), 16)
+	} else {
+		put(len(data), 8)
+	}
+	for _, b := range data {
+		put(int(b), 8)
+	}
+	capBits := qrDataCodewords(ver, lvl) * 8
+	put(0, min(4, capBits-len(bits)))
+	put(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capBits; pad ^= 0xEC ^ 0x11 {
+		put(pad, 8)
+	}
+	cw := make([]byte, len(bits)/8)
+	for i, b := range bits {
+		if b {
+			cw[i>>3] |= 1 << (7 - uint(i&7))
+		}
+	}
+
+	q := &QRCode{Size: ver*4 + 17}
+	q.modules = make([][]bool, q.Size)
+	q.isFunc = make([][]bool, q.Size)
+	for i := range q.modules {
+		q.modules[i] = make([]bool, q.Size)
+		q.isFunc[i] = make([]bool, q.Size)
+	}
+	q.drawFunctionPatterns(ver, lvl)
+	q.drawCodewords(qrAddECC(cw, ver, lvl))
+
+	best, bestScore := 0, -1
+	for m := 0; m < 8; m++ {
+		q.applyMask(m)
+		q.drawFormat(lvl, m)
+		if s := q.penalty(); bestScore < 0 || s < bestScore {
+			best, bestScore = m, s
+		}
+		q.applyMask(m) // XOR — повторне накладання знімає маску
+	}
+	q.applyMask(best)
+	q.drawFormat(lvl, best)
+	return q, nil
+}
+
+func (q *QRCode) set(x, y int, dark bool) {
+	q.modules[y][x] = dark
+	q.isFunc[y][x] = true
+}
+
+func (q *QRCode) drawFunctionPatterns(ver int, lvl QRLevel) {
+	for i := 0; i < q.Size; i++ {
+		q.set(6, i, i%2 == 0)
+		q.set(i, 6, i%2 == 0)
+	}
+	q.drawFinder(3, 3)
+	q.drawFinder(q.Size-4, 3)
+	q.drawFinder(3, q.Size-4)
+
+	pos := qrAlignmentPositions(ver, q.Size)
+	n := len(pos)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if (i == 0 && j == 0) || (i == 0 && j == n-1) || (i == n-1 && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.set(pos[i]+dx, pos[j]+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+	q.drawFormat(lvl, 0) // резервуємо місце; справжні біти після вибору маски
+	if ver >= 7 {
+		rem := ver
+		for i := 0; i < 12; i++ {
+			rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+		}
+		b := ver<<12 | rem
+		for i := 0; i < 18; i++ {
+			bit := (b>>i)&1 != 0
+			a, c := q.Size-11+i%3, i/3
+			q.set(a, c, bit)
+			q.set(c, a, bit)
+		}
+	}
+}
+
+func (q *QRCode) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || y < 0 || x >= q.Size || y >= q.Size {
+				continue
+			}
+			d := max(abs(dx), abs(dy))
+			q.set(x, y, d != 2 && d != 4)
+		}
+	}
+}
+
+func qrAlignmentPositions(ver, size int) []int {
+	if ver == 1 {
+		return nil
+	}
+	n := ver/7 + 2
+	step := (ver*8 + n*3 + 5) / (n*4 - 4) * 2
+	res := make([]int, n)
+	res[0] = 6
+	for i, pos := n-1, size-7; i >= 1; i, pos = i-1, pos-step {
+		res[i] = pos
+	}
+	return res
+}
+
+func (q *QRCode) drawFormat(lvl QRLevel, mask int) {
+	data := qrFormatBits[lvl]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	b := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return (b>>i)&1 != 0 }
+
+	for i := 0; i <= 5; i++ {
+		q.set(8, i, bit(i))
+	}
+	q.set(8, 7, bit(6))
+	q.set(8, 8, bit(7))
+	q.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.set(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		q.set(q.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.set(8, q.Size-15+i, bit(i))
+	}
+	q.set(8, q.Size-8, true)
+}
+
+func (q *QRCode) drawCodewords(data []byte) {
+	i := 0
+	for right := q.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < q.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = q.Size - 1 - vert
+				}
+				if !q.isFunc[y][x] && i < len(data)*8 {
+					q.modules[y][x] = (data[i>>3]>>(7-uint(i&7)))&1 != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (q *QRCode) applyMask(m int) {
+	for y := 0; y < q.Size; y++ {
+		for x := 0; x < q.Size; x++ {
+			if q.isFunc[y][x] {
+				continue
+			}
+			var inv bool
+			switch m {
+			case 0:
+				inv = (x+y)%2 == 0
+			case 1:
+				inv = y%2 == 0
+			case 2:
+				inv = x%3 == 0
+			case 3:
+				inv = (x+y)%3 == 0
+			case 4:
+				inv = (x/3+y/2)%2 == 0
+			case 5:
+				inv = x*y%2+x*y%3 == 0
+			case 6:
+				inv = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				inv = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if inv {
+				q.modules[y][x] = !q.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty — правила N1–N4 для вибору маски
+func (q *QRCode) penalty() int {
+	n := q.Size
+	score := 0
+	line := func(get func(i int) bool) {
+		run := 1
+		for i := 1; i <= n; i++ {
+			if i < n && get(i) == get(i-1) {
+				run++
+				continue
+			}
+			if run >= 5 {
+				score += 3 + run - 5
+			}
+			run = 1
+		}
+		// 1:1:3:1:1 з чотирма світлими модулями з будь-якого боку
+		pat := []bool{true, false, true, true, true, false, true}
+		for i := 0; i+7 <= n; i++ {
+			ok := true
+			for k, v := range pat {
+				if get(i+k) != v {
+					ok = false
+					break
+				}
+			}
+			if !ok {
+				continue
+			}
+			light := func(from, to int) bool {
+				for k := from; k < to; k++ {
+					if k >= 0 && k < n && get(k) {
+						return false
+					}
+				}
+				return true
+			}
+			if light(i-4, i) || light(i+7, i+11) {
+				score += 40
+			}
+		}
+	}
+	dark := 0
+	for y := 0; y < n; y++ {
+		yy := y
+		line(func(i int) bool { return q.modules[yy][i] })
+		line(func(i int) bool { return q.modules[i][yy] })
+		for x := 0; x < n; x++ {
+			if q.modules[y][x] {
+				dark++
+			}
+			if x+1 < n && y+1 < n {
+				c := q.modules[y][x]
+				if c == q.modules[y][x+1] && c == q.modules[y+1][x] && c == q.modules[y+1][x+1] {
+					score += 3
+				}
+			}
+		}
+	}
+	total := n * n
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	return score + k*10
+}
+
+// ================== REED–SOLOMON ==================
+
+func gfMul(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>uint(i))&1) * int(x)
+	}
+	return byte(z)
+}
+
+func rsDivisor(degree int) []byte {
+	res := make([]byte, degree)
+	res[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range res {
+			res[j] = gfMul(res[j], root)
+			if j+1 < len(res) {
+				res[j] ^= res[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return res
+}
+
+func rsRemainder(data, div []byte) []byte {
+	res := make([]byte, len(div))
+	for _, b := range data {
+		f := b ^ res[0]
+		copy(res, res[1:])
+		res[len(res)-1] = 0
+		for i := range res {
+			res[i] ^= gfMul(div[i], f)
+		}
+	}
+	return res
+}
+
+// qrAddECC ділить дані на блоки, додає ECC і перемежовує
+func qrAddECC(data []byte, ver int, lvl QRLevel) []byte {
+	nBlocks := qrECCBlocks[lvl][ver]
+	eccLen := qrECCPerBlock[lvl][ver]
+	raw := qrRawDataModules(ver) / 8
+	nShort := nBlocks - raw%nBlocks
+	shortLen := raw / nBlocks
+
+	div := rsDivisor(eccLen)
+	blocks := make([][]byte, nBlocks)
+	k := 0
+	for i := 0; i < nBlocks; i++ {
+		n := shortLen - eccLen
+		if i >= nShort {
+			n++
+		}
+		dat := append([]byte{}, data[k:k+n]...)
+		k += n
+		ecc := rsRemainder(dat, div)
+		if i < nShort {
+			dat = append(dat, 0)
+		}
+		blocks[i] = append(dat, ecc...)
+	}
+	out := make([]byte, 0, raw)
+	for i := range blocks[0] {
+		for j, b := range blocks {
+			if i != shortLen-eccLen || j >= nShort {
+				out = append(out, b[i])
+			}
+		}
+	}
+	return out
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// ================== RENDER ==================
+
+const qrQuietZone = 4
+
+// qrPNG — scale пікселів на модуль, тиха зона 4 модулі
+func qrPNG(q *QRCode, scale int) ([]byte, error) {
+	if scale < 1 {
+		scale = 1
+	}
+	dim := (q.Size + 2*qrQuietZone) * scale
+	img := image.NewPaletted(image.Rect(0, 0, dim, dim), color.Palette{color.White, color.Black})
+	for y := 0; y < q.Size; y++ {
+		for x := 0; x < q.Size; x++ {
+			if !q.Dark(x, y) {
+				continue
+			}
+			x0, y0 := (x+qrQuietZone)*scale, (y+qrQuietZone)*scale
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex(x0+dx, y0+dy, 1)
+				}
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// qrSVG — масштабований вектор; size — бажана ширина у пікселях
+func qrSVG(q *QRCode, size int) []byte {
+	dim := q.Size + 2*qrQuietZone
+	var path strings.Builder
+	for y := 0; y < q.Size; y++ {
+		for x := 0; x < q.Size; x++ {
+			if q.Dark(x, y) {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x+qrQuietZone, y+qrQuietZone)
+			}
+		}
+	}
+	return []byte(fmt.Sprintf(
+		`<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+			`<svg xmlns="http://www.w3.org/2000/svg" version="1.1" viewBox="0 0 %d %d" width="%d" height="%d" shape-rendering="crispEdges">`+
+			`<rect width="100%%" height="100%%" fill="#fff"/><path d="%s" fill="#000"/></svg>`+"\n",
+		dim, dim, size, size, path.String()))
+}
@@ -0,0 +1,480 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// Незалежний від енкодера декодер за ISO/IEC 18004: власні таблиці блоків,
+// позицій вирівнювання і форматних рядків, власна GF(256) для синдромів.
+
+// форматні рядки (рівень, маска) з таблиці C.1 ISO/IEC 18004, старший біт першим
+var isoFormatStrings = map[string][2]int{}
+
+func init() {
+	table := map[QRLevel][8]string{
+		QRLow:      {"111011111000100", "111001011110011", "111110110101010", "111100010011101", "110011000101111", "110001100011000", "110110001000001", "110100101110110"},
+		QRMedium:   {"101010000010010", "101000100100101", "101111001111100", "101101101001011", "100010111111001", "100000011001110", "100111110010111", "100101010100000"},
+		QRQuartile: {"011010101011111", "011000001101000", "011111100110001", "011101000000110", "010010010110100", "010000110000011", "010111011011010", "010101111101101"},
+		QRHigh:     {"001011010001001", "001001110111110", "001110011100111", "001100111010000", "000011101100010", "000001001010101", "000110100001100", "000100000111011"},
+	}
+	for lvl, row := range table {
+		for mask, s := range row {
+			isoFormatStrings[s] = [2]int{int(lvl), mask}
+		}
+	}
+}
+
+// версійна інформація (таблиця D.1)
+var isoVersionStrings = map[int]string{
+	7:  "000111110010010100",
+	10: "001010010011010011",
+}
+
+// центри вирівнювання (таблиця E.1)
+var isoAlignment = map[int][]int{1: nil, 2: {6, 18}, 5: {6, 30}, 7: {6, 22, 38}, 10: {6, 28, 50}}
+
+// структура блоків (таблиця 9): ECC на блок і групи {кількість, даних у блоці}
+type isoBlockSpec struct {
+	ecc    int
+	groups [][2]int
+}
+
+var isoBlocks = map[[2]int]isoBlockSpec{
+	{1, int(QRLow)}:      {7, [][2]int{{1, 19}}},
+	{1, int(QRMedium)}:   {10, [][2]int{{1, 16}}},
+	{1, int(QRQuartile)}: {13, [][2]int{{1, 13}}},
+	{1, int(QRHigh)}:     {17, [][2]int{{1, 9}}},
+	{2, int(QRMedium)}:   {16, [][2]int{{1, 28}}},
+	{5, int(QRQuartile)}: {18, [][2]int{{2, 15}, {2, 16}}},
+	{5, int(QRHigh)}:     {22, [][2]int{{2, 11}, {2, 12}}},
+	{7, int(QRMedium)}:   {18, [][2]int{{4, 31}}},
+	{10, int(QRLow)}:     {18, [][2]int{{2, 68}, {2, 69}}},
+	{10, int(QRMedium)}:  {26, [][2]int{{4, 43}, {1, 44}}},
+}
+
+var gfExp, gfLog = func() ([512]byte, [256]byte) {
+	var exp [512]byte
+	var log [256]byte
+	x := 1
+	for i := 0; i < 255; i++ {
+		exp[i], exp[i+255] = byte(x), byte(x)
+		log[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11D
+		}
+	}
+	return exp, log
+}()
+
+// rsSyndromesZero — кодове слово ділиться на генератор з коренями α^0..α^(ecc-1)
+func rsSyndromesZero(cw []byte, ecc int) bool {
+	for j := 0; j < ecc; j++ {
+		var s byte
+		for _, c := range cw {
+			// схема Горнера: s = s·α^j + c
+			if s != 0 {
+				s = gfExp[(int(gfLog[s])+j)%255]
+			}
+			s ^= c
+		}
+		if s != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func isoMask(mask, row, col int) bool {
+	switch mask {
+	case 0:
+		return (row+col)%2 == 0
+	case 1:
+		return row%2 == 0
+	case 2:
+		return col%3 == 0
+	case 3:
+		return (row+col)%3 == 0
+	case 4:
+		return (row/2+col/3)%2 == 0
+	case 5:
+		return row*col%2+row*col%3 == 0
+	case 6:
+		return (row*col%2+row*col%3)%2 == 0
+	default:
+		return ((row+col)%2+row*col%3)%2 == 0
+	}
+}
+
+type decodedQR struct {
+	ver, mask int
+	lvl       QRLevel
+	data      []byte
+}
+
+func decodeQRMatrix(q *QRCode) (decodedQR, error) {
+	var res decodedQR
+	n := q.Size
+	if (n-17)%4 != 0 || n < 21 || n > 177 {
+		return res, fmt.Errorf("bad size %d", n)
+	}
+	res.ver = (n - 17) / 4
+	bit := func(x, y int) byte {
+		if q.Dark(x, y) {
+			return '1'
+		}
+		return '0'
+	}
+
+	// шукачі з роздільниками: 7×7 рамка-квадрат-центр, навколо світла смуга
+	for _, c := range [][2]int{{0, 0}, {n - 7, 0}, {0, n - 7}} {
+		for dy := -1; dy <= 7; dy++ {
+			for dx := -1; dx <= 7; dx++ {
+				x, y := c[0]+dx, c[1]+dy
+				if x < 0 || y < 0 || x >= n || y >= n {
+					continue
+				}
+				d := max(abs(dx-3), abs(dy-3))
+				if q.Dark(x, y) != (d != 2 && d != 4) {
+					return res, fmt.Errorf("finder at %v broken at (%d,%d)", c, x, y)
+				}
+			}
+		}
+	}
+	for i := 8; i < n-8; i++ {
+		if q.Dark(i, 6) != (i%2 == 0) || q.Dark(6, i) != (i%2 == 0) {
+			return res, fmt.Errorf("timing pattern broken at %d", i)
+		}
+	}
+	if !q.Dark(8, n-8) {
+		return res, fmt.Errorf("dark module missing")
+	}
+
+	// дві копії форматної інформації мають збігатися
+	var f1, f2 []byte
+	for x := 0; x <= 5; x++ {
+		f1 = append(f1, bit(x, 8))
+	}
+	f1 = append(f1, bit(7, 8), bit(8, 8), bit(8, 7))
+	for y := 5; y >= 0; y-- {
+		f1 = append(f1, bit(8, y))
+	}
+	for y := n - 1; y >= n-7; y-- {
+		f2 = append(f2, bit(8, y))
+	}
+	for x := n - 8; x < n; x++ {
+		f2 = append(f2, bit(x, 8))
+	}
+	if string(f1) != string(f2) {
+		return res, fmt.Errorf("format copies differ: %s vs %s", f1, f2)
+	}
+	lm, ok := isoFormatStrings[string(f1)]
+	if !ok {
+		return res, fmt.Errorf("unknown format string %s", f1)
+	}
+	res.lvl, res.mask = QRLevel(lm[0]), lm[1]
+
+	fn := make([][]bool, n)
+	for y := range fn {
+		fn[y] = make([]bool, n)
+	}
+	mark := func(x0, y0, w, h int) {
+		for y := y0; y < y0+h; y++ {
+			for x := x0; x < x0+w; x++ {
+				fn[y][x] = true
+			}
+		}
+	}
+	mark(0, 0, 9, 9)
+	mark(n-8, 0, 8, 9)
+	mark(0, n-8, 9, 8)
+	mark(6, 0, 1, n)
+	mark(0, 6, n, 1)
+
+	if res.ver >= 7 {
+		want, ok := isoVersionStrings[res.ver]
+		if !ok {
+			return res, fmt.Errorf("no version table entry for %d", res.ver)
+		}
+		var tr, bl []byte
+		for i := 17; i >= 0; i-- {
+			tr = append(tr, bit(n-11+i%3, i/3))
+			bl = append(bl, bit(i/3, n-11+i%3))
+		}
+		if string(tr) != want || string(bl) != want {
+			return res, fmt.Errorf("version info %s / %s, want %s", tr, bl, want)
+		}
+		mark(n-11, 0, 3, 6)
+		mark(0, n-11, 6, 3)
+	}
+
+	pos, ok := isoAlignment[res.ver]
+	if !ok {
+		return res, fmt.Errorf("no alignment table entry for %d", res.ver)
+	}
+	for _, cy := range pos {
+		for _, cx := range pos {
+			if (cx == 6 && cy == 6) || (cx == 6 && cy == n-7) || (cx == n-7 && cy == 6) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					if q.Dark(cx+dx, cy+dy) != (max(abs(dx), abs(dy)) != 1) {
+						return res, fmt.Errorf("alignment at (%d,%d) broken", cx, cy)
+					}
+				}
+			}
+			mark(cx-2, cy-2, 5, 5)
+		}
+	}
+
+	// зигзаг знизу справа, парами стовпців, оминаючи вертикальну синхронізацію
+	var bits []bool
+	up := true
+	for right := n - 1; right > 0; right -= 2 {
+		if right == 6 {
+			right--
+		}
+		for k := 0; k < n; k++ {
+			y := k
+			if up {
+				y = n - 1 - k
+			}
+			for _, x := range []int{right, right - 1} {
+				if !fn[y][x] {
+					bits = append(bits, q.Dark(x, y) != isoMask(res.mask, y, x))
+				}
+			}
+		}
+		up = !up
+	}
+
+	spec, ok := isoBlocks[[2]int{res.ver, int(res.lvl)}]
+	if !ok {
+		return res, fmt.Errorf("no block table entry for %d-%d", res.ver, res.lvl)
+	}
+	var blocks [][]byte
+	total := 0
+	for _, g := range spec.groups {
+		for i := 0; i < g[0]; i++ {
+			blocks = append(blocks, make([]byte, 0, g[1]+spec.ecc))
+			total += g[1] + spec.ecc
+		}
+	}
+	if len(bits)/8 != total {
+		return res, fmt.Errorf("matrix holds %d codewords, table says %d", len(bits)/8, total)
+	}
+	cw := make([]byte, total)
+	for i := 0; i < total*8; i++ {
+		if bits[i] {
+			cw[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	// перемежування: спершу дані по колонках блоків, далі ECC
+	k := 0
+	maxData := spec.groups[len(spec.groups)-1][1]
+	for i := 0; i < maxData; i++ {
+		j := 0
+		for _, g := range spec.groups {
+			for b := 0; b < g[0]; b, j = b+1, j+1 {
+				if i < g[1] {
+					blocks[j] = append(blocks[j], cw[k])
+					k++
+				}
+			}
+		}
+	}
+	for i := 0; i < spec.ecc; i++ {
+		for j := range blocks {
+			blocks[j] = append(blocks[j], cw[k])
+			k++
+		}
+	}
+	var data []byte
+	for j, b := range blocks {
+		if !rsSyndromesZero(b, spec.ecc) {
+			return res, fmt.Errorf("block %d fails Reed-Solomon check", j)
+		}
+		data = append(data, b[:len(b)-spec.ecc]...)
+	}
+
+	// byte mode: 0100, довжина (8 або 16 біт), дані, термінатор, 0xEC/0x11
+	pos2 := 0
+	read := func(w int) int {
+		v := 0
+		for i := 0; i < w; i++ {
+			v = v<<1 | int(data[(pos2+i)/8]>>(7-(pos2+i)%8)&1)
+		}
+		pos2 += w
+		return v
+	}
+	if m := read(4); m != 0x4 {
+		return res, fmt.Errorf("mode %04b, want byte mode", m)
+	}
+	ccBits := 8
+	if res.ver >= 10 {
+		ccBits = 16
+	}
+	cnt := read(ccBits)
+	if 4+ccBits+cnt*8 > len(data)*8 {
+		return res, fmt.Errorf("count %d overflows capacity", cnt)
+	}
+	for i := 0; i < cnt; i++ {
+		res.data = append(res.data, byte(read(8)))
+	}
+	if rest := len(data)*8 - pos2; rest > 0 && read(min(4, rest)) != 0 {
+		return res, fmt.Errorf("terminator missing")
+	}
+	for pos2%8 != 0 {
+		if read(1) != 0 {
+			return res, fmt.Errorf("non-zero bit padding")
+		}
+	}
+	for i, pad := pos2/8, byte(0xEC); i < len(data); i, pad = i+1, pad^0xEC^0x11 {
+		if data[i] != pad {
+			return res, fmt.Errorf("pad codeword %d = %#x, want %#x", i, data[i], pad)
+		}
+	}
+	return res, nil
+}
+
+func qrPayload(n int) []byte {
+	const url = "https://marki.example/01/09506000134352/21/ABCD-EFGH-JKMN-PQR0?code=17-"
+	b := make([]byte, n)
+	for i := range b {
+		if i < len(url) {
+			b[i] = url[i]
+		} else {
+			b[i] = byte(i * 37) // будь-які байти, не лише ASCII
+		}
+	}
+	return b
+}
+
+func TestEncodeQRDecodes(t *testing.T) {
+	tests := []struct {
+		lvl     QRLevel
+		n       int
+		wantVer int
+	}{
+		{QRLow, 0, 1},
+		{QRLow, 17, 1}, // межа ємності 1-L
+		{QRMedium, 14, 1},
+		{QRMedium, 15, 2},
+		{QRQuartile, 11, 1},
+		{QRHigh, 7, 1},
+		{QRMedium, 26, 2},
+		{QRQuartile, 60, 5},
+		{QRHigh, 44, 5},
+		{QRMedium, 122, 7},  // перша версія з версійною інформацією
+		{QRMedium, 213, 10}, // 16-бітна довжина, блоки різної довжини
+		{QRLow, 271, 10},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d-bytes-level-%d", tt.n, tt.lvl), func(t *testing.T) {
+			in := qrPayload(tt.n)
+			q, err := encodeQR(in, tt.lvl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := tt.wantVer*4 + 17; q.Size != want {
+				t.Fatalf("size = %d, want %d (version %d)", q.Size, want, tt.wantVer)
+			}
+			got, err := decodeQRMatrix(q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.lvl != tt.lvl {
+				t.Errorf("level = %d, want %d", got.lvl, tt.lvl)
+			}
+			if !bytes.Equal(got.data, in) {
+				t.Errorf("decoded %q, want %q", got.data, in)
+			}
+		})
+	}
+	// декодер має бачити пошкодження: один інвертований модуль даних
+	q, err := encodeQR(qrPayload(40), QRQuartile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, y := q.Size-1, q.Size-1
+	q.modules[y][x] = !q.modules[y][x]
+	if _, err := decodeQRMatrix(q); err == nil {
+		t.Fatal("flipped data module not detected")
+	}
+	if _, err := encodeQR(make([]byte, 2954), QRLow); err == nil {
+		t.Fatal("2954 bytes exceed 40-L capacity but were accepted")
+	}
+}
+
+// приклад "HELLO WORLD" 1-M (ISO/IEC 18004, додаток I): дані та їхні 10 байтів ECC
+func TestRSRemainderKnown(t *testing.T) {
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := rsRemainder(data, rsDivisor(10)); !bytes.Equal(got, want) {
+		t.Fatalf("ECC = %v, want %v", got, want)
+	}
+	if !rsSyndromesZero(append(data, want...), 10) {
+		t.Fatal("syndrome check disagrees with the known codeword")
+	}
+}
+
+func TestParseQRLevel(t *testing.T) {
+	for in, want := range map[string]QRLevel{"": QRMedium, "l": QRLow, "M": QRMedium, " q ": QRQuartile, "H": QRHigh} {
+		if got, ok := parseQRLevel(in); !ok || got != want {
+			t.Errorf("parseQRLevel(%q) = %d, %v", in, got, ok)
+		}
+	}
+	if _, ok := parseQRLevel("X"); ok {
+		t.Error("parseQRLevel(X) accepted")
+	}
+}
+
+// PNG і SVG відтворюють ту саму матрицю з тихою зоною
+func TestQRRender(t *testing.T) {
+	q, err := encodeQR([]byte("https://marki.example/details.html?id=42"), QRMedium)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const scale = 3
+	b, err := qrPNG(q, scale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dim := (q.Size + 2*qrQuietZone) * scale
+	if img.Bounds().Dx() != dim || img.Bounds().Dy() != dim {
+		t.Fatalf("png is %v, want %dx%d", img.Bounds(), dim, dim)
+	}
+	dark := 0
+	for y := -qrQuietZone; y < q.Size+qrQuietZone; y++ {
+		for x := -qrQuietZone; x < q.Size+qrQuietZone; x++ {
+			r, _, _, _ := img.At((x+qrQuietZone)*scale+1, (y+qrQuietZone)*scale+1).RGBA()
+			if (r == 0) != q.Dark(x, y) {
+				t.Fatalf("png module (%d,%d) does not match", x, y)
+			}
+			if q.Dark(x, y) {
+				dark++
+			}
+		}
+	}
+
+	svg := string(qrSVG(q, 256))
+	if got := strings.Count(svg, "h1v1h-1z"); got != dark {
+		t.Fatalf("svg has %d modules, want %d", got, dark)
+	}
+	side := q.Size + 2*qrQuietZone
+	if !strings.Contains(svg, fmt.Sprintf(`viewBox="0 0 %d %d" width="256"`, side, side)) {
+		t.Fatalf("svg viewBox/size missing: %.200s", svg)
+	}
+	if !strings.Contains(svg, fmt.Sprintf("M%d %dh1v1h-1z", qrQuietZone, qrQuietZone)) {
+		t.Fatal("top-left finder corner missing in svg")
+	}
+}
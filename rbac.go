@@ -0,0 +1,171 @@
+// rbac.go — ролі та матриця дозволів. Ролі персоналу зберігаються в
+// admins/{userId}.role (старі записи без role — super-admin), ролі бренду —
+// з членства в команді (members.go), ownerId або API-ключа. Усі перевірки
+// приймають ID користувача (users.go).
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+type Role string
+
+const (
+	RoleSuperAdmin    Role = "super-admin"
+	RoleModerator     Role = "moderator"
+	RoleSupport       Role = "support"
+	RoleBrandOwner    Role = "brand-owner"
+	RoleBrandManager  Role = "brand-manager"
+	RoleBrandOperator Role = "brand-operator"
+	RoleBrandViewer   Role = "brand-viewer"
+	RoleConsumer      Role = "consumer"
+)
+
+// ролі, які видаються через /api/admins/grant
+var staffRoles = []Role{RoleSuperAdmin, RoleModerator, RoleSupport}
+
+type Perm string
+
+const (
+	PermAdminsManage       Perm = "admins.manage"
+	PermBrandsVerify       Perm = "brands.verify"
+	PermBrandsCreate       Perm = "brands.create"
+	PermApplicationsRead   Perm = "applications.read"
+	PermApplicationsReview Perm = "applications.review"
+	PermProductsReadAny    Perm = "products.read_any"
+	PermLedgerRead         Perm = "ledger.read"
+	PermSystemManage       Perm = "system.manage" // ключі серійників, ланцюг, чекпоінти
+	PermAuditRead          Perm = "audit.read"
+
+	// у межах бренду (персонал з цими дозволами — для всіх брендів)
+	PermBrandRead     Perm = "brand.read"
+	PermBrandMembers  Perm = "brand.members"
+	PermBrandProducts Perm = "brand.products" // мінт, QR, NFC, dynamic QR
+	PermBrandRevoke   Perm = "brand.revoke"
+	PermBrandBatches  Perm = "brand.batches"
+	PermBrandKeys     Perm = "brand.keys"
+	PermBrandProfile  Perm = "brand.profile" // публічний профіль бренду
+	PermBrandOwners   Perm = "brand.owners"  // видавати і змінювати роль owner у команді
+)
+
+var rolePerms = map[Role][]Perm{
+	RoleSuperAdmin: {
+		PermAdminsManage, PermBrandsVerify, PermBrandsCreate,
+		PermApplicationsRead, PermApplicationsReview,
+		PermProductsReadAny, PermLedgerRead, PermSystemManage, PermAuditRead,
+		PermBrandRead, PermBrandMembers, PermBrandProducts, PermBrandRevoke, PermBrandBatches, PermBrandKeys, PermBrandProfile,
+		PermBrandOwners,
+	},
+	RoleModerator: {PermApplicationsRead, PermApplicationsReview, PermBrandsCreate},
+	RoleSupport:   {PermApplicationsRead, PermProductsReadAny, PermLedgerRead, PermBrandRead},
+
+	RoleBrandOwner:    {PermBrandRead, PermBrandMembers, PermBrandProducts, PermBrandRevoke, PermBrandBatches, PermBrandKeys, PermBrandProfile, PermBrandOwners},
+	RoleBrandManager:  {PermBrandRead, PermBrandMembers, PermBrandProducts, PermBrandRevoke, PermBrandBatches},
+	RoleBrandOperator: {PermBrandRead, PermBrandProducts, PermBrandBatches},
+	RoleBrandViewer:   {PermBrandRead},
+	RoleConsumer:      {},
+}
+
+func roleHas(role Role, p Perm) bool {
+	return slices.Contains(rolePerms[role], p)
+}
+
+func validStaffRole(s string) (Role, bool) {
+	r := Role(strings.ToLower(strings.TrimSpace(s)))
+	return r, slices.Contains(staffRoles, r)
+}
+
+// staffRole — роль персоналу з admins/{userId}; "" — не персонал
+func staffRole(ctx context.Context, uid string) Role {
+	if uid == "" || !fsEnabled {
+		return ""
+	}
+	return cachedStaffRole(ctx, uid, func() (Role, error) {
+		s, err := adminDoc(uid).Get(ctx)
+		if err != nil {
+			if strings.Contains(strings.ToLower(err.Error()), "not found") {
+				return "", nil
+			}
+			return "", err
+		}
+		if r, ok := validStaffRole(asString(s.Data()["role"])); ok {
+			return r, nil
+		}
+		return RoleSuperAdmin, nil
+	})
+}
+
+func asString(v any) string {
+	s, _ := v.(string)
+	return s
+}
+
+// can — глобальний дозвіл; API-ключі ролей персоналу не мають
+func can(ctx context.Context, uid string, p Perm) bool {
+	if uid == "" || apiKeyFromContext(ctx) != nil {
+		return false
+	}
+	return roleHas(staffRole(ctx, uid), p)
+}
+
+// brandRole — роль користувача в бренді
+func brandRole(ctx context.Context, slug, uid string) Role {
+	if k := apiKeyFromContext(ctx); k != nil {
+		if k.BrandSlug == slug {
+			return RoleBrandOperator
+		}
+		return ""
+	}
+	if m, ok, err := fsGetMember(ctx, slug, uid); err == nil && ok {
+		return memberRoles[m.Role]
+	}
+	if isBrandOwner(ctx, slug, uid) {
+		return RoleBrandOwner
+	}
+	return ""
+}
+
+// canBrand — дозвіл у межах бренду: персонал із цим дозволом або роль у бренді
+func canBrand(ctx context.Context, uid, slug string, p Perm) bool {
+	if uid == "" {
+		return false
+	}
+	return can(ctx, uid, p) || (slug != "" && roleHas(brandRole(ctx, slug, uid), p))
+}
+
+// permsFor — для /api/me: глобальні дозволи користувача
+func permsFor(ctx context.Context, uid string) (Role, []Perm) {
+	role := staffRole(ctx, uid)
+	if role == "" {
+		role = RoleConsumer
+	}
+	return role, append([]Perm{}, rolePerms[role]...)
+}
+
+// requirePerm — спільний middleware для маршрутів персоналу
+func requirePerm(p Perm, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			returnOK(w)
+			return
+		}
+		u := currentUser(r)
+		if u == "" {
+			writeJSON(w, 401, ErrorResp{"missing user"})
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), 4*time.Second)
+		ok := can(ctx, u, p)
+		cancel()
+		if !ok {
+			writeJSON(w, 403, ErrorResp{"forbidden"})
+			return
+		}
+		h(w, r)
+	}
+}
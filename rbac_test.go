@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+// роль owner у команді видає лише власник (або super-admin), не менеджер
+func TestBrandOwnersPerm(t *testing.T) {
+	tests := []struct {
+		role Role
+		want bool
+	}{
+		{RoleSuperAdmin, true},
+		{RoleBrandOwner, true},
+		{RoleBrandManager, false},
+		{RoleBrandOperator, false},
+		{RoleBrandViewer, false},
+		{RoleModerator, false},
+	}
+	for _, tt := range tests {
+		if got := roleHas(tt.role, PermBrandOwners); got != tt.want {
+			t.Errorf("roleHas(%s, brand.owners) = %v, want %v", tt.role, got, tt.want)
+		}
+	}
+	if !roleHas(RoleBrandManager, PermBrandMembers) {
+		t.Error("manager must still manage non-owner members")
+	}
+}
@@ -0,0 +1,275 @@
+// serial.go — серійні номери з CSPRNG + контрольний символ (Luhn mod 32)
+// та HMAC-хеші серійників із серверним ключем і ротацією.
+
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+// Crockford base32: без I, L, O, U — щоб не плутати при ручному введенні
+const serialAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+const (
+	serialGroups   = 4
+	serialGroupLen = 4
+)
+
+type serialKey struct {
+	ID  string
+	Key []byte
+}
+
+// SERIAL_HMAC_KEYS="k2:<base64>,k1:<base64>" — перший ключ поточний,
+// решта лишаються для перевірки хешів, зроблених до ротації.
+var serialKeys = func() []serialKey {
+	var out []serialKey
+	for _, part := range strings.Split(os.Getenv("SERIAL_HMAC_KEYS"), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, b64, ok := strings.Cut(part, ":")
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(b64))
+		if !ok || strings.TrimSpace(id) == "" || err != nil || len(key) < 16 {
+			log.Printf("[serial] skip malformed key entry %q\n", strings.TrimSpace(id))
+			continue
+		}
+		out = append(out, serialKey{ID: strings.TrimSpace(id), Key: key})
+	}
+	if len(out) == 0 {
+		log.Println("[serial] SERIAL_HMAC_KEYS not set — serial hashes are UNKEYED sha256")
+	}
+	return out
+}()
+
+func currentSerialKeyID() string {
+	if len(serialKeys) == 0 {
+		return ""
+	}
+	return serialKeys[0].ID
+}
+
+// serialHashWith: keyID == "" — старий нехешований варіант sha256(serial)
+func serialHashWith(keyID, serial string) (string, bool) {
+	if keyID == "" {
+		return sha256Hex(serial), true
+	}
+	for _, k := range serialKeys {
+		if k.ID == keyID {
+			m := hmac.New(sha256.New, k.Key)
+			m.Write([]byte(serial))
+			return hex.EncodeToString(m.Sum(nil)), true
+		}
+	}
+	return "", false
+}
+
+// serialHash — хеш поточним ключем і його ідентифікатор
+func serialHash(serial string) (string, string) {
+	id := currentSerialKeyID()
+	h, _ := serialHashWith(id, serial)
+	return h, id
+}
+
+// serialHashMatches — чи відповідає серійник збереженому хешу (з урахуванням
+// ключа). Новий формат хешується як XXXX-XXXX-XXXX-XXXX, тож введення без
+// дефісів чи малими літерами зводиться до нього; старі серійники — як є.
+func serialHashMatches(serial, keyID, stored string) bool {
+	for _, v := range []string{canonicalSerial(serial), normalizeSerial(serial)} {
+		if h, ok := serialHashWith(keyID, v); ok && hmac.Equal([]byte(h), []byte(stored)) {
+			return true
+		}
+	}
+	return false
+}
+
+// serialCommitment — sha256(salt‖serial) з випадковою сіллю; саме він іде в
+// CID-блок продукту. На відміну від HMAC не залежить від ключа, тож ротація
+// не змінює вже опублікованих CID, а сіль лишається лише у Firestore —
+// перебрати серійник за публічним блоком не вийде.
+func serialCommitment(serial string) (string, string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	salt := hex.EncodeToString(b)
+	return sha256Hex(salt + serial), salt, nil
+}
+
+// cidSerial — значення серійника в CID-блоці. Продукти до комітментів
+// зберігають serialHash, яким їх замінтили (fsRehashSerials заморожує його
+// в serialCommit перед першою ротацією).
+func cidSerial(p Product) string {
+	if p.SerialCommit != "" {
+		return p.SerialCommit
+	}
+	return p.SerialHash
+}
+
+// ================== GENERATION ==================
+
+func luhn32(payload string) (byte, bool) {
+	const n = len(serialAlphabet)
+	factor, sum := 2, 0
+	for i := len(payload) - 1; i >= 0; i-- {
+		cp := strings.IndexByte(serialAlphabet, payload[i])
+		if cp < 0 {
+			return 0, false
+		}
+		add := factor * cp
+		factor = 3 - factor
+		sum += add/n + add%n
+	}
+	return serialAlphabet[(n-sum%n)%n], true
+}
+
+func normalizeSerial(s string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(s), " ", ""))
+}
+
+// canonicalSerial — серійник нового формату в групах через дефіс; решта —
+// лише нормалізовані
+func canonicalSerial(s string) string {
+	n := normalizeSerial(s)
+	if !validSerial(n) {
+		return n
+	}
+	raw := strings.ReplaceAll(n, "-", "")
+	groups := make([]string, 0, serialGroups)
+	for i := 0; i < len(raw); i += serialGroupLen {
+		groups = append(groups, raw[i:i+serialGroupLen])
+	}
+	return strings.Join(groups, "-")
+}
+
+// validSerial перевіряє формат і контрольний символ
+func validSerial(s string) bool {
+	raw := strings.ReplaceAll(normalizeSerial(s), "-", "")
+	if len(raw) != serialGroups*serialGroupLen {
+		return false
+	}
+	c, ok := luhn32(raw[:len(raw)-1])
+	return ok && c == raw[len(raw)-1]
+}
+
+func randomSerial() (string, error) {
+	b := make([]byte, serialGroups*serialGroupLen-1)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	raw := make([]byte, 0, len(b)+1)
+	for _, x := range b {
+		raw = append(raw, serialAlphabet[x&31]) // 256 кратне 32 — без зміщення
+	}
+	c, _ := luhn32(string(raw))
+	raw = append(raw, c)
+
+	groups := make([]string, 0, serialGroups)
+	for i := 0; i < len(raw); i += serialGroupLen {
+		groups = append(groups, string(raw[i:i+serialGroupLen]))
+	}
+	return strings.Join(groups, "-"), nil
+}
+
+// genSerial — випадковий серійник, перевірений на колізію з наявними продуктами
+func genSerial(ctx context.Context) (string, error) {
+	for attempt := 0; attempt < 5; attempt++ {
+		s, err := randomSerial()
+		if err != nil {
+			return "", err
+		}
+		if !fsEnabled {
+			return s, nil
+		}
+		it := fsCol("products").Where("serial", "==", s).Limit(1).Documents(ctx)
+		_, err = it.Next()
+		it.Stop()
+		if err == iterator.Done {
+			return s, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("could not allocate a unique serial")
+}
+
+// ================== ROTATION ==================
+
+// fsRehashSerials переписує serialHash для всіх продуктів, захешованих не
+// поточним ключем. CID (ipfsHash) не змінюється: для старих продуктів, у чиєму
+// блоці ще лежить HMAC, він заморожується в serialCommit.
+func fsRehashSerials(ctx context.Context) (int, error) {
+	cur := currentSerialKeyID()
+	if cur == "" {
+		return 0, fmt.Errorf("no serial HMAC key configured")
+	}
+	it := fsCol("products").Documents(ctx)
+	defer it.Stop()
+	n := 0
+	for {
+		d, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+		var fp FSProduct
+		if err := d.DataTo(&fp); err != nil {
+			return n, err
+		}
+		if fp.SerialKeyID == cur || fp.Serial == "" {
+			continue
+		}
+		h, id := serialHash(fp.Serial)
+		upd := []firestore.Update{
+			{Path: "serialHash", Value: h},
+			{Path: "serialKeyId", Value: id},
+		}
+		if fp.SerialCommit == "" {
+			upd = append(upd, firestore.Update{Path: "serialCommit", Value: fp.SerialHash})
+		}
+		_, err = d.Ref.Update(ctx, upd)
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// POST /api/admins/serial-keys/rehash
+func adminRehashSerials(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		returnOK(w)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeJSON(w, 405, ErrorResp{"Method not allowed"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
+	defer cancel()
+	n, err := fsRehashSerials(ctx)
+	if err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+	writeJSON(w, 200, map[string]any{"ok": true, "rehashed": n, "keyId": currentSerialKeyID()})
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestLuhn32(t *testing.T) {
+	tests := []struct {
+		payload string
+		want    byte
+		ok      bool
+	}{
+		{"0", '0', true},
+		{"1", 'Y', true},
+		{"Z", '1', true},
+		{"10", 'Z', true},
+		{"I", 0, false}, // не з алфавіту Crockford
+		{"abc", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := luhn32(tt.payload)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("luhn32(%q) = %q, %v; want %q, %v", tt.payload, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+var serialFormat = regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{4}(-[0-9A-HJKMNP-TV-Z]{4}){3}$`)
+
+func TestRandomSerial(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 200; i++ {
+		s, err := randomSerial()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !serialFormat.MatchString(s) || !validSerial(s) {
+			t.Fatalf("randomSerial() = %q", s)
+		}
+		if seen[s] {
+			t.Fatalf("duplicate serial %q", s)
+		}
+		seen[s] = true
+	}
+}
+
+func TestValidSerial(t *testing.T) {
+	s, err := randomSerial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := strings.ReplaceAll(s, "-", "")
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{s, true},
+		{raw, true},
+		{strings.ToLower(s), true},
+		{" " + s + " ", true},
+		{raw[:15], false},
+		{raw + "0", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := validSerial(tt.in); got != tt.want {
+			t.Errorf("validSerial(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	// будь-яка заміна одного символу ловиться контрольним символом
+	for i := range raw {
+		for _, c := range []byte(serialAlphabet) {
+			if c == raw[i] {
+				continue
+			}
+			bad := raw[:i] + string(c) + raw[i+1:]
+			if validSerial(bad) {
+				t.Fatalf("substitution %q -> %q at %d not detected", raw[i], c, i)
+			}
+		}
+	}
+}
+
+func TestSerialHashMatchesCanonicalForms(t *testing.T) {
+	s, err := randomSerial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := serialHashWith("", s)
+	raw := strings.ReplaceAll(s, "-", "")
+	for _, in := range []string{s, raw, strings.ToLower(raw), strings.ToLower(s), " " + s} {
+		if !serialHashMatches(in, "", stored) {
+			t.Errorf("%q does not match the hash of %q", in, s)
+		}
+	}
+	if canonicalSerial(raw) != s {
+		t.Errorf("canonicalSerial(%q) = %q, want %q", raw, canonicalSerial(raw), s)
+	}
+
+	// старий серійник без контрольного символу звіряється як є
+	legacy, _ := serialHashWith("", "SN-000123")
+	if !serialHashMatches("sn-000123", "", legacy) || serialHashMatches("SN000123", "", legacy) {
+		t.Error("legacy serial comparison changed")
+	}
+	other, _ := randomSerial()
+	if serialHashMatches(other, "", stored) {
+		t.Error("different serial matched")
+	}
+}
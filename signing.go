@@ -0,0 +1,134 @@
+// signing.go — підпис запитів HMAC для фабричних клієнтів: API-ключ з
+// увімкненим підписом на мінті та партіях мусить додати
+//
+//	X-Marki-Timestamp: <unix seconds>
+//	X-Marki-Nonce:     <8..128 символів, унікальний>
+//	X-Marki-Signature: v1=<hex HMAC-SHA256(signingSecret, canonical)>
+//
+// canonical = "v1\n" + METHOD + "\n" + path[?query] + "\n" + timestamp + "\n" +
+// nonce + "\n" + hex(sha256(body)).
+//
+// Секрет підпису не зберігається: він виводиться з REQUEST_SIGNING_MASTER і
+// солі ключа (ротація = нова сіль).
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const signedBodyMax = 10 << 20
+
+var (
+	signingMaster = func() []byte {
+		m := strings.TrimSpace(os.Getenv("REQUEST_SIGNING_MASTER"))
+		if m == "" {
+			log.Println("[signing] REQUEST_SIGNING_MASTER not set — signed API keys cannot be created")
+		}
+		return []byte(m)
+	}()
+	// SIGNING_MAX_SKEW — допустиме розходження годинників клієнта і сервера
+	signingMaxSkew = envDuration("SIGNING_MAX_SKEW", 5*time.Minute)
+)
+
+// signingSecretFor — секрет підпису для ключа id з сіллю salt
+func signingSecretFor(id, salt string) string {
+	m := hmac.New(sha256.New, signingMaster)
+	m.Write([]byte("marki-sign|" + id + "|" + salt))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
+
+func signingCanonical(method, pathQuery, ts, nonce string, body []byte) string {
+	sum := sha256.Sum256(body)
+	return strings.Join([]string{"v1", strings.ToUpper(method), pathQuery, ts, nonce, hex.EncodeToString(sum[:])}, "\n")
+}
+
+// signRequestMAC — для клієнтів і перевірки: hex HMAC канонічного рядка
+func signRequestMAC(secret, canonical string) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(canonical))
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// verifySignedRequest перевіряє підпис, вікно часу і одноразовість nonce;
+// тіло запиту читається і повертається на місце. code != 0 — відмова.
+func verifySignedRequest(ctx context.Context, r *http.Request, k FSAPIKey) (int, string) {
+	nonce, at, code, msg := checkRequestSignature(r, k, time.Now())
+	if code != 0 {
+		return code, msg
+	}
+
+	// nonce одноразовий у межах ключа; документ живе, поки timestamp у вікні
+	// (на колекції requestNonces варто ввімкнути TTL-політику за expiresAt)
+	nh := sha256.Sum256([]byte(nonce))
+	_, err := fsDoc("requestNonces/"+k.ID+"_"+hex.EncodeToString(nh[:16])).Create(ctx, map[string]any{
+		"keyId":     k.ID,
+		"createdAt": time.Now(),
+		"expiresAt": at.Add(2 * signingMaxSkew),
+	})
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "already exists") {
+			return 401, "nonce already used"
+		}
+		return 500, fmt.Sprintf("nonce store: %v", err)
+	}
+	return 0, ""
+}
+
+// checkRequestSignature — усе, крім одноразовості nonce: заголовки, вікно
+// часу відносно now і HMAC; повертає nonce і час підпису
+func checkRequestSignature(r *http.Request, k FSAPIKey, now time.Time) (string, time.Time, int, string) {
+	ts := strings.TrimSpace(r.Header.Get("X-Marki-Timestamp"))
+	nonce := strings.TrimSpace(r.Header.Get("X-Marki-Nonce"))
+	sig, ok := strings.CutPrefix(strings.TrimSpace(r.Header.Get("X-Marki-Signature")), "v1=")
+	if ts == "" || nonce == "" || !ok {
+		return "", time.Time{}, 401, "request signature required"
+	}
+	if len(nonce) < 8 || len(nonce) > 128 {
+		return "", time.Time{}, 401, "bad nonce"
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return "", time.Time{}, 401, "bad timestamp"
+	}
+	at := time.Unix(sec, 0)
+	if d := now.Sub(at); d > signingMaxSkew || d < -signingMaxSkew {
+		return "", time.Time{}, 401, "timestamp outside allowed skew"
+	}
+
+	var body []byte
+	if r.Body != nil {
+		body, err = io.ReadAll(io.LimitReader(r.Body, signedBodyMax+1))
+		r.Body.Close()
+		if err != nil {
+			return "", time.Time{}, 400, "cannot read body"
+		}
+		if len(body) > signedBodyMax {
+			return "", time.Time{}, 413, "body too large"
+		}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	pq := r.URL.EscapedPath()
+	if r.URL.RawQuery != "" {
+		pq += "?" + r.URL.RawQuery
+	}
+	want := signRequestMAC(signingSecretFor(k.ID, k.SigningSalt), signingCanonical(r.Method, pq, ts, nonce, body))
+	if !hmac.Equal([]byte(want), []byte(strings.ToLower(sig))) {
+		return "", time.Time{}, 401, "bad request signature"
+	}
+	return nonce, at, 0, ""
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigningCanonical(t *testing.T) {
+	got := signingCanonical("post", "/api/manufacturer/mint?brand=ACME", "1700000000", "nonce-0001", []byte(`{"count":1}`))
+	want := "v1\nPOST\n/api/manufacturer/mint?brand=ACME\n1700000000\nnonce-0001\n" +
+		"6aea6dfe6561984cdc5c54ead84d47d2cf29e48253ae282aef237404adad4661"
+	if got != want {
+		t.Fatalf("canonical = %q", got)
+	}
+	if mac := signRequestMAC("secret", want); mac != "1665142b3d182e3e565cc6ef4c6238878552db5d97d2da2d76bed4019fef3e13" {
+		t.Fatalf("mac = %s", mac)
+	}
+}
+
+func TestCheckRequestSignature(t *testing.T) {
+	saved := signingMaster
+	t.Cleanup(func() { signingMaster = saved })
+	signingMaster = []byte("test-master")
+
+	k := FSAPIKey{ID: "k1", SigningSalt: "salt-1"}
+	now := time.Unix(1700000000, 0)
+	const body = `{"count":1}`
+
+	type tc struct {
+		name           string
+		signPQ, sendPQ string
+		signBody, send string
+		nonce          string
+		at             time.Time
+		code           int
+	}
+	tests := []tc{
+		{"valid", "/api/manufacturer/mint?brand=ACME", "/api/manufacturer/mint?brand=ACME", body, body, "nonce-0001", now, 0},
+		{"tampered body", "/api/manufacturer/mint?brand=ACME", "/api/manufacturer/mint?brand=ACME", body, `{"count":9}`, "nonce-0001", now, 401},
+		{"tampered path", "/api/manufacturer/mint?brand=ACME", "/api/manufacturer/batches?brand=ACME", body, body, "nonce-0001", now, 401},
+		{"tampered query", "/api/manufacturer/mint?brand=ACME", "/api/manufacturer/mint?brand=EVIL", body, body, "nonce-0001", now, 401},
+		{"too old", "/api/manufacturer/mint?brand=ACME", "/api/manufacturer/mint?brand=ACME", body, body, "nonce-0001", now.Add(-signingMaxSkew - time.Second), 401},
+		{"from the future", "/api/manufacturer/mint?brand=ACME", "/api/manufacturer/mint?brand=ACME", body, body, "nonce-0001", now.Add(signingMaxSkew + time.Second), 401},
+		{"edge of skew", "/api/manufacturer/mint?brand=ACME", "/api/manufacturer/mint?brand=ACME", body, body, "nonce-0001", now.Add(-signingMaxSkew), 0},
+		{"short nonce", "/api/manufacturer/mint?brand=ACME", "/api/manufacturer/mint?brand=ACME", body, body, "n-1", now, 401},
+		{"missing nonce", "/api/manufacturer/mint?brand=ACME", "/api/manufacturer/mint?brand=ACME", body, body, "", now, 401},
+	}
+	for _, tt := range tests {
+		ts := strconv.FormatInt(tt.at.Unix(), 10)
+		mac := signRequestMAC(signingSecretFor(k.ID, k.SigningSalt), signingCanonical("POST", tt.signPQ, ts, tt.nonce, []byte(tt.signBody)))
+		r := httptest.NewRequest("POST", tt.sendPQ, strings.NewReader(tt.send))
+		r.Header.Set("X-Marki-Timestamp", ts)
+		r.Header.Set("X-Marki-Nonce", tt.nonce)
+		r.Header.Set("X-Marki-Signature", "v1="+strings.ToUpper(mac))
+		nonce, at, code, msg := checkRequestSignature(r, k, now)
+		if code != tt.code {
+			t.Errorf("%s: code %d (%s), want %d", tt.name, code, msg, tt.code)
+			continue
+		}
+		if code != 0 {
+			continue
+		}
+		if nonce != tt.nonce || !at.Equal(time.Unix(tt.at.Unix(), 0)) {
+			t.Errorf("%s: nonce %q at %v", tt.name, nonce, at)
+		}
+		// обробник після перевірки читає те саме тіло
+		if b, _ := io.ReadAll(r.Body); string(b) != tt.send {
+			t.Errorf("%s: body for handler = %q", tt.name, b)
+		}
+	}
+
+	// інший ключ (інша сіль) — інший секрет
+	r := httptest.NewRequest("POST", "/api/manufacturer/mint", strings.NewReader(body))
+	ts := strconv.FormatInt(now.Unix(), 10)
+	r.Header.Set("X-Marki-Timestamp", ts)
+	r.Header.Set("X-Marki-Nonce", "nonce-0002")
+	r.Header.Set("X-Marki-Signature", "v1="+signRequestMAC(signingSecretFor("k1", "salt-2"), signingCanonical("POST", "/api/manufacturer/mint", ts, "nonce-0002", []byte(body))))
+	if _, _, code, _ := checkRequestSignature(r, k, now); code != 401 {
+		t.Errorf("rotated salt accepted: %d", code)
+	}
+	r.Header.Del("X-Marki-Signature")
+	if _, _, code, msg := checkRequestSignature(r, k, now); code != 401 || msg != "request signature required" {
+		t.Errorf("unsigned request = %d %q", code, msg)
+	}
+}
@@ -0,0 +1,533 @@
+// users.go — внутрішні користувачі: стабільний ID (u_...), прив'язаний до
+// суб'єктів провайдерів ідентичності (provider:sub); email — лише змінний
+// атрибут. Власність продуктів, брендів, партій і заявок, ролі персоналу та
+// членство в командах посилаються на ID. migrateUsers переводить старі
+// email-записи на ID.
+//
+// Новий суб'єкт ніколи не приєднується до активного користувача за email:
+// інший провайдер додається лише явно, з уже відкритої сесії (/api/me/link).
+// Email не унікальний, тож адмінські ендпоінти приймають userId, а email —
+// лише якщо він однозначний.
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+type User struct {
+	ID        string `json:"id"`
+	Email     string `json:"email"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+type FSUser struct {
+	ID        string    `firestore:"id"`
+	Email     string    `firestore:"email"`
+	Subjects  []string  `firestore:"subjects"` // provider:sub; порожньо — створений за email і чекає першого входу
+	CreatedAt time.Time `firestore:"createdAt"`
+	UpdatedAt time.Time `firestore:"updatedAt"`
+}
+
+// subject → користувач, щоб не ходити у Firestore на кожен запит
+var userCache = newTTLCache[FSUser]()
+
+func userDoc(id string) *firestore.DocumentRef { return fsDoc("users/" + id) }
+
+// userSubjects/{sha256(provider:sub)} — унікальний індекс суб'єктів
+func subjectDoc(subject string) *firestore.DocumentRef {
+	return fsDoc("userSubjects/" + sha256Hex(subject))
+}
+
+func newUserID() string {
+	b := make([]byte, 10)
+	_, _ = rand.Read(b)
+	return "u_" + hex.EncodeToString(b)
+}
+
+func isUserID(s string) bool { return strings.HasPrefix(s, "u_") }
+
+func fsGetUser(ctx context.Context, id string) (FSUser, bool, error) {
+	s, err := userDoc(id).Get(ctx)
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "not found") {
+			return FSUser{}, false, nil
+		}
+		return FSUser{}, false, err
+	}
+	var u FSUser
+	if err := s.DataTo(&u); err != nil {
+		return FSUser{}, false, err
+	}
+	return u, true, nil
+}
+
+var errAmbiguousEmail = errors.New("several users share this email; pass userId")
+
+// fsUsersByEmail — усі користувачі з цим email (email не унікальний)
+func fsUsersByEmail(ctx context.Context, email string) ([]FSUser, error) {
+	docs, err := fsCol("users").Where("email", "==", strings.ToLower(strings.TrimSpace(email))).Limit(20).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]FSUser, 0, len(docs))
+	for _, d := range docs {
+		var u FSUser
+		if err := d.DataTo(&u); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, nil
+}
+
+// fsUserByEmail — єдиний користувач з цим email; кілька — errAmbiguousEmail
+func fsUserByEmail(ctx context.Context, email string) (FSUser, bool, error) {
+	list, err := fsUsersByEmail(ctx, email)
+	switch {
+	case err != nil || len(list) == 0:
+		return FSUser{}, false, err
+	case len(list) > 1:
+		return FSUser{}, false, errAmbiguousEmail
+	}
+	return list[0], true, nil
+}
+
+// fsUserRef — користувач за userId або однозначним email (адмінські ендпоінти)
+func fsUserRef(ctx context.Context, uid, email string) (FSUser, error) {
+	if uid = strings.TrimSpace(uid); uid != "" {
+		u, ok, err := fsGetUser(ctx, uid)
+		if err == nil && !ok {
+			err = opError{404, "user not found"}
+		}
+		return u, err
+	}
+	if email = strings.ToLower(strings.TrimSpace(email)); !strings.Contains(email, "@") {
+		return FSUser{}, opError{400, "userId or email required"}
+	}
+	u, ok, err := fsUserByEmail(ctx, email)
+	switch {
+	case errors.Is(err, errAmbiguousEmail):
+		return FSUser{}, opError{409, err.Error()}
+	case err != nil:
+		return FSUser{}, err
+	case !ok:
+		return FSUser{}, opError{404, "user not found (they must sign in first)"}
+	}
+	return u, nil
+}
+
+// fsUserForEmail — користувач з цим email для міграції; якщо ще не входив,
+// створюється запис без суб'єктів, який чекає першого входу власника
+func fsUserForEmail(ctx context.Context, email string) (FSUser, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if !strings.Contains(email, "@") {
+		return FSUser{}, fmt.Errorf("bad email")
+	}
+	var out FSUser
+	err := fsClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		docs, err := tx.Documents(fsCol("users").Where("email", "==", email).Limit(1)).GetAll()
+		if err != nil {
+			return err
+		}
+		if len(docs) > 0 {
+			return docs[0].DataTo(&out)
+		}
+		now := time.Now()
+		out = FSUser{ID: newUserID(), Email: email, Subjects: []string{}, CreatedAt: now, UpdatedAt: now}
+		return tx.Create(userDoc(out.ID), out)
+	})
+	return out, err
+}
+
+// fsResolveUser — користувач для перевіреної ідентичності, лише за
+// (provider, subject). Невідомий суб'єкт отримує нового користувача; виняток —
+// запис з міграції без жодного суб'єкта (ще нікому не належить) з тим самим
+// підтвердженим email.
+func fsResolveUser(ctx context.Context, id Identity) (FSUser, error) {
+	subject := identitySubject(id)
+	if u, ok := userCache.get(subject); ok && u.Email == id.Email {
+		return u, nil
+	}
+	var out FSUser
+	err := fsClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		now := time.Now()
+		s, err := tx.Get(subjectDoc(subject))
+		if err == nil {
+			us, err := tx.Get(userDoc(asString(s.Data()["userId"])))
+			if err != nil {
+				return err
+			}
+			if err := us.DataTo(&out); err != nil {
+				return err
+			}
+			// email змінився у провайдера — оновлюємо атрибут
+			if id.Email != "" && out.Email != id.Email {
+				out.Email = id.Email
+				return tx.Update(us.Ref, []firestore.Update{{Path: "email", Value: id.Email}, {Path: "updatedAt", Value: now}})
+			}
+			return nil
+		}
+		if !strings.Contains(strings.ToLower(err.Error()), "not found") {
+			return err
+		}
+
+		var placeholder *firestore.DocumentSnapshot
+		if id.EmailVerified && id.Email != "" {
+			docs, err := tx.Documents(fsCol("users").Where("email", "==", id.Email).Limit(20)).GetAll()
+			if err != nil {
+				return err
+			}
+			for _, d := range docs {
+				var u FSUser
+				if err := d.DataTo(&u); err != nil {
+					return err
+				}
+				if len(u.Subjects) > 0 {
+					continue
+				}
+				if placeholder != nil {
+					placeholder = nil // кілька — не вгадуємо
+					break
+				}
+				placeholder, out = d, u
+			}
+		}
+		if placeholder != nil {
+			out.Subjects = []string{subject}
+			if err := tx.Update(placeholder.Ref, []firestore.Update{
+				{Path: "subjects", Value: firestore.ArrayUnion(subject)},
+				{Path: "updatedAt", Value: now},
+			}); err != nil {
+				return err
+			}
+		} else {
+			out = FSUser{ID: newUserID(), Email: id.Email, Subjects: []string{subject}, CreatedAt: now, UpdatedAt: now}
+			if err := tx.Create(userDoc(out.ID), out); err != nil {
+				return err
+			}
+		}
+		return tx.Create(subjectDoc(subject), map[string]any{
+			"userId": out.ID, "subject": subject, "createdAt": now,
+		})
+	})
+	if err != nil {
+		return FSUser{}, err
+	}
+	userCache.set(subject, out, tokenCacheTTL)
+	return out, nil
+}
+
+func identitySubject(id Identity) string {
+	if id.Subject == "" {
+		return id.Provider + ":email:" + id.Email
+	}
+	return id.Provider + ":" + id.Subject
+}
+
+// fsLinkSubject — явно додає суб'єкт іншого провайдера до користувача uid
+func fsLinkSubject(ctx context.Context, uid string, id Identity) error {
+	subject := identitySubject(id)
+	err := fsClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		s, err := tx.Get(subjectDoc(subject))
+		if err == nil {
+			if asString(s.Data()["userId"]) == uid {
+				return nil
+			}
+			return opError{409, "this identity is already linked to another user"}
+		}
+		if !strings.Contains(strings.ToLower(err.Error()), "not found") {
+			return err
+		}
+		now := time.Now()
+		if err := tx.Update(userDoc(uid), []firestore.Update{
+			{Path: "subjects", Value: firestore.ArrayUnion(subject)},
+			{Path: "updatedAt", Value: now},
+		}); err != nil {
+			return err
+		}
+		return tx.Create(subjectDoc(subject), map[string]any{
+			"userId": uid, "subject": subject, "createdAt": now,
+		})
+	})
+	userCache.del(subject)
+	return err
+}
+
+// POST /api/me/link {token} — прив'язати ідентичність іншого провайдера до
+// поточного користувача; власність обох доводиться двома токенами
+func meLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		returnOK(w)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeJSON(w, 405, ErrorResp{"Method not allowed"})
+		return
+	}
+	u := currentUser(r)
+	if u == "" || !isUserID(u) {
+		writeJSON(w, 401, ErrorResp{"missing user"})
+		return
+	}
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || strings.TrimSpace(body.Token) == "" {
+		writeJSON(w, 400, ErrorResp{"token required"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 8*time.Second)
+	defer cancel()
+	id, ok := verifyToken(ctx, strings.TrimSpace(body.Token))
+	if !ok {
+		writeJSON(w, 400, ErrorResp{"invalid token"})
+		return
+	}
+	if err := fsLinkSubject(ctx, u, id); err != nil {
+		writeOpErr(w, err)
+		return
+	}
+	auditRecord(ctx, r, AuditUserLink, auditUser(u), nil, map[string]any{"provider": id.Provider})
+	writeJSON(w, 200, map[string]any{"ok": true, "provider": id.Provider})
+}
+
+// fsUserEmails — email-и за ID (для відображення команди, персоналу)
+func fsUserEmails(ctx context.Context, ids []string) (map[string]string, error) {
+	out := map[string]string{}
+	var refs []*firestore.DocumentRef
+	for _, id := range ids {
+		if id != "" {
+			refs = append(refs, userDoc(id))
+		}
+	}
+	if len(refs) == 0 {
+		return out, nil
+	}
+	snaps, err := fsClient.GetAll(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range snaps {
+		if s.Exists() {
+			out[s.Ref.ID] = asString(s.Data()["email"])
+		}
+	}
+	return out, nil
+}
+
+func fsUserEmail(ctx context.Context, id string) string {
+	m, err := fsUserEmails(ctx, []string{id})
+	if err != nil {
+		return ""
+	}
+	return m[id]
+}
+
+// ================== MIGRATION ==================
+
+// migrateUsers переводить email-поля на ID користувачів; повторний запуск
+// нічого не змінює. Записи журналу подій (ledger) незмінні й лишаються як є.
+func migrateUsers(ctx context.Context) (map[string]int, error) {
+	ids := map[string]string{}
+	uidFor := func(email string) (string, error) {
+		email = strings.ToLower(strings.TrimSpace(email))
+		if id, ok := ids[email]; ok {
+			return id, nil
+		}
+		u, err := fsUserForEmail(ctx, email)
+		if err != nil {
+			return "", err
+		}
+		ids[email] = u.ID
+		return u.ID, nil
+	}
+	stats := map[string]int{}
+	bw := fsClient.BulkWriter(ctx)
+	var jobs []*firestore.BulkWriterJob
+	queue := func(j *firestore.BulkWriterJob, err error) error {
+		if err == nil {
+			jobs = append(jobs, j)
+		}
+		return err
+	}
+
+	// поля з email → ID; renames: старе поле → нове; keep: поле → куди зберегти email
+	rewrite := func(col string, fields []string, renames, keep map[string]string) error {
+		it := fsCol(col).Documents(ctx)
+		defer it.Stop()
+		for {
+			d, err := it.Next()
+			if err == iterator.Done {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			data := d.Data()
+			var ups []firestore.Update
+			for _, f := range fields {
+				if v := asString(data[f]); strings.Contains(v, "@") {
+					id, err := uidFor(v)
+					if err != nil {
+						return err
+					}
+					ups = append(ups, firestore.Update{Path: f, Value: id})
+					if k := keep[f]; k != "" {
+						ups = append(ups, firestore.Update{Path: k, Value: strings.ToLower(v)})
+					}
+				}
+			}
+			for from, to := range renames {
+				if v := asString(data[from]); strings.Contains(v, "@") {
+					id, err := uidFor(v)
+					if err != nil {
+						return err
+					}
+					ups = append(ups, firestore.Update{Path: to, Value: id}, firestore.Update{Path: from, Value: firestore.Delete})
+				}
+			}
+			if len(ups) > 0 {
+				if err := queue(bw.Update(d.Ref, ups)); err != nil {
+					return err
+				}
+				stats[col]++
+			}
+		}
+	}
+
+	steps := []struct {
+		col     string
+		fields  []string
+		renames map[string]string
+		keep    map[string]string
+	}{
+		{"products", []string{"owner", "seller"}, nil, nil},
+		{"batches", []string{"owner"}, nil, nil},
+		{"brands", []string{"verifiedBy"}, map[string]string{"ownerEmail": "ownerId"}, nil},
+		{"companyApplications", []string{"user", "reviewedBy"}, nil, map[string]string{"user": "userEmail"}},
+		{"apiKeys", []string{"createdBy"}, nil, nil},
+		{"credentials", []string{"owner"}, nil, nil},
+		{"brandInvites", []string{"invitedBy"}, nil, nil},
+	}
+	for _, s := range steps {
+		if err := rewrite(s.col, s.fields, s.renames, s.keep); err != nil {
+			bw.End()
+			return stats, fmt.Errorf("%s: %w", s.col, err)
+		}
+	}
+
+	// admins/{email} → admins/{id}
+	admins, err := fsCol("admins").Documents(ctx).GetAll()
+	if err != nil {
+		bw.End()
+		return stats, err
+	}
+	for _, d := range admins {
+		if !strings.Contains(d.Ref.ID, "@") {
+			continue
+		}
+		var a FSAdmin
+		if err := d.DataTo(&a); err != nil {
+			continue
+		}
+		id, err := uidFor(d.Ref.ID)
+		if err != nil {
+			bw.End()
+			return stats, err
+		}
+		a.UserID, a.Email = id, d.Ref.ID
+		if by := a.GrantedBy; strings.Contains(by, "@") {
+			if a.GrantedBy, err = uidFor(by); err != nil {
+				bw.End()
+				return stats, err
+			}
+		}
+		if err := queue(bw.Set(adminDoc(id), a)); err != nil {
+			bw.End()
+			return stats, err
+		}
+		if err := queue(bw.Delete(d.Ref)); err != nil {
+			bw.End()
+			return stats, err
+		}
+		stats["admins"]++
+	}
+
+	// brandMembers/{slug}:{email} → brandMembers/{slug}:{id}
+	members, err := fsCol("brandMembers").Documents(ctx).GetAll()
+	if err != nil {
+		bw.End()
+		return stats, err
+	}
+	for _, d := range members {
+		var m FSBrandMember
+		if err := d.DataTo(&m); err != nil || m.UserID != "" || !strings.Contains(m.Email, "@") {
+			continue
+		}
+		id, err := uidFor(m.Email)
+		if err != nil {
+			bw.End()
+			return stats, err
+		}
+		m.UserID = id
+		if strings.Contains(m.AddedBy, "@") {
+			if m.AddedBy, err = uidFor(m.AddedBy); err != nil {
+				bw.End()
+				return stats, err
+			}
+		}
+		if err := queue(bw.Set(memberDoc(m.BrandSlug, id), m)); err != nil {
+			bw.End()
+			return stats, err
+		}
+		if err := queue(bw.Delete(d.Ref)); err != nil {
+			bw.End()
+			return stats, err
+		}
+		stats["brandMembers"]++
+	}
+
+	bw.End()
+	for _, j := range jobs {
+		if _, err := j.Results(); err != nil {
+			return stats, err
+		}
+	}
+	stats["users"] = len(ids)
+	log.Printf("[users] migration done: %v\n", stats)
+	return stats, nil
+}
+
+// POST /api/admins/migrate-users
+func adminMigrateUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		returnOK(w)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeJSON(w, 405, ErrorResp{"Method not allowed"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Minute)
+	defer cancel()
+	stats, err := migrateUsers(ctx)
+	if err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+	auditRecord(ctx, r, AuditUsersMigrate, "users", nil, stats)
+	writeJSON(w, 200, map[string]any{"ok": true, "updated": stats})
+}
@@ -0,0 +1,449 @@
+// vc.go — W3C Verifiable Credential (JWT-VC, EdDSA) про автентичність продукту:
+// видача власнику, DID-документ емітента (did:web) і перевірка з урахуванням
+// поточного стану продукту та відкликання.
+
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+type vcKey struct {
+	ID   string
+	Priv ed25519.PrivateKey
+}
+
+// VC_SIGNING_KEYS="k2:<base64 seed>,k1:<base64 seed>" — перший ключ підписує,
+// решта лишаються в DID-документі для перевірки вже виданих VC.
+var vcKeys = func() []vcKey {
+	var out []vcKey
+	for _, part := range strings.Split(os.Getenv("VC_SIGNING_KEYS"), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, b64, ok := strings.Cut(part, ":")
+		seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(b64))
+		if !ok || strings.TrimSpace(id) == "" || err != nil || len(seed) != ed25519.SeedSize {
+			log.Printf("[vc] skip malformed key entry %q\n", strings.TrimSpace(id))
+			continue
+		}
+		out = append(out, vcKey{ID: strings.TrimSpace(id), Priv: ed25519.NewKeyFromSeed(seed)})
+	}
+	if len(out) == 0 {
+		_, priv, _ := ed25519.GenerateKey(rand.Reader)
+		out = append(out, vcKey{ID: "ephemeral", Priv: priv})
+		log.Println("[vc] VC_SIGNING_KEYS not set — using an EPHEMERAL key, credentials die with the process")
+	}
+	return out
+}()
+
+// VC_ISSUER_DID або did:web з PUBLIC_BASE
+var vcIssuer = func() string {
+	if v := strings.TrimSpace(os.Getenv("VC_ISSUER_DID")); v != "" {
+		return v
+	}
+	u, err := url.Parse(publicBase)
+	if err != nil || u.Host == "" {
+		return "did:web:localhost"
+	}
+	did := "did:web:" + strings.ReplaceAll(u.Host, ":", "%3A")
+	if p := strings.Trim(u.Path, "/"); p != "" {
+		did += ":" + strings.ReplaceAll(p, "/", ":")
+	}
+	return did
+}()
+
+const vcDefaultTTL = 365 * 24 * time.Hour
+
+const (
+	VCActive     = "active"
+	VCRevoked    = "revoked"    // продукт відкликано
+	VCSuperseded = "superseded" // продукт змінив власника
+)
+
+type FSCredential struct {
+	ID        string    `firestore:"id"`
+	TokenID   int64     `firestore:"tokenId"`
+	Holder    string    `firestore:"holder"`
+	Owner     string    `firestore:"owner"` // власник на момент видачі
+	KeyID     string    `firestore:"keyId"`
+	IssuedAt  time.Time `firestore:"issuedAt"`
+	ExpiresAt time.Time `firestore:"expiresAt"`
+}
+
+func vcKeyByID(kid string) (vcKey, bool) {
+	for _, k := range vcKeys {
+		if vcIssuer+"#"+k.ID == kid {
+			return k, true
+		}
+	}
+	return vcKey{}, false
+}
+
+func b64url(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func signJWT(k vcKey, claims any) string {
+	head := b64url(mustJSON(map[string]any{"alg": "EdDSA", "typ": "JWT", "kid": vcIssuer + "#" + k.ID}))
+	input := head + "." + b64url(mustJSON(claims))
+	return input + "." + b64url(ed25519.Sign(k.Priv, []byte(input)))
+}
+
+// parseJWT перевіряє підпис ключем емітента і повертає claims
+func parseJWT(token string, claims any) error {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("malformed jwt")
+	}
+	hb, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return fmt.Errorf("malformed jwt header")
+	}
+	var head struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(hb, &head); err != nil || head.Alg != "EdDSA" {
+		return fmt.Errorf("unsupported jwt header")
+	}
+	k, ok := vcKeyByID(head.Kid)
+	if !ok {
+		return fmt.Errorf("unknown issuer key")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !ed25519.Verify(k.Priv.Public().(ed25519.PublicKey), []byte(parts[0]+"."+parts[1]), sig) {
+		return fmt.Errorf("bad signature")
+	}
+	pb, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return fmt.Errorf("malformed jwt payload")
+	}
+	return json.Unmarshal(pb, claims)
+}
+
+type vcClaims struct {
+	Iss string         `json:"iss"`
+	Sub string         `json:"sub"`
+	Jti string         `json:"jti"`
+	Iat int64          `json:"iat"`
+	Nbf int64          `json:"nbf"`
+	Exp int64          `json:"exp"`
+	VC  map[string]any `json:"vc"`
+}
+
+func vcStatusURL(id string) string {
+	return publicBase + "/api/vc/status/" + url.PathEscape(id)
+}
+
+// issueProductVC формує і підписує VC; holder — DID, непрозорий urn:uuid
+// або (за згодою власника) mailto:
+func issueProductVC(ctx context.Context, p Product, holder string, ttl time.Duration) (string, vcClaims, error) {
+	brand := map[string]any{"slug": p.BrandSlug}
+	if p.BrandSlug != "" {
+		if b, err := fsGetBrand(ctx, p.BrandSlug); err == nil {
+			brand["name"] = b.Name
+			brand["verified"] = b.Verified
+			brand["suspended"] = b.Suspended
+		}
+	}
+	product := map[string]any{
+		"tokenId":     p.TokenID,
+		"name":        p.Meta.Name,
+		"brand":       brand,
+		"metadataCid": p.IPFSHash,
+		"serialHash":  p.SerialHash,
+		"state":       p.State,
+	}
+	if p.GTIN != "" {
+		product["gtin"] = p.GTIN
+	}
+	if p.SKU != "" {
+		product["sku"] = p.SKU
+	}
+
+	k := vcKeys[0]
+	now := time.Now()
+	id := "urn:uuid:" + randUUID()
+	c := vcClaims{
+		Iss: vcIssuer, Sub: holder, Jti: id,
+		Iat: now.Unix(), Nbf: now.Unix(), Exp: now.Add(ttl).Unix(),
+		VC: map[string]any{
+			"@context":       []string{"https://www.w3.org/2018/credentials/v1"},
+			"type":           []string{"VerifiableCredential", "ProductAuthenticityCredential"},
+			"id":             id,
+			"issuer":         vcIssuer,
+			"issuanceDate":   now.UTC().Format(time.RFC3339),
+			"expirationDate": now.Add(ttl).UTC().Format(time.RFC3339),
+			"credentialSubject": map[string]any{
+				"id":        holder,
+				"authentic": true,
+				"product":   product,
+			},
+			"credentialStatus": map[string]any{
+				"id":   vcStatusURL(id),
+				"type": "MarkiProductStatus",
+			},
+		},
+	}
+	_, err := fsDoc("credentials/"+url.PathEscape(id)).Create(ctx, FSCredential{
+		ID: id, TokenID: p.TokenID, Holder: holder, Owner: strings.ToLower(p.Owner),
+		KeyID: k.ID, IssuedAt: now, ExpiresAt: now.Add(ttl),
+	})
+	if err != nil {
+		return "", vcClaims{}, err
+	}
+	return signJWT(k, c), c, nil
+}
+
+func randUUID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func fsGetCredential(ctx context.Context, id string) (FSCredential, bool, error) {
+	s, err := fsDoc("credentials/" + url.PathEscape(id)).Get(ctx)
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "not found") {
+			return FSCredential{}, false, nil
+		}
+		return FSCredential{}, false, err
+	}
+	var c FSCredential
+	if err := s.DataTo(&c); err != nil {
+		return FSCredential{}, false, err
+	}
+	return c, true, nil
+}
+
+// credentialStatus — VC чинний, поки продукт не відкликано і власник той самий
+func credentialStatus(ctx context.Context, c FSCredential) (string, Product, error) {
+	p, ok, err := fsGetProduct(ctx, c.TokenID)
+	if err != nil {
+		return "", Product{}, err
+	}
+	switch {
+	case !ok || p.State == StateRevoked:
+		return VCRevoked, p, nil
+	case !strings.EqualFold(p.Owner, c.Owner):
+		return VCSuperseded, p, nil
+	}
+	return VCActive, p, nil
+}
+
+// checkVCClaims: емітент і строк дії; "" — claims придатні
+func checkVCClaims(c vcClaims, now int64) string {
+	switch {
+	case c.Iss != vcIssuer:
+		return "unknown issuer"
+	case now < c.Nbf:
+		return "not yet valid"
+	case now >= c.Exp:
+		return "expired"
+	}
+	return ""
+}
+
+// verifyProductVC: підпис і ключ емітента → строк дії → запис про видачу → стан продукту
+func verifyProductVC(ctx context.Context, token string) (map[string]any, error) {
+	var c vcClaims
+	if err := parseJWT(token, &c); err != nil {
+		return map[string]any{"valid": false, "error": err.Error()}, nil
+	}
+	res := map[string]any{"valid": false, "id": c.Jti, "issuer": c.Iss, "holder": c.Sub}
+	if msg := checkVCClaims(c, time.Now().Unix()); msg != "" {
+		res["error"] = msg
+		return res, nil
+	}
+	rec, ok, err := fsGetCredential(ctx, c.Jti)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || rec.Holder != c.Sub {
+		res["error"] = "credential not issued here"
+		return res, nil
+	}
+	st, p, err := credentialStatus(ctx, rec)
+	if err != nil {
+		return nil, err
+	}
+	res["status"] = st
+	res["tokenId"] = rec.TokenID
+	res["state"] = p.State
+	if st != VCActive {
+		res["error"] = "credential " + st
+		return res, nil
+	}
+	res["valid"] = true
+	res["credential"] = c.VC
+	return res, nil
+}
+
+// ================== HTTP ==================
+
+// POST /api/products/{id}/vc {holder?, includeEmail?, ttlDays?} — власник або
+// бренд продукту з PermBrandProducts
+func productIssueVC(w http.ResponseWriter, r *http.Request, id int64) {
+	u := currentUser(r)
+	if u == "" {
+		writeJSON(w, 401, ErrorResp{"missing user"})
+		return
+	}
+	var body struct {
+		Holder       string `json:"holder"`
+		IncludeEmail bool   `json:"includeEmail"` // власник погоджується показати email у VC
+		TTLDays      int    `json:"ttlDays"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	p, ok, err := fsGetProduct(ctx, id)
+	if err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+	if !ok {
+		writeJSON(w, 404, ErrorResp{"product not found"})
+		return
+	}
+	isOwner := strings.EqualFold(p.Owner, u)
+	if !isOwner && !canBrand(ctx, u, p.BrandSlug, PermBrandProducts) {
+		writeJSON(w, 403, ErrorResp{"forbidden"})
+		return
+	}
+	if p.State == StateRevoked {
+		writeJSON(w, 409, ErrorResp{"product revoked"})
+		return
+	}
+
+	holder := strings.TrimSpace(body.Holder)
+	switch {
+	case body.IncludeEmail:
+		// email розкриває лише сам власник
+		e := fsUserEmail(ctx, p.Owner)
+		if !isOwner || e == "" || holder != "" {
+			writeJSON(w, 400, ErrorResp{"includeEmail is only for the owner without an explicit holder"})
+			return
+		}
+		holder = "mailto:" + e
+	case holder == "":
+		// непрозорий id на кожен VC: ні email, ні userId власника не потрапляють
+		// у публічний credential і різні VC не зв'язуються між собою
+		holder = "urn:uuid:" + randUUID()
+	case !strings.HasPrefix(holder, "did:"):
+		writeJSON(w, 400, ErrorResp{"holder must be a DID"})
+		return
+	}
+	ttl := vcDefaultTTL
+	if body.TTLDays > 0 {
+		ttl = time.Duration(min(body.TTLDays, 3650)) * 24 * time.Hour
+	}
+
+	jwt, c, err := issueProductVC(ctx, p, holder, ttl)
+	if err != nil {
+		writeJSON(w, 500, ErrorResp{err.Error()})
+		return
+	}
+	writeJSON(w, 201, map[string]any{"id": c.Jti, "jwt": jwt, "credential": c.VC})
+}
+
+// POST /api/vc/verify {jwt}
+// GET  /api/vc/status/{id}
+func vcRoutes(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		returnOK(w)
+		return
+	}
+	rest := strings.TrimPrefix(r.URL.Path, "/api/vc/")
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	switch {
+	case rest == "verify" && r.Method == http.MethodPost:
+		var body struct {
+			JWT string `json:"jwt"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || strings.TrimSpace(body.JWT) == "" {
+			writeJSON(w, 400, ErrorResp{"jwt required"})
+			return
+		}
+		res, err := verifyProductVC(ctx, body.JWT)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		writeJSON(w, 200, res)
+
+	case strings.HasPrefix(rest, "status/") && r.Method == http.MethodGet:
+		id, err := url.PathUnescape(strings.TrimPrefix(rest, "status/"))
+		if err != nil {
+			writeJSON(w, 400, ErrorResp{"bad id"})
+			return
+		}
+		rec, ok, err := fsGetCredential(ctx, id)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		if !ok {
+			writeJSON(w, 404, ErrorResp{"credential not found"})
+			return
+		}
+		st, _, err := credentialStatus(ctx, rec)
+		if err != nil {
+			writeJSON(w, 500, ErrorResp{err.Error()})
+			return
+		}
+		writeJSON(w, 200, map[string]any{"id": rec.ID, "tokenId": rec.TokenID, "status": st})
+
+	default:
+		writeJSON(w, 405, ErrorResp{"Method not allowed"})
+	}
+}
+
+// GET /.well-known/did.json — DID-документ емітента з усіма ключами VC
+func didDocument(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		returnOK(w)
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeJSON(w, 405, ErrorResp{"Method not allowed"})
+		return
+	}
+	var methods []map[string]any
+	var refs []string
+	for _, k := range vcKeys {
+		kid := vcIssuer + "#" + k.ID
+		methods = append(methods, map[string]any{
+			"id":         kid,
+			"type":       "JsonWebKey2020",
+			"controller": vcIssuer,
+			"publicKeyJwk": map[string]string{
+				"kty": "OKP", "crv": "Ed25519", "kid": k.ID,
+				"x": b64url(k.Priv.Public().(ed25519.PublicKey)),
+			},
+		})
+		refs = append(refs, kid)
+	}
+	writeJSON(w, 200, map[string]any{
+		"@context":           []string{"https://www.w3.org/ns/did/v1", "https://w3id.org/security/suites/jws-2020/v1"},
+		"id":                 vcIssuer,
+		"verificationMethod": methods,
+		"assertionMethod":    refs,
+	})
+}
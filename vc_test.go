@@ -0,0 +1,92 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func withTestVCKeys(t *testing.T) (vcKey, vcKey) {
+	t.Helper()
+	old := vcKeys
+	t.Cleanup(func() { vcKeys = old })
+	_, p2, _ := ed25519.GenerateKey(rand.Reader)
+	_, p1, _ := ed25519.GenerateKey(rand.Reader)
+	vcKeys = []vcKey{{ID: "k2", Priv: p2}, {ID: "k1", Priv: p1}}
+	return vcKeys[0], vcKeys[1]
+}
+
+func TestJWTRoundTrip(t *testing.T) {
+	cur, rotated := withTestVCKeys(t)
+	for _, k := range []vcKey{cur, rotated} {
+		in := vcClaims{Iss: vcIssuer, Sub: "did:example:alice", Jti: "urn:uuid:1", Nbf: 100, Exp: 200}
+		var out vcClaims
+		if err := parseJWT(signJWT(k, in), &out); err != nil {
+			t.Fatalf("key %s: %v", k.ID, err)
+		}
+		if out.Sub != in.Sub || out.Jti != in.Jti || out.Exp != in.Exp {
+			t.Errorf("key %s: claims = %+v", k.ID, out)
+		}
+	}
+}
+
+func TestParseJWTRejects(t *testing.T) {
+	cur, _ := withTestVCKeys(t)
+	token := signJWT(cur, vcClaims{Iss: vcIssuer, Sub: "did:example:alice", Exp: 200})
+	parts := strings.Split(token, ".")
+	enc := base64.RawURLEncoding.EncodeToString
+
+	_, stranger, _ := ed25519.GenerateKey(rand.Reader)
+	foreign := signJWT(vcKey{ID: "k9", Priv: stranger}, vcClaims{Iss: vcIssuer})
+
+	// підпис і ключ справжні, але в заголовку інший алгоритм
+	algHead := enc([]byte(`{"alg":"none","typ":"JWT","kid":"` + vcIssuer + `#k2"}`))
+	algInput := algHead + "." + parts[1]
+	algNone := algInput + "." + enc(ed25519.Sign(cur.Priv, []byte(algInput)))
+
+	// підміна payload без перепідпису
+	tampered := parts[0] + "." + enc([]byte(`{"iss":"`+vcIssuer+`","sub":"did:example:mallory","exp":200}`)) + "." + parts[2]
+
+	// ключ з тим самим ID, але іншого емітента
+	otherIss := enc([]byte(`{"alg":"EdDSA","typ":"JWT","kid":"did:web:evil.example#k2"}`))
+	otherInput := otherIss + "." + parts[1]
+	otherIssuer := otherInput + "." + enc(ed25519.Sign(cur.Priv, []byte(otherInput)))
+
+	tests := map[string]string{
+		"alg none":       algNone,
+		"unknown kid":    foreign,
+		"foreign issuer": otherIssuer,
+		"tampered":       tampered,
+		"two parts":      parts[0] + "." + parts[1],
+		"garbage header": "@@." + parts[1] + "." + parts[2],
+	}
+	for name, tok := range tests {
+		var c vcClaims
+		if err := parseJWT(tok, &c); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestCheckVCClaims(t *testing.T) {
+	tests := []struct {
+		name string
+		c    vcClaims
+		now  int64
+		want string
+	}{
+		{"valid", vcClaims{Iss: vcIssuer, Nbf: 100, Exp: 200}, 150, ""},
+		{"at nbf", vcClaims{Iss: vcIssuer, Nbf: 100, Exp: 200}, 100, ""},
+		{"not yet valid", vcClaims{Iss: vcIssuer, Nbf: 100, Exp: 200}, 99, "not yet valid"},
+		{"at exp", vcClaims{Iss: vcIssuer, Nbf: 100, Exp: 200}, 200, "expired"},
+		{"expired", vcClaims{Iss: vcIssuer, Nbf: 100, Exp: 200}, 500, "expired"},
+		{"other issuer", vcClaims{Iss: "did:web:evil.example", Nbf: 100, Exp: 200}, 150, "unknown issuer"},
+	}
+	for _, tt := range tests {
+		if got := checkVCClaims(tt.c, tt.now); got != tt.want {
+			t.Errorf("%s: %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}